@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	golog "log"
 	"net/http"
@@ -11,11 +12,20 @@ import (
 	"os/signal"
 	"syscall"
 	"ticket-processor/internal/api/handlers"
+	"time"
 
 	"ticket-processor/internal/api"
+	"ticket-processor/internal/auth"
 	"ticket-processor/internal/config"
+	"ticket-processor/internal/health"
+	"ticket-processor/internal/metrics"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/receipt"
 	"ticket-processor/internal/services"
 	"ticket-processor/internal/storage"
+	"ticket-processor/internal/tracing"
+	"ticket-processor/internal/validation"
+	"ticket-processor/internal/webhooks"
 	"ticket-processor/pkg/logger"
 )
 
@@ -28,17 +38,175 @@ func main() {
 	log := logger.SetupLogger(cfg.Env)
 	defer log.Sync()
 
-	store := storage.NewInMemoryStore()
-	cache := storage.NewInMemoryCache(log)
-	receiptProcessor := services.NewReceiptProcessor(log, store, cache)
-	receiptHandler := handlers.NewReceiptHandler(log, receiptProcessor)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing", zap.String("exporter", cfg.Tracing.Exporter), zap.Error(err))
+	}
+
+	rules, err := receipt.NewRegistryFromConfig(cfg.Rules)
+	if err != nil {
+		log.Fatal("Failed to build points rules", zap.Error(err))
+	}
+
+	tolerance, err := models.ParseMoney(cfg.Validation.TotalTolerance)
+	if err != nil {
+		log.Fatal("Invalid total tolerance", zap.Error(err))
+	}
+
+	store, closeStore, err := newStorage(log, cfg.Storage)
+	if err != nil {
+		log.Fatal("Failed to initialize storage", zap.String("driver", cfg.Storage.Driver), zap.Error(err))
+	}
+	defer func() {
+		if err := closeStore(); err != nil {
+			log.Error("Error closing storage", zap.Error(err))
+		}
+	}()
+
+	cache, closeCache, err := newCache(log, cfg.Cache)
+	if err != nil {
+		log.Fatal("Failed to initialize cache", zap.String("driver", cfg.Cache.Driver), zap.Error(err))
+	}
+	defer func() {
+		if err := closeCache(); err != nil {
+			log.Error("Error closing cache", zap.Error(err))
+		}
+	}()
+
+	m := metrics.New()
+	if statsCache, ok := cache.(metrics.StatsCache); ok {
+		if err := m.RegisterCache(cfg.Cache.Driver, statsCache); err != nil {
+			log.Fatal("Failed to register cache metrics", zap.Error(err))
+		}
+	}
+
+	checker := health.NewChecker(log, cfg.Health.CheckTimeout)
+	registerHealthCheck(checker, "storage", store)
+	registerHealthCheck(checker, "cache", cache)
+
+	apiKeyStore, ok := store.(storage.APIKeyStore)
+	if !ok {
+		log.Fatal("Storage does not support API keys", zap.String("driver", cfg.Storage.Driver))
+	}
+	if cfg.Auth.Enabled && cfg.Auth.AdminKey == "" {
+		log.Warn("Authentication is enabled without an admin key; only existing API keys can call the API")
+	}
+	apiKeyService := services.NewAPIKeyService(log, apiKeyStore, cfg.Auth.AdminKey)
+
+	// Webhooks are kept with the receipts when the storage is durable.
+	webhookStore := storage.NewInMemoryWebhookStore(cfg.Webhooks.MaxDeadLetters)
+	if webhookStorage, ok := store.(storage.WebhookStorage); ok {
+		webhookStore = webhookStorage.Webhooks(cfg.Webhooks.MaxDeadLetters)
+	}
+	webhookStore = webhooks.NewSubscriptionCache(webhookStore)
+
+	tokens, err := newTokenVerifier(log, cfg.Auth.JWT)
+	if err != nil {
+		log.Fatal("Failed to load JWKS", zap.Error(err))
+	}
+
+	store = tracing.WrapStorage(store, cfg.Storage.Driver)
+	cache = tracing.WrapCache(cache, cfg.Cache.Driver)
+
+	dispatcher := webhooks.NewDispatcher(log, webhookStore, &http.Client{Timeout: cfg.Webhooks.Timeout}, webhooks.Options{
+		Workers:        cfg.Webhooks.Workers,
+		QueueSize:      cfg.Webhooks.QueueSize,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+	})
 
-	e := api.SetupRouter(log, cfg, receiptHandler)
+	receiptProcessor := services.NewReceiptProcessor(log, store, cache, rules, cfg.Duplicates.Policy, services.MultiPublisher(dispatcher, m))
 
-	gracefulShutdown(e, log, cfg)
+	var queue services.ReceiptQueue
+	if cfg.Async.Enabled {
+		queue = services.NewReceiptQueue(log, receiptProcessor, cfg.Async.Workers, cfg.Async.QueueSize, cfg.Async.StatusTTL)
+		registerHealthCheck(checker, "queue", queue)
+	}
+
+	idempotency := storage.NewInMemoryIdempotencyStore(cfg.Idempotency.TTL)
+	receiptHandler := handlers.NewReceiptHandler(log, receiptProcessor, queue, idempotency, cfg.Batch.MaxSize, validation.TotalTolerance{Amount: tolerance, Percent: cfg.Validation.TotalTolerancePercent})
+	webhookHandler := handlers.NewWebhookHandler(log, webhookStore)
+	customerHandler := handlers.NewCustomerHandler(log, services.NewCustomerService(log, store))
+	healthHandler := handlers.NewHealthHandler(log, checker)
+	apiKeyHandler := handlers.NewAPIKeyHandler(log, apiKeyService)
+
+	e, err := api.SetupRouter(log, cfg, receiptHandler, webhookHandler, customerHandler, healthHandler, apiKeyHandler, apiKeyService, tokens, m)
+	if err != nil {
+		log.Fatal("Failed to set up router", zap.Error(err))
+	}
+
+	gracefulShutdown(e, log, cfg, checker, queue, dispatcher, shutdownTracing)
+}
+
+// registerHealthCheck adds dependency to the readiness checks if it can report its health.
+func registerHealthCheck(checker health.Checker, name string, dependency any) {
+	if hc, ok := dependency.(health.HealthChecker); ok {
+		checker.Register(name, hc)
+	}
+}
+
+// newTokenVerifier returns the bearer token verifier, or nil if bearer tokens are not configured.
+func newTokenVerifier(log *zap.Logger, cfg config.JWT) (auth.Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	source := auth.FileKeySource(cfg.JWKSFile)
+	if cfg.JWKSURL != "" {
+		source = auth.URLKeySource(&http.Client{Timeout: cfg.Timeout}, cfg.JWKSURL)
+	}
+	return auth.NewVerifier(context.Background(), log, cfg, source)
+}
+
+func newCache(log *zap.Logger, cfg config.Cache) (storage.Cache, func() error, error) {
+	switch cfg.Driver {
+	case config.CacheDriverRedis:
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Redis.Timeout)
+		defer cancel()
+		c, err := storage.NewRedisCache(ctx, log, &redis.Options{
+			Addr:         cfg.Redis.Address,
+			Password:     cfg.Redis.Password,
+			DB:           cfg.Redis.DB,
+			DialTimeout:  cfg.Redis.Timeout,
+			ReadTimeout:  cfg.Redis.Timeout,
+			WriteTimeout: cfg.Redis.Timeout,
+		}, cfg.Redis.KeyPrefix)
+		if err != nil {
+			return nil, nil, err
+		}
+		return c, c.Close, nil
+	default:
+		c := storage.NewInMemoryCache(log, cfg.MaxEntries, cfg.SweepInterval)
+		return c, func() error { c.Close(); return nil }, nil
+	}
+}
+
+func newStorage(log *zap.Logger, cfg config.Storage) (storage.Storage, func() error, error) {
+	switch cfg.Driver {
+	case config.StorageDriverFile:
+		s, err := storage.NewFileStore(log, cfg.File.Dir, cfg.File.SnapshotEvery)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s.Close, nil
+	case config.StorageDriverSQLite:
+		s, err := storage.NewSQLiteStore(context.Background(), cfg.SQLite.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s, s.Close, nil
+	default:
+		return storage.NewInMemoryStore(), func() error { return nil }, nil
+	}
 }
 
-func gracefulShutdown(e *echo.Echo, log *zap.Logger, cfg *config.Config) {
+// gracefulShutdown stops the server on SIGINT or SIGTERM, then lets queue (if
+// any) finish the receipts it has accepted, dispatcher deliver their events and
+// shutdownTracing export the remaining spans, sharing one shutdown timeout.
+// Readiness is withdrawn first, and the server keeps serving for the drain
+// delay so load balancers can stop routing to it.
+func gracefulShutdown(e *echo.Echo, log *zap.Logger, cfg *config.Config, checker health.Checker, queue services.ReceiptQueue, dispatcher webhooks.Dispatcher, shutdownTracing func(context.Context) error) {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
@@ -51,6 +219,12 @@ func gracefulShutdown(e *echo.Echo, log *zap.Logger, cfg *config.Config) {
 
 	<-ctx.Done()
 
+	checker.Drain()
+	if cfg.Health.DrainDelay > 0 {
+		log.Info("Draining traffic before shutdown", zap.Duration("delay", cfg.Health.DrainDelay))
+		time.Sleep(cfg.Health.DrainDelay)
+	}
+
 	log.Info("Shutting down server...")
 
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
@@ -60,5 +234,20 @@ func gracefulShutdown(e *echo.Echo, log *zap.Logger, cfg *config.Config) {
 		log.Error("Server shutdown error", zap.Error(err))
 	}
 
+	if queue != nil {
+		log.Info("Draining receipt queue...")
+		if err := queue.Shutdown(shutdownCtx); err != nil {
+			log.Error("Receipt queue shutdown error", zap.Error(err))
+		}
+	}
+
+	if err := dispatcher.Close(shutdownCtx); err != nil {
+		log.Error("Webhook dispatcher shutdown error", zap.Error(err))
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error("Tracing shutdown error", zap.Error(err))
+	}
+
 	log.Info("Server stopped")
 }
@@ -18,21 +18,97 @@ import (
 // Base64 encoded, gzipped, json marshaled Swagger object
 var swaggerSpec = []string{
 
-	"H4sIAAAAAAAC/6xWQW/bOBP9K8R8vVW2ZcWf0ei2XWMXxiJFkPQWZQFaHMXsRiQ7HDU1Av33BSVZtmyl",
-	"SYA9JZKGM4/vvZnxM+S2dNagYQ/pMxB6Z43H5uGzVDf4vULP4Umhz0k71tZACl+3KAhz1I6F9kKbH/JR",
-	"qynUEXyx/IetjDo/9MX2Z4oQIQpLgreSxXo1hbqOwOdbLGVTfc1Yhr+OrENi3WJypHMch8OW5aNoAoST",
-	"O9ynD/AYyylEgD9l6R4RUlhOF5cQgZPMSCHD31mmPmbZNMvUc1J/gAh450KkZ9LmIVzMby3x6rjuGIzb",
-	"ECWuyaoqZ3EU3sHBETRXtjIstRErfBLz5PqvIbS7LHvKMp9lk/uPI8jqCAi/V5pQQXp3DjPqWLvvT9rN",
-	"N8w53Omm1eOc6ABy+M8HwgJS+N/sYJlZp9esEauOoNRm3cbP+2KSSO7CR1dRvpUeV5JfkFBJRmGLhqV9",
-	"dFDUMCphTfO+c9CQwCROkkk8n8RziKCwVEqGFEK6MSH3qb/q8iUv6fLNQESymGxtRe0h/OkwZ1RDfPOL",
-	"dAgtxI5BI2SpH5HGYRl5gLWPFJaEZ0t4DCr0ZEH21GZZFcfJ8kr8bskgiStJ/yC/5LU2eNRxETTN9qs+",
-	"lGXwtHBS/1q59zfiid17xk4MdiJz1Bl5D/28GUJibQp7fqvfhNcBbs+uI5uj9zYUZc3NRbpOCp3ff/uB",
-	"5NsU82k8jQNx1qGRTkMKF9N4etFefds02KxL72dd/qYr7dj4va02pWYv5NFApT0sbR4Cx6GbZYhfK0jh",
-	"2nruIPoOIrREoufPVu1CkdwaRtPUk8496rw5P/vm22HXdvtrs2A/UuqhUkwVNi+ONkwSx+8qezKimhVz",
-	"sJJUm+Xm/8t4EiMWk0WyySeXar6cqGLxqbiI8dPlJjm12u0bBqpWL7hlqMkNckXGN1Zfr4T0Xj8YVILt",
-	"0P11BIv23mMs9vzMjtZvsxqrspS0e5P4If7gpmet6pmzutvxDzhiqGPwbaiQT5JUv0UH7Tu01p/YO2ut",
-	"rts6gWaSJTKSh/RubE6sV4dR1mfW4WvoCIggTDtIA/2nPoqOPfG6nPf/qe0OTPbWm8fx0WzXhpeLA4yw",
-	"NB6QGlFeNVEz5atyE+Z6caJEZ53F69bpf4INjfMekeu6rv8NAAD//+zUmakeCgAA",
+	"H4sIAAAAAAAC/+x8+2/jOJL/v0LoO8D3Dis7jvPo7jQOuPRjbjMzPRMkfduLG/ctaKlkcyORHpJK4m3k",
+	"fz+wSFGURfnRk5kBbu+nxLZEFuv5qWKRX5JMVCvBgWuVXHxJVlTSCjRI/HS5Yt/D+io3/+egMslWmgme",
+	"XCQfl0Cu3hFREL0Ecnl9Re5gPU7ShJlfV1QvkzThtILkImF5kiYSfqmZhDy50LKGNFHZEipqp9QapHnt",
+	"v2ez2z99k6SJXq/Mi0pLxhfJ01OavK2VFhXI3bRk7smvI6aijz8AX+hlcnF+moa0/TybPcxmo/G/fx4g",
+	"8SqHaiU08Gz9Paz7ZF6SrGTA9ShbCgXcMCwlNWe/1EBWIEkpFiyjJTHEgdIp0UuqSUXvQBEJWjJQRNEC",
+	"xuR7WCtCJcy4ysQKcqIFLp3Weglcs4xqyN1s4xlvGLEEmoNsWREQPDIUD/BhenaWJhXjzefj/uKfDEvV",
+	"SnAFqDdvaH5jVxGXloQM2EoTpgjj97Rk+TgxQha8KFm246UHqggtJdB8TVQ9r5g2q6U8J3m9KnHxyB0i",
+	"4e+QachTIiShDV/JA9NLZJeiFZANJhiSlGZlSebA+IKspMhAKbAEBg9/YKqiOlsOqOPGqCHNtYLcEkFJ",
+	"zooCJHDtqZuLfI1z/Sj0t6LmEX3/UXheFOYJUghpleXq3RhV0coxMGHz30qKFUjNrIgyCUZLLpHZhZAV",
+	"1clFklMNI80q6Gt4akzn4kv/6ztYx5mgIJOgjXIq4DlhHLn+19Hl9RUyxerjmPzEyzWRoGvJDWeWYB+8",
+	"s9JwhBoZSqHNv8ay4ZFWq9IQold3fzspXmUTOJu/yKf0NDuHl8UxPZmfZS/yVzAppvR0fp69zCdwXJzQ",
+	"s/mL7FV+DCfFGX0xf5Ud5yfR1VojiaxXwr24O4xzjvBDXkHLRkkxDRX+842EIrlI/t9R67KPnKSPrJhv",
+	"zUvJkx+OSknXydNT6PF+ti4Ql+enSQN9+OxfF3NjQGa8cPiLLwnwujIjOTVUF0azk7T9/CCZNsPTvGI8",
+	"+RxZ3xtjPFcaqhtQdan7CuqNua9c1xKUsRlj88aBE2Zdf2MVaJjoEwhQWTKQRHBIyYNxvCZUMOX1bdwy",
+	"fy5ECZQb6kBKIVV/5k/LdWcmY9dc6NBNpPsJ7FsGZf7eTNOXV2Nq0TBHlWIL3jp9Rwo6OfdVs2jvZnXg",
+	"GsczfjlH9rGCsNgSMGL0rZ/n8BinaiUUMx+bEOzntYY8N+JIidJUauNTqSaTgO2Ma1gAsmElmMMhsUnM",
+	"b4Q+UJlDPibtIrYKxLCFKUJbDpipvQ0yrs9PI7RsmgwuPmYZqMdDOoxqNKxFyBgkOas1UUshdbguuAe5",
+	"DpnppGWMbRwTUUFZCaGTDlgrkcT9HcqmfUaUVNVZBpDHZ9xgYDN9+JYnOMbXBvC9oSXlGfR5O29/8MHg",
+	"5HQSU6usAx77yC0kNHg29XNsI/AHyM00fdlzLd2/ezHcjvOea7mOMZvDo35bSxXTp2uqFKHKwV/zDPE4",
+	"3viEBWj8zYxBVnQBXsuEtdCSKvdDku7gT7OsGEsCn9ZjR2F+i8bTCpSiC4iLpjeHUcn+6CvJMoj7DS00",
+	"LQk+QFZ0DQ1cMthTQ9UFE+fj01dJ2k1J8j/NZuPZLP8yffomGqmN3b4L542RcWueItdS5HWmSfC4Iwci",
+	"1HwQNdeUcfIOHsjx9Pr7JJKRqNlsNJSShILrkZk6rsUkGSrjNsvrr9P9SGiByrcEYjRmHff3XwFCt9py",
+	"2iXoy94YdlvgyZaUL6CJrW59KeGwoJrdA8pPggH85iWFqEQa361oqeLrdj59KJl1P7fcQ7df0Rwni7p+",
+	"+0WLzDIJOdMIyRrK8IMlK4LIYhix4wvxec+p1jfugo7X+MIbCfQuFw88YrtbeG+Nl9fVHKRBFxsgILSW",
+	"6cs9wnqayLo8wCnf1CVcmsl2gmrPFztBjBM3VqyRXGxHcaP5HRWiYYEEYoWMUHBM3OgKU0tRG98O+JSB",
+	"Q82T4xl/BwU1obhRaFUjgQ12mwOVGDXugKeoy1WtHKQmTJOal6BsqMFnZnzpIg9ifSJFCRZBtn6soX90",
+	"Ok3Sry6xBMB6L9lhpHjC0sWVff64H1ZXtcyWVMG7aKJhmG+8UcOc5mkTTjgmpTxEn13vPZ1Mp6PJ8Why",
+	"HAJOM1xsbc3QH1k1FMhYtTchZHo6Wopa2pfgcYWFkC59xycXXdIGs1fQBqfJOFmctmQ1TxIhidJCQjcd",
+	"UKSQYjPGzerJZHr+gbwVkoMkH6i8Az0U6OzDA+qBzmKbH6GVCahkRVmfYR9aLbdWUTWLMspm8YO1LZfV",
+	"ZIIXbFFLNL8SpHGGvxZM9ECzY/yGnm5oS2MYDQe2+J7vxPygLMWlUSZrs1h9PFwYimarndzMltWsZuSk",
+	"5jnILsdoPj+fn51PRhOAYnQ6nWejV/nx+SgvTl8WJxN4+Wo+jRGgNNW1CuPfCnhufkwTnwnG8o1t0c8N",
+	"uoWbPzAV8eZ/GFJv6y97+8hbFIVbzs4Y54ffwpObBmL0+DIAvcou1DwgRboXLD8EPMaE7MeIL8ljp95q",
+	"8m2A/5MpxgbB+gEkIEaEyuYfXbX/VoLxJ0UB0I2P07Pz9DCw2oNKWriJO1MeTyYYGFlVV2FcHErf3ZRR",
+	"JnmIdBCy6yI5Mnd1tbrsOtHz/UAdUBWXQzusn0ovQTXzbzhsQsvVkvK6AskyA/wlzTRIRQywaWKbq5z2",
+	"ja8uYY8QublC7+X/Fh940wbNLAEMd0uPSaZr3AP2uG0rLQprvtZH/74w33v9Sx1TC+C9qmGnhBtDcR8N",
+	"YDq7mEz+K0n38jfeH+/MMlr/i9nDX0CqwULCvf3RI0HLKnzPbi1pQVS2gb26azopjrMpfTV/kR9PYmSH",
+	"jnUPztmsMqjJuhzBlivHe3Ir5p0b/gX6Hgp2g10xG/gE86UQd++A5j+A1rFCHdXaeHkVr5vCPfCdAnSz",
+	"vMdnfSH2kLKGieu+ctb7VdVzL4WBwkctyz0raB1in2NfMKeabnP+h5vrQdY1wJBNS9qDMwMIZbO20tiU",
+	"J3pPQOkqKC2HHeu26O1tIPhn3MNtXcEkO6fHxUsYvSwmxeg0n85HxjGMzrPTYjJ/kWUnxXFsHLu323cQ",
+	"A3u5oQIHm7pREOs02a+nlmw/b2Fe3F6NsoTXkun1rTFdCLbIL2sd2cy/cZMESwF5D5LImiu3i992XZjV",
+	"AadzkygR1wHRFmTozPY64M62Qfqnk+PX5kP7CE6QiRUQyokRtR2TA+TKvXKypaPD7623DKO4ONypwspO",
+	"s0xb5/m2YfJ3nz4m6WbDCic3t9OzcyIkeY//YMUHk3ibGedm3RrTxHuWg0wJzTJY6Qi/mJrxIGVGzjGt",
+	"yHefvr8dk49WR0hWUlYZBQk7eV430UYF/R5mgBn3ZajNehhhekz+c6NWRRaScr1ZrUqJDmefcSx4zYFQ",
+	"YsXVlufsJqsfDreRi7af43RybIWDcQG3lJHLrTSWWq9svwzjhYj1CClmLNPHV+djBI7BNNqsgwvkOvjt",
+	"vnF0yfF4Mp4YeYsVcLpiJt6PJ+MTW4lYosYf4eqP6IqNjPqZrxYxazYJrnL7kK7HKyWizEFpUjCpdEoY",
+	"z8rapNrE9UUQwUGNyS06CFun5GYEUjLlTN4rtnHdyX+AvjTkWDNUyUYv0XQyMX8ywbULWHRld3KZ4Ed/",
+	"d7g/aCfrBnc36GF9FDsz4WbYiIt56plRWaKRu9acuqqoXHvmOraqscXIsY6pt+jRlHEJ7um2h2nB7oFb",
+	"l6GsGbm2GdFxxFizMibYsPa1q88xjT1l5XpMOs0chJaleLB7zVa49kesCDcGodIZb36wLR+NgZpX2n2Q",
+	"3gA2IzVfW5RgC82olDNUlrUpsy1C7bJZG/DcJW5oZ11Fuhaqr0nog9+IfP0rlKhpBWrDJqZW5HRKrn+6",
+	"7absx9OXXieeq5tnSwV7Qy+7XT1x7ew2Pz71zO34IE7tY0x9m8CsGB6sQ2mFbAKCRRZoDqfW9PuvNmbQ",
+	"6R/sGFffZuwTG57v6AvLn+wcJcR2AG6cU7tzLZfe4TdBkBPBybzWRGm6bnwcwdfQCJBOujC7uEvsTSFQ",
+	"FJDpvh98hySECoz7bmFH7s9xfrePHPmO3afPPcGexpl55/oTnf92nD+N9h02jB/qO/T8t3zbj/9Htj3O",
+	"Jg1DLaNm1juAlUIlMX1QPHd+7zV+tZJwz0TdKJDxbytFHoREObCqgpxRbTzdTsdxld9Yip6T+5Pfyawi",
+	"JmUsrWNWhwvXvPVquzEa9Z4D8ECROipxpVRtNCIgBycZUJEHmwHtAifusU6GodIQUDPpZlPD2ONTM9mz",
+	"go9wCXv5/VjWtwuK+En2xSIdTsVBSZSpWxDKDSyYwkIp5T5G29JzBqZHghLXtNjLnQkWWDBdQJSI6uFh",
+	"hfHw/tExeU+zpXuBKRN7P0JOqCLf3f70o0vGOPnryLFxdMsWnOraNMrbTMkUzWaJWtLp2fm/zRJSCINy",
+	"2vLzEh7Jnz9cvh3d/vkSEx+3Jy7ydWoUtAH6eml678Oc1pkXCfqemcnYuNE2yE07HxfawTXjunoQbQkS",
+	"tqKajo4+B6xpc/iwu/98OCVv8Y/JY9TF0VEmq7H7dpyJ6ggJPPL7ROlhWbyZ5o/GLFETHAYwoRJ8DZJp",
+	"LG0YyQS2FbXLqNM8yoHmoxJ0c5Jniwc1Gl4JpdE2ubb2pazvx40ro7g5lOwepDE3bOyiZdkcSulmhLt9",
+	"bFuLfWZ3mwcDH+hxW5p2+ttwln1crpFyzVv+WfbG/W7I+kzUZY68n0PL/ri0d6FYCy0HNYi8t/M2J1R+",
+	"qaF2fZJMk4qu/ZGYkJAt+LURdQzAbttw6ur1b3SYbG9g3HHwBiFb9m5HyA2DdyLkHTKxcvaZtgXKQedl",
+	"1KCbnuS2oOjT7iXFMOxGMAz/B0gRtVY/ylX+xjf5HQaEg+N6vykU3uwSH7C/ho3/XzUM6HtZE4ZVuKUW",
+	"sIoGpwpjcil9D3hULOHgtpvUd9/1KdsssAkO2PBBqCYU+7liKKErNteT/mukln6x1vdLDXLdml/JKmwr",
+	"bcWT237C5GI6wUKIaynY3WAQ3Sn3vTN+f7NJ65qmlxhVtpWmQ9Zuq39+LXRsj+FuK0JREKsqxLXRbwcH",
+	"uMot0KCN391R91HZoGN5OPFu/clSlLmtZ4QNoiSjvIGxOUDlqyJMEvHAW2OLgdpAX28CYn61q/k6aLx9",
+	"f74h7/dGo52Op7hvi7UYbVerVvSbB3BPh0JhR+gmFDKlarvRQrnQS5Chum0pFvT9naFBC0FK8dCezGhJ",
+	"7Dtqs0TVLBsVjg46+KOwI26rb24T0uYV2w3qas+kYKUGqaL+mGBbHyaFPp0LPJkWpICmsdSmnGZUHKR7",
+	"GNlNYmtb5jRY3NPftPnVVnDlNmObvnA8/dhpYyJGSSnD0GTEAI86JWzBhXGaJKNq0OMGranDPjfdTlDT",
+	"xIrNuEL6IyNMYdP10NRhK+y3UlQdEnb0WR9K0hwK20lzAE0fxaEUxUasGL9uel56HO5E0ejb9HHPt7dy",
+	"w9sE1RsCQr0fYkbz2qV5fpgZg41A+1IVSmcvgt7gC89A0Uf0CFDabishNZmvX5OVhII9WpueJSMsMEli",
+	"3rTdyETIHOQQlWaYOLLaaHryPc6db0fdjxsN46ONz76fauT/C0x65P//vLfC/h8yHO7sw0bxrbCw0e7n",
+	"AIT9ULYRDI/mzc0Vcdh3i5u4ilBbWMU6SEgktkFweCgZh5GpCFTYddFWYU2M9B0MIGfcPIllhvBAs7L9",
+	"FbS5vqOl2yATrRBCUuwjZHYn2u4Tu0BrRYbIVLkZzRljM2F4aBp3pJVtmUTzI1dFcPraRn9R6xnXAgdD",
+	"NFJRvg7WqwWZA/5QUmkkJt1XFS2NB/F1MSUqv3Q144jK/Lpsp4mnvHTwXSiwEZ+ujWQJnscgD/6qAdXW",
+	"4YYKxA0iwFPbv6JA7GtmvYa0Xk0sHOhxxPP+YN61zhmncp0M3BuzHUs/ny2GJ/UHsLTTIOO1e4fvvcps",
+	"N9G5PSiniMGvazQUoTcsSZIf3+FnpSXQyg54fDIwoMjR5FHxsJrEbXNGGADBVgs22nJpz0E0lt1Rb1xv",
+	"e9LHuNLHkSUN8pFTpdEcdQmF1PEl7sV9vEl7a01nPvIWbypydU8JWq6JWmL5Ey+OoXzGNy/Usfs5pjNs",
+	"BdT12LU4GvfceW55J2FV0rX1Gg2XnAUyrjTQHFvOPT2d/mbcuR+Ty5azaK5OIWcccwS7zx1cNeJ2dWxu",
+	"LiRbME5LrHNKcjp5lZIcVg0k4P4kGSr1Lgt3TV8Hp8kbt1P9dqmya2V/ftuOnV44+FjCXpeNbXSW7lPl",
+	"D0t8w5e0oKVPJ9PnZrc51jfo0lqPENT1A3X/F6rWPFtKwQ2YqkQO/zom16IsZ7y1cqwZNaWGxtZ8snv1",
+	"zt9iIGqdCVujbN1kbAleF46C28La0sH2V/xNYeaF6XT3C7Gbu57S5Gwy4HUD/iDX8PBqXZbGgtWyth1u",
+	"5jz7a+ewSqpBDrnbQde3gcuafZydVeRmOHikmS7XDiUZGfsOWQNaFoClGS+w7rma1Dbpso3zLw3G2jxp",
+	"0h4xGW8rShy65xOcUPmdtnueD1JsHJ+MW6A77hq6AFdn266y/gK44Z0KH1rawXv6dNQexNipVl398DYd",
+	"XjE1IQKLdrrT7+PO/1zxGe95k7QTDe2p2v5NUTYQ11yzMhx8xoP2j53laCxFN2do3WJE0Rak23bV18RW",
+	"LZvP/qYE3LfbUXe7yn1x5X+Rog8d3+keGt3jME97GnsDCNrLAGdt4WKWGGTd05hxrMgRP2Sz1/b78MHC",
+	"Pywc+2smXUyOBeGv8BQ+Gv7Oy+k0K7SRJL6qXRuvA+5ni3M7mofXyxzq5uZrAqaxDE8Ju4s5l3VF+UgC",
+	"zc1pHmJP2ZqrKjNo6g925T0vmDapoxmtCaX2gKuElZCaTMhwD3vEy7RX5/zTxNXNhW/f+orJ8VnD7K6w",
+	"yDRU7B+Q29KTnb2vqk5BhhNlc/VJF95RRexZXCxaubDY7nalCCztfQvuoihNsd15TrM7wkwFytHOFKF5",
+	"3iYkwYaZ3Tt+Hd5uhT0/czBAvr3nyt606EfEklTTMeQ3HskhW8bIjxn34Zl8fXQOU2Szn2xZ/U9jMJuX",
+	"b+yRDDrF+rogM7S9G7uj2cPDDQv7i2B5kBqlBMaLse0Ix1MW3Khrs20xTp7C05sozPDc5s+fzbZBeMTx",
+	"589Pn5/+ZwAbb14t31wAAA==",
 }
 
 // GetSwagger returns the content of the embedded swagger specification file
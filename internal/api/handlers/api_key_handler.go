@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/services"
+	"ticket-processor/internal/validation"
+)
+
+type APIKeyHandler interface {
+	PostAdminApiKeys(c echo.Context) error
+	GetAdminApiKeys(c echo.Context) error
+	PostAdminApiKeysIdRotate(c echo.Context) error
+	DeleteAdminApiKeysId(c echo.Context) error
+}
+
+type apiKeyHandler struct {
+	log           *zap.Logger
+	apiKeyService services.APIKeyService
+}
+
+func NewAPIKeyHandler(log *zap.Logger, apiKeyService services.APIKeyService) APIKeyHandler {
+	return &apiKeyHandler{
+		log:           log,
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *apiKeyHandler) PostAdminApiKeys(c echo.Context) error {
+	h.log.Info("Creating API key")
+
+	var req models.CreateAPIKeyRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Error("Invalid JSON format", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Invalid JSON format"))
+	}
+	if err := validation.ValidateRequest(&req); err != nil {
+		h.log.Error("Validation failed", zap.Error(err))
+		response := ierrors.NewValidationErrorResponse(err)
+		response.ErrorText = "The API key is invalid."
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	key, secret, err := h.apiKeyService.Create(c.Request().Context(), req.Name, req.Scopes)
+	if err != nil {
+		h.log.Error("Error creating API key", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(http.StatusCreated, apiKeyResponse(key, secret))
+}
+
+func (h *apiKeyHandler) GetAdminApiKeys(c echo.Context) error {
+	h.log.Info("Listing API keys")
+
+	keys, err := h.apiKeyService.List(c.Request().Context())
+	if err != nil {
+		h.log.Error("Error listing API keys", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response := models.ListAPIKeysResponse{APIKeys: make([]models.APIKeyResponse, 0, len(keys))}
+	for _, key := range keys {
+		response.APIKeys = append(response.APIKeys, apiKeyResponse(key, ""))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *apiKeyHandler) PostAdminApiKeysIdRotate(c echo.Context) error {
+	h.log.Info("Rotating API key")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	key, secret, err := h.apiKeyService.Rotate(c.Request().Context(), id)
+	if err != nil {
+		h.log.Error("Error rotating API key", zap.Error(err))
+		return h.apiKeyError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, apiKeyResponse(key, secret))
+}
+
+func (h *apiKeyHandler) DeleteAdminApiKeysId(c echo.Context) error {
+	h.log.Info("Revoking API key")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	if _, err := h.apiKeyService.Revoke(c.Request().Context(), id); err != nil {
+		h.log.Error("Error revoking API key", zap.Error(err))
+		return h.apiKeyError(c, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *apiKeyHandler) apiKeyError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, ierrors.ErrAPIKeyNotFound):
+		return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No API key found for that ID"))
+	case errors.Is(err, ierrors.ErrAPIKeyRevoked):
+		return c.JSON(http.StatusConflict, ierrors.NewErrorResponse(http.StatusConflict, "This API key has been revoked"))
+	}
+	return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+}
+
+func apiKeyResponse(key models.APIKey, secret string) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		Key:       secret,
+		CreatedAt: key.CreatedAt,
+		RotatedAt: key.RotatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/services"
+	"ticket-processor/internal/storage"
+)
+
+func newTestAPIKeyHandler(t *testing.T) (APIKeyHandler, services.APIKeyService) {
+	t.Helper()
+	store, ok := storage.NewInMemoryStore().(storage.APIKeyStore)
+	require.True(t, ok)
+	svc := services.NewAPIKeyService(zap.NewNop(), store, "")
+	return NewAPIKeyHandler(zap.NewNop(), svc), svc
+}
+
+func TestAPIKeyHandler_PostAdminApiKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "created",
+			body:       `{"name":"pos","scopes":["receipts:write","receipts:read"]}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "unknown scope",
+			body:       `{"name":"pos","scopes":["receipts:delete"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","errorText":"The API key is invalid.","errors":[{"field":"Scopes[0]","message":"Key: 'CreateAPIKeyRequest.Scopes[0]' Error:Field validation for 'Scopes[0]' failed on the 'oneof' tag"}]}`,
+		},
+		{
+			name:       "no scopes",
+			body:       `{"name":"pos","scopes":[]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","errorText":"The API key is invalid.","errors":[{"field":"Scopes","message":"Key: 'CreateAPIKeyRequest.Scopes' Error:Field validation for 'Scopes' failed on the 'min' tag"}]}`,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"Invalid JSON format"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, svc := newTestAPIKeyHandler(t)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/api-keys", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			require.NoError(t, handler.PostAdminApiKeys(echo.New().NewContext(req, rec)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusCreated {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+
+			var resp models.APIKeyResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.NotEmpty(t, resp.ID)
+			assert.Equal(t, "pos", resp.Name)
+			assert.Equal(t, []string{models.ScopeReceiptsWrite, models.ScopeReceiptsRead}, resp.Scopes)
+			assert.NotContains(t, rec.Body.String(), `"hash"`)
+
+			key, err := svc.Authenticate(context.Background(), resp.Key)
+			require.NoError(t, err, "the returned key authenticates")
+			assert.Equal(t, resp.ID, key.ID)
+		})
+	}
+}
+
+func TestAPIKeyHandler_GetAdminApiKeys(t *testing.T) {
+	handler, svc := newTestAPIKeyHandler(t)
+	ctx := context.Background()
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.GetAdminApiKeys(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil), rec)))
+	assert.JSONEq(t, `{"apiKeys":[]}`, rec.Body.String())
+
+	key, _, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, handler.GetAdminApiKeys(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/api-keys", nil), rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp models.ListAPIKeysResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.APIKeys, 1)
+	assert.Equal(t, key.ID, resp.APIKeys[0].ID)
+	assert.Empty(t, resp.APIKeys[0].Key, "secrets are never listed")
+	assert.NotContains(t, rec.Body.String(), `"hash"`)
+}
+
+func TestAPIKeyHandler_PostAdminApiKeysIdRotate(t *testing.T) {
+	handler, svc := newTestAPIKeyHandler(t)
+	ctx := context.Background()
+
+	key, oldSecret, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+	revoked, _, err := svc.Create(ctx, "old", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+	_, err = svc.Revoke(ctx, revoked.ID)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "rotated", id: key.ID, wantStatus: http.StatusOK},
+		{
+			name:       "unknown",
+			id:         "unknown",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"statusText":"Not Found","message":"No API key found for that ID"}`,
+		},
+		{
+			name:       "revoked",
+			id:         revoked.ID,
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"statusText":"Conflict","message":"This API key has been revoked"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/admin/api-keys/"+tt.id+"/rotate", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+			require.NoError(t, handler.PostAdminApiKeysIdRotate(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+
+			var resp models.APIKeyResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			assert.NotNil(t, resp.RotatedAt)
+			_, err := svc.Authenticate(ctx, resp.Key)
+			assert.NoError(t, err)
+			_, err = svc.Authenticate(ctx, oldSecret)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAPIKeyHandler_DeleteAdminApiKeysId(t *testing.T) {
+	handler, svc := newTestAPIKeyHandler(t)
+	ctx := context.Background()
+
+	key, secret, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		id         string
+		wantStatus int
+	}{
+		{name: "revoked", id: key.ID, wantStatus: http.StatusNoContent},
+		{name: "revoked again", id: key.ID, wantStatus: http.StatusNoContent},
+		{name: "unknown", id: "unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/admin/api-keys/"+tt.id, nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+			require.NoError(t, handler.DeleteAdminApiKeysId(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+
+	_, err = svc.Authenticate(ctx, secret)
+	assert.Error(t, err, "a revoked key no longer authenticates")
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/services"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/validation"
+)
+
+type CustomerHandler interface {
+	GetCustomersIdBalance(c echo.Context) error
+	GetCustomersIdLedger(c echo.Context) error
+	PostCustomersIdRedemptions(c echo.Context) error
+}
+
+type customerHandler struct {
+	log             *zap.Logger
+	customerService services.CustomerService
+}
+
+func NewCustomerHandler(log *zap.Logger, customerService services.CustomerService) CustomerHandler {
+	return &customerHandler{
+		log:             log,
+		customerService: customerService,
+	}
+}
+
+func (h *customerHandler) GetCustomersIdBalance(c echo.Context) error {
+	h.log.Info("Getting customer balance")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	balance, err := h.customerService.GetBalance(c.Request().Context(), id)
+	if err != nil {
+		h.log.Error("Error getting customer balance", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, models.GetCustomerBalanceResponse{CustomerID: id, Balance: balance})
+}
+
+func (h *customerHandler) GetCustomersIdLedger(c echo.Context) error {
+	h.log.Info("Getting customer ledger")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	q := storage.LedgerQuery{Cursor: c.QueryParam("cursor")}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > storage.MaxPageSize {
+			h.log.Error("Invalid ledger query", zap.String("limit", limit))
+			return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("limit must be an integer between 1 and %d", storage.MaxPageSize)))
+		}
+		q.Limit = n
+	}
+
+	page, err := h.customerService.GetLedger(c.Request().Context(), id, q)
+	if err != nil {
+		h.log.Error("Error getting customer ledger", zap.Error(err))
+		if errors.Is(err, ierrors.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "The cursor is invalid for this customer"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	entries := page.Entries
+	if entries == nil {
+		entries = []models.LedgerEntry{}
+	}
+	return c.JSON(http.StatusOK, models.GetCustomerLedgerResponse{Entries: entries, NextCursor: page.NextCursor})
+}
+
+func (h *customerHandler) PostCustomersIdRedemptions(c echo.Context) error {
+	h.log.Info("Redeeming points")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	// Customers may only redeem from their own balance; others' are reported as
+	// missing, like others' receipts.
+	if customer, ok := restrictedCustomer(c); ok && customer != id {
+		h.log.Info("Refused redemption from another customer's balance", zap.String("id", id), zap.String("customerId", customer))
+		return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No customer found for that ID"))
+	}
+
+	var req models.RedeemPointsRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Error("Invalid JSON format", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Invalid JSON format"))
+	}
+	if err := validation.ValidateRequest(&req); err != nil {
+		h.log.Error("Validation failed", zap.Error(err))
+		response := ierrors.NewValidationErrorResponse(err)
+		response.ErrorText = "The redemption is invalid."
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	entry, err := h.customerService.Redeem(c.Request().Context(), id, req.Points, req.Description)
+	if err != nil {
+		h.log.Error("Error redeeming points", zap.Error(err))
+		if errors.Is(err, ierrors.ErrInsufficientBalance) {
+			return c.JSON(http.StatusConflict, ierrors.NewErrorResponse(http.StatusConflict, "The customer's balance is too low for this redemption"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(http.StatusCreated, entry)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+type MockCustomerService struct {
+	mock.Mock
+}
+
+func (m *MockCustomerService) GetBalance(ctx context.Context, customerID string) (int, error) {
+	args := m.Called(ctx, customerID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCustomerService) GetLedger(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error) {
+	args := m.Called(ctx, customerID, q)
+	return args.Get(0).(storage.LedgerPage), args.Error(1)
+}
+
+func (m *MockCustomerService) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	args := m.Called(ctx, customerID, points, description)
+	return args.Get(0).(models.LedgerEntry), args.Error(1)
+}
+
+func customerContext(target, id string) (echo.Context, *httptest.ResponseRecorder) {
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	return c, rec
+}
+
+func TestCustomerHandler_GetCustomersIdBalance(t *testing.T) {
+	tests := []struct {
+		name       string
+		balance    int
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "balance",
+			balance:    34,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"customerId":"alice","balance":34}`,
+		},
+		{
+			name:       "storage error",
+			err:        errors.New("storage error"),
+			wantStatus: http.StatusInternalServerError,
+			wantBody:   `{"statusText":"Internal Server Error","message":"storage error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockCustomerService)
+			service.On("GetBalance", mock.Anything, "alice").Return(tt.balance, tt.err)
+			handler := NewCustomerHandler(zap.NewNop(), service)
+
+			c, rec := customerContext("/customers/alice/balance", "alice")
+			require.NoError(t, handler.GetCustomersIdBalance(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			service.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCustomerHandler_GetCustomersIdLedger(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	page := storage.LedgerPage{
+		Entries: []models.LedgerEntry{{
+			ID:         "entry-1",
+			CustomerID: "alice",
+			Type:       models.LedgerEntryCredit,
+			ReceiptID:  "receipt-1",
+			Points:     24,
+			Balance:    24,
+			CreatedAt:  createdAt,
+		}},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name       string
+		target     string
+		wantQuery  *storage.LedgerQuery
+		page       storage.LedgerPage
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "first page",
+			target:     "/customers/alice/ledger?limit=1",
+			wantQuery:  &storage.LedgerQuery{Limit: 1},
+			page:       page,
+			wantStatus: http.StatusOK,
+			wantBody: `{"entries":[{"id":"entry-1","customerId":"alice","type":"credit","receiptId":"receipt-1",
+				"points":24,"balance":24,"createdAt":"2024-05-01T12:30:00Z"}],"nextCursor":"next"}`,
+		},
+		{
+			name:       "empty ledger",
+			target:     "/customers/alice/ledger",
+			wantQuery:  &storage.LedgerQuery{},
+			wantStatus: http.StatusOK,
+			wantBody:   `{"entries":[]}`,
+		},
+		{
+			name:       "invalid limit",
+			target:     "/customers/alice/ledger?limit=0",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"limit must be an integer between 1 and 100"}`,
+		},
+		{
+			name:       "invalid cursor",
+			target:     "/customers/alice/ledger?cursor=foreign",
+			wantQuery:  &storage.LedgerQuery{Cursor: "foreign"},
+			err:        ierrors.ErrInvalidCursor,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"The cursor is invalid for this customer"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockCustomerService)
+			if tt.wantQuery != nil {
+				service.On("GetLedger", mock.Anything, "alice", *tt.wantQuery).Return(tt.page, tt.err)
+			}
+			handler := NewCustomerHandler(zap.NewNop(), service)
+
+			c, rec := customerContext(tt.target, "alice")
+			require.NoError(t, handler.GetCustomersIdLedger(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			service.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCustomerHandler_PostCustomersIdRedemptions(t *testing.T) {
+	entry := models.LedgerEntry{
+		ID:          "entry-1",
+		CustomerID:  "alice",
+		Type:        models.LedgerEntryRedemption,
+		Points:      -20,
+		Balance:     14,
+		Description: "coffee",
+		CreatedAt:   time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		redeem     bool
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "redeemed",
+			body:       `{"points":20,"description":"coffee"}`,
+			redeem:     true,
+			wantStatus: http.StatusCreated,
+			wantBody: `{"id":"entry-1","customerId":"alice","type":"redemption","points":-20,"balance":14,
+				"description":"coffee","createdAt":"2024-05-01T12:30:00Z"}`,
+		},
+		{
+			name:       "insufficient balance",
+			body:       `{"points":20,"description":"coffee"}`,
+			redeem:     true,
+			err:        ierrors.ErrInsufficientBalance,
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"statusText":"Conflict","message":"The customer's balance is too low for this redemption"}`,
+		},
+		{
+			name:       "no points",
+			body:       `{"points":0}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","errorText":"The redemption is invalid.","errors":[{"field":"Points","message":"Key: 'RedeemPointsRequest.Points' Error:Field validation for 'Points' failed on the 'required' tag"}]}`,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"Invalid JSON format"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockCustomerService)
+			if tt.redeem {
+				service.On("Redeem", mock.Anything, "alice", 20, "coffee").Return(entry, tt.err)
+			}
+			handler := NewCustomerHandler(zap.NewNop(), service)
+
+			req := httptest.NewRequest(http.MethodPost, "/customers/alice/redemptions", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("alice")
+			require.NoError(t, handler.PostCustomersIdRedemptions(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			service.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCustomerHandler_PostCustomersIdRedemptions_CustomerToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		id         string
+		wantStatus int
+	}{
+		{name: "own balance", token: "alice", id: "alice", wantStatus: http.StatusCreated},
+		{name: "another customer's balance", token: "alice", id: "bob", wantStatus: http.StatusNotFound},
+		{name: "admin", token: "admin", id: "bob", wantStatus: http.StatusCreated},
+		{name: "API key or no auth", id: "bob", wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := new(MockCustomerService)
+			service.On("Redeem", mock.Anything, tt.id, 20, "").Return(models.LedgerEntry{ID: "entry-1", CustomerID: tt.id}, nil)
+			handler := NewCustomerHandler(zap.NewNop(), service)
+
+			req := httptest.NewRequest(http.MethodPost, "/customers/"+tt.id+"/redemptions", strings.NewReader(`{"points":20}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := serveWithBearer(t, handler.PostCustomersIdRedemptions, tt.token, req, "id", tt.id)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusNotFound {
+				assert.JSONEq(t, `{"statusText":"Not Found","message":"No customer found for that ID"}`, rec.Body.String())
+				service.AssertNotCalled(t, "Redeem", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
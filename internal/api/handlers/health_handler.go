@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"ticket-processor/internal/health"
+	"ticket-processor/internal/models"
+)
+
+type HealthHandler interface {
+	GetHealthz(c echo.Context) error
+	GetReadyz(c echo.Context) error
+}
+
+type healthHandler struct {
+	log     *zap.Logger
+	checker health.Checker
+}
+
+func NewHealthHandler(log *zap.Logger, checker health.Checker) HealthHandler {
+	return &healthHandler{
+		log:     log,
+		checker: checker,
+	}
+}
+
+// GetHealthz reports that the process is up and serving requests; it checks no dependencies.
+func (h *healthHandler) GetHealthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+func (h *healthHandler) GetReadyz(c echo.Context) error {
+	response, ready := h.checker.Ready(c.Request().Context())
+	if !ready {
+		h.log.Info("Not ready", zap.String("status", response.Status))
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+	return c.JSON(http.StatusOK, response)
+}
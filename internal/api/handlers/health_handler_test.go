@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ticket-processor/internal/health"
+	"time"
+)
+
+type healthCheckFunc func(ctx context.Context) error
+
+func (f healthCheckFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestHealthHandler_GetHealthz(t *testing.T) {
+	checker := health.NewChecker(zap.NewNop(), time.Second)
+	checker.Register("storage", healthCheckFunc(func(context.Context) error { return errors.New("disk on fire") }))
+	checker.Drain()
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/healthz", nil), rec)
+
+	require.NoError(t, NewHealthHandler(zap.NewNop(), checker).GetHealthz(c))
+	assert.Equal(t, http.StatusOK, rec.Code, "liveness does not depend on dependencies or shutdown")
+	assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestHealthHandler_GetReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheErr   error
+		draining   bool
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "ready",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ready","components":{"cache":{"status":"up"},"storage":{"status":"up"}}}`,
+		},
+		{
+			name:       "dependency down",
+			cacheErr:   errors.New("connection refused"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"not_ready","components":{"cache":{"status":"down","error":"connection refused"},"storage":{"status":"up"}}}`,
+		},
+		{
+			name:       "shutting down",
+			draining:   true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"shutting_down"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := health.NewChecker(zap.NewNop(), time.Second)
+			checker.Register("storage", healthCheckFunc(func(context.Context) error { return nil }))
+			checker.Register("cache", healthCheckFunc(func(context.Context) error { return tt.cacheErr }))
+			if tt.draining {
+				checker.Drain()
+			}
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)
+
+			require.NoError(t, NewHealthHandler(zap.NewNop(), checker).GetReadyz(c))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"io"
+	"mime"
+	"net/http"
+	"ticket-processor/internal/api/middlewares"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/validation"
+)
+
+// maxBatchLineSize bounds a single NDJSON line.
+const maxBatchLineSize = 1 << 20
+
+var errBatchTooLarge = errors.New("batch is too large")
+
+// PostReceiptsBatch processes a JSON array or an NDJSON stream of receipts as
+// they are read, so a batch is never held in memory. Every receipt is validated
+// and scored on its own; one bad receipt does not fail the batch. Should the
+// body turn out to be malformed or too large after some receipts have been
+// processed, the response lists those and why the batch was cut short.
+func (h *receiptHandler) PostReceiptsBatch(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.PostReceiptsBatch").End()
+	h.log.Info("Processing receipt batch")
+
+	ctx := c.Request().Context()
+	customer, _ := restrictedCustomer(c)
+	response := models.ProcessReceiptsBatchResponse{Results: []models.BatchReceiptResult{}}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	err := readBatch(c.Request().Body, mediaType == middlewares.MIMEApplicationNDJSON, h.maxBatchSize, func(item json.RawMessage) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result := h.processBatchItem(ctx, len(response.Results), item, customer)
+		if len(result.Errors) > 0 {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+		response.Results = append(response.Results, result)
+		return nil
+	})
+	if err != nil {
+		status, message := h.batchError(err)
+		if len(response.Results) == 0 {
+			h.log.Error("Invalid batch", zap.Error(err))
+			return c.JSON(status, ierrors.NewErrorResponse(status, message))
+		}
+		h.log.Warn("Receipt batch cut short", zap.Int("processed", len(response.Results)), zap.Error(err))
+		response.Error = message
+	} else if len(response.Results) == 0 {
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "A batch must contain at least one receipt"))
+	}
+
+	h.log.Info("Processed receipt batch", zap.Int("succeeded", response.Succeeded), zap.Int("failed", response.Failed))
+	return c.JSON(http.StatusOK, response)
+}
+
+// batchError returns the status and message a batch that failed with err is
+// refused with, should it fail before its first receipt.
+func (h *receiptHandler) batchError(err error) (int, string) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errBatchTooLarge):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("A batch may contain at most %d receipts", h.maxBatchSize)
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, fmt.Sprintf("A batch may be at most %d bytes", maxBytesErr.Limit)
+	}
+	return http.StatusBadRequest, err.Error()
+}
+
+func (h *receiptHandler) processBatchItem(ctx context.Context, index int, item json.RawMessage, customer string) models.BatchReceiptResult {
+	result := models.BatchReceiptResult{Index: index}
+
+	var receipt models.Receipt
+	if err := json.Unmarshal(item, &receipt); err != nil {
+		var fieldErr ierrors.FieldError
+		if errors.As(err, &fieldErr) {
+			result.Errors = []ierrors.FieldError{fieldErr}
+		} else {
+			result.Errors = []ierrors.FieldError{{Message: "Invalid JSON format: " + err.Error()}}
+		}
+		return result
+	}
+
+	if err := assignCustomer(&receipt, customer); err != nil {
+		result.Errors = []ierrors.FieldError{{Field: "customerId", Message: err.Error()}}
+		return result
+	}
+
+	if err := validation.ValidateReceipt(&receipt, h.tolerance); err != nil {
+		result.Errors = ierrors.NewValidationErrorResponse(err).Errors
+		return result
+	}
+
+	outcome, err := h.receiptProcessor.ProcessReceiptOutcome(ctx, receipt)
+	if errors.Is(err, ierrors.ErrDuplicateReceipt) {
+		result.Errors = []ierrors.FieldError{{Message: "This receipt has already been submitted"}}
+		return result
+	}
+	if err != nil {
+		h.log.Error("Error processing batch receipt", zap.Int("index", index), zap.Error(err))
+		result.Errors = []ierrors.FieldError{{Message: err.Error()}}
+		return result
+	}
+
+	result.ID = outcome.ID
+	if outcome.Duplicate {
+		result.Duplicate = true
+	} else {
+		result.Points = &outcome.Points
+	}
+	return result
+}
+
+// readBatch splits the body into one raw document per receipt, without
+// decoding the receipts themselves so a malformed receipt only fails its own
+// item, and passes each to process as soon as it is read. It stops at the first
+// error from process, and returns errBatchTooLarge when it finds more than
+// maxSize items.
+func readBatch(body io.Reader, ndjson bool, maxSize int, process func(item json.RawMessage) error) error {
+	count := 0
+	next := func(item json.RawMessage) error {
+		if count == maxSize {
+			return errBatchTooLarge
+		}
+		count++
+		return process(item)
+	}
+
+	if ndjson {
+		// A bufio.Scanner would hand back the partial line left over from a
+		// failed read, so lines are read by hand and only a complete line
+		// (or the last one at EOF) is processed.
+		reader := bufio.NewReader(body)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("error reading NDJSON batch: %w", err)
+			}
+			if len(line) > maxBatchLineSize {
+				return fmt.Errorf("error reading NDJSON batch: %w", bufio.ErrTooLong)
+			}
+			if len(bytes.TrimSpace(line)) > 0 {
+				if err := next(line); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return nil
+			}
+		}
+	}
+
+	dec := json.NewDecoder(body)
+	tok, err := dec.Token()
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return err
+	}
+	if err != nil || tok != json.Delim('[') {
+		return errors.New("batch must be a JSON array of receipts")
+	}
+	for dec.More() {
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			return fmt.Errorf("batch is not valid JSON: %w", err)
+		}
+		if err := next(item); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return fmt.Errorf("batch is not valid JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/api/middlewares"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/services"
+	"ticket-processor/internal/validation"
+)
+
+const batchReceipt = `{"retailer":"%s","purchaseDate":"2023-10-10","purchaseTime":"10:10","items":[{"shortDescription":"Item","price":"1.00"}],"total":"1.00"}`
+
+func batchItem(retailer string) string {
+	return strings.Replace(batchReceipt, "%s", retailer, 1)
+}
+
+func postBatch(t *testing.T, handler ReceiptHandler, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/receipts/batch", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.PostReceiptsBatch(echo.New().NewContext(req, rec)))
+	return rec
+}
+
+func TestReceiptHandler_PostReceiptsBatch(t *testing.T) {
+	wantResults := `{"results":[
+		{"index":0,"id":"id-A","points":10},
+		{"index":1,"errors":[{"field":"total","message":"amount \"1\" must match the format 0.00"}]},
+		{"index":2,"errors":[{"field":"","message":"This receipt has already been submitted"}]},
+		{"index":3,"id":"id-D","points":0},
+		{"index":4,"id":"id-A","duplicate":true}
+	],"succeeded":3,"failed":2}`
+	badTotal := strings.Replace(batchItem("B"), `"total":"1.00"`, `"total":"1"`, 1)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "JSON array",
+			contentType: echo.MIMEApplicationJSON,
+			body:        "[" + strings.Join([]string{batchItem("A"), badTotal, batchItem("C"), batchItem("D"), batchItem("E")}, ",") + "]",
+		},
+		{
+			name:        "NDJSON with blank lines",
+			contentType: middlewares.MIMEApplicationNDJSON,
+			body:        strings.Join([]string{batchItem("A"), badTotal, "", batchItem("C"), batchItem("D"), batchItem("E")}, "\n") + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockReceiptProcessor)
+			outcomes := map[string]services.ProcessOutcome{
+				"A": {ID: "id-A", Points: 10},
+				"D": {ID: "id-D"},
+				"E": {ID: "id-A", Duplicate: true},
+			}
+			for retailer, outcome := range outcomes {
+				mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+					return r.Retailer == retailer
+				})).Return(outcome, nil)
+			}
+			mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+				return r.Retailer == "C"
+			})).Return(services.ProcessOutcome{}, ierrors.ErrDuplicateReceipt)
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			rec := postBatch(t, handler, tt.contentType, tt.body)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, wantResults, rec.Body.String())
+			mockProcessor.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReceiptHandler_PostReceiptsBatch_Rejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{
+			name:        "empty array",
+			contentType: echo.MIMEApplicationJSON,
+			body:        "[]",
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"statusText":"Bad Request","message":"A batch must contain at least one receipt"}`,
+		},
+		{
+			name:        "not an array",
+			contentType: echo.MIMEApplicationJSON,
+			body:        batchItem("A"),
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"statusText":"Bad Request","message":"batch must be a JSON array of receipts"}`,
+		},
+		{
+			name:        "truncated array",
+			contentType: echo.MIMEApplicationJSON,
+			body:        "[{",
+			wantStatus:  http.StatusBadRequest,
+			wantBody:    `{"statusText":"Bad Request","message":"batch is not valid JSON: unexpected EOF"}`,
+		},
+		{
+			name:        "body too large",
+			contentType: middlewares.MIMEApplicationNDJSON,
+			body:        batchItem(strings.Repeat("A", 200)),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantBody:    `{"statusText":"Request Entity Too Large","message":"A batch may be at most 100 bytes"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), nil, nil, 2, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodPost, "/receipts/batch", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			rec := httptest.NewRecorder()
+			req.Body = http.MaxBytesReader(rec, req.Body, 100)
+			require.NoError(t, handler.PostReceiptsBatch(echo.New().NewContext(req, rec)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestReceiptHandler_PostReceiptsBatch_CutShort(t *testing.T) {
+	processed := `{"index":0,"id":"id-A","points":10},{"index":1,"id":"id-A","points":10}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantError   string
+	}{
+		{
+			name:        "too many receipts",
+			contentType: echo.MIMEApplicationJSON,
+			body:        "[" + strings.Repeat(batchItem("A")+",", 2) + batchItem("A") + "]",
+			wantError:   "A batch may contain at most 2 receipts",
+		},
+		{
+			name:        "too many NDJSON lines",
+			contentType: middlewares.MIMEApplicationNDJSON,
+			body:        strings.Repeat(batchItem("A")+"\n", 3),
+			wantError:   "A batch may contain at most 2 receipts",
+		},
+		{
+			name:        "truncated array",
+			contentType: echo.MIMEApplicationJSON,
+			body:        "[" + batchItem("A") + "," + batchItem("A") + ",",
+			wantError:   "batch is not valid JSON: unexpected end of JSON input",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockReceiptProcessor)
+			mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.Anything).Return(services.ProcessOutcome{ID: "id-A", Points: 10}, nil).Twice()
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 2, validation.TotalTolerance{})
+
+			rec := postBatch(t, handler, tt.contentType, tt.body)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, `{"results":[`+processed+`],"succeeded":2,"failed":0,"error":"`+tt.wantError+`"}`, rec.Body.String())
+			mockProcessor.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReceiptHandler_PostReceiptsBatch_ProcessesWhileReading(t *testing.T) {
+	body, w := io.Pipe()
+	processedFirst := make(chan struct{})
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+		return r.Retailer == "A"
+	})).Return(services.ProcessOutcome{ID: "id-A", Points: 10}, nil).Run(func(mock.Arguments) { close(processedFirst) })
+	mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+		return r.Retailer == "B"
+	})).Return(services.ProcessOutcome{ID: "id-B", Points: 20}, nil)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	go func() {
+		w.Write([]byte(batchItem("A") + "\n"))
+		// The second receipt is only sent once the first has been processed.
+		<-processedFirst
+		w.Write([]byte(batchItem("B") + "\n"))
+		w.Close()
+	}()
+
+	req := httptest.NewRequest(http.MethodPost, "/receipts/batch", body)
+	req.Header.Set(echo.HeaderContentType, middlewares.MIMEApplicationNDJSON)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.PostReceiptsBatch(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[
+		{"index":0,"id":"id-A","points":10},
+		{"index":1,"id":"id-B","points":20}
+	],"succeeded":2,"failed":0}`, rec.Body.String())
+}
+
+func TestReceiptHandler_PostReceiptsBatch_CustomerToken(t *testing.T) {
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ProcessReceiptOutcome", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+		return r.CustomerID == "alice"
+	})).Return(services.ProcessOutcome{ID: "id-A", Points: 10}, nil)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	foreign := strings.Replace(batchItem("B"), `"total":"1.00"`, `"total":"1.00","customerId":"bob"`, 1)
+	req := httptest.NewRequest(http.MethodPost, "/receipts/batch", strings.NewReader("["+batchItem("A")+","+foreign+"]"))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := serveWithBearer(t, handler.PostReceiptsBatch, "alice", req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"results":[
+		{"index":0,"id":"id-A","points":10},
+		{"index":1,"errors":[{"field":"customerId","message":"receipts can only be submitted for the customer the token was issued to"}]}
+	],"succeeded":1,"failed":1}`, rec.Body.String())
+	mockProcessor.AssertExpectations(t)
+}
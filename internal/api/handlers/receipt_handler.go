@@ -1,66 +1,422 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"ticket-processor/internal/api/middlewares"
 	"ticket-processor/internal/ierrors"
 	"ticket-processor/internal/models"
 	"ticket-processor/internal/services"
+	"ticket-processor/internal/storage"
 	"ticket-processor/internal/validation"
+	"time"
 )
 
 type ReceiptHandler interface {
 	PostReceiptsProcess(c echo.Context) error
+	PostReceiptsBatch(c echo.Context) error
+	GetReceipts(c echo.Context) error
+	GetReceiptsId(c echo.Context) error
 	GetReceiptsIdPoints(c echo.Context) error
+	GetReceiptsIdPointsBreakdown(c echo.Context) error
+	PostReceiptsIdReverse(c echo.Context) error
 }
 
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+var errForeignCustomer = errors.New("receipts can only be submitted for the customer the token was issued to")
+
 type receiptHandler struct {
 	log              *zap.Logger
 	receiptProcessor services.ReceiptProcessor
+	queue            services.ReceiptQueue
+	idempotency      storage.IdempotencyStore
+	maxBatchSize     int
+	tolerance        validation.TotalTolerance
 }
 
-func NewReceiptHandler(log *zap.Logger, receiptProcessor services.ReceiptProcessor) ReceiptHandler {
+// NewReceiptHandler creates the receipt handler. Submitted receipts are queued
+// and answered with 202 Accepted when queue is set, and processed before
+// responding otherwise. Idempotency-Key headers are ignored when idempotency is
+// nil. Batches may hold at most maxBatchSize receipts, and item prices must sum
+// to the total within tolerance.
+func NewReceiptHandler(log *zap.Logger, receiptProcessor services.ReceiptProcessor, queue services.ReceiptQueue, idempotency storage.IdempotencyStore, maxBatchSize int, tolerance validation.TotalTolerance) ReceiptHandler {
 	return &receiptHandler{
 		log:              log,
 		receiptProcessor: receiptProcessor,
+		queue:            queue,
+		idempotency:      idempotency,
+		maxBatchSize:     maxBatchSize,
+		tolerance:        tolerance,
 	}
 }
 
 func (h *receiptHandler) PostReceiptsProcess(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.PostReceiptsProcess").End()
 	h.log.Info("Processing receipt")
 
+	key := c.Request().Header.Get(IdempotencyKeyHeader)
+	if key == "" || h.idempotency == nil {
+		status, response := h.processReceipt(c)
+		return c.JSON(status, response)
+	}
+
+	return h.processReceiptIdempotently(c, key)
+}
+
+// processReceiptIdempotently replays the recorded response for a repeated key and
+// records the response of the first request otherwise. Server errors are not
+// recorded so the client can retry with the same key. Keys are scoped to the
+// authenticated principal, so clients cannot replay each other's responses.
+func (h *receiptHandler) processReceiptIdempotently(c echo.Context, key string) error {
+	if len(key) > maxIdempotencyKeyLength {
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Idempotency-Key is too long"))
+	}
+	storeKey := key
+	if principal, ok := middlewares.PrincipalFromContext(c); ok {
+		// Header values cannot contain a newline, so different principals and
+		// keys cannot produce the same store key.
+		storeKey = principal.ID + "\n" + key
+	}
+
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		h.log.Error("Error reading request body", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Error reading request body"))
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+
+	ctx := c.Request().Context()
+	replay, err := h.idempotency.Begin(ctx, storeKey, hex.EncodeToString(sum[:]))
+	switch {
+	case errors.Is(err, storage.ErrIdempotencyKeyMismatch):
+		return c.JSON(http.StatusUnprocessableEntity, ierrors.NewErrorResponse(http.StatusUnprocessableEntity, err.Error()))
+	case errors.Is(err, storage.ErrIdempotencyKeyInProgress):
+		return c.JSON(http.StatusConflict, ierrors.NewErrorResponse(http.StatusConflict, err.Error()))
+	case err != nil:
+		h.log.Error("Error checking idempotency key", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	case replay != nil:
+		h.log.Info("Replaying response for idempotency key", zap.String("idempotencyKey", key))
+		c.Response().Header().Set(IdempotentReplayedHeader, "true")
+		return c.JSONBlob(replay.StatusCode, replay.Body)
+	}
+
+	// The outcome must be recorded even if the client has gone away in the meantime.
+	recordCtx := context.WithoutCancel(ctx)
+
+	status, response := h.processReceipt(c)
+	payload, err := json.Marshal(response)
+	if err != nil || status >= http.StatusInternalServerError {
+		if releaseErr := h.idempotency.Release(recordCtx, storeKey); releaseErr != nil {
+			h.log.Error("Error releasing idempotency key", zap.Error(releaseErr))
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		return c.JSONBlob(status, payload)
+	}
+
+	if err := h.idempotency.Complete(recordCtx, storeKey, storage.IdempotentResponse{StatusCode: status, Body: payload}); err != nil {
+		h.log.Error("Error recording idempotent response", zap.Error(err))
+	}
+	return c.JSONBlob(status, payload)
+}
+
+func (h *receiptHandler) processReceipt(c echo.Context) (int, any) {
 	var receipt models.Receipt
 
 	if err := c.Bind(&receipt); err != nil {
 		h.log.Error("Invalid JSON format", zap.Error(err))
-		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Invalid JSON format"))
+		return http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Invalid JSON format")
 	}
 
-	if err := validation.ValidateReceipt(&receipt); err != nil {
+	customer, _ := restrictedCustomer(c)
+	if err := assignCustomer(&receipt, customer); err != nil {
+		h.log.Info("Rejected receipt for another customer", zap.String("customerId", customer))
+		return http.StatusForbidden, ierrors.NewErrorResponse(http.StatusForbidden, "Receipts can only be submitted for the customer the token was issued to")
+	}
+
+	if err := validation.ValidateReceipt(&receipt, h.tolerance); err != nil {
 		h.log.Error("Validation failed", zap.Error(err))
 
 		var validationErrs validator.ValidationErrors
 		if errors.As(err, &validationErrs) {
 			h.log.Error("Validation errors", zap.Any("errors", validationErrs))
-			return c.JSON(http.StatusBadRequest, ierrors.NewValidationErrorResponse(validationErrs))
+			return http.StatusBadRequest, ierrors.NewValidationErrorResponse(validationErrs)
 		}
 
-		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, err.Error()))
+		var fieldErrs ierrors.ValidationErrors
+		if errors.As(err, &fieldErrs) {
+			return http.StatusBadRequest, ierrors.NewValidationErrorResponse(fieldErrs)
+		}
+
+		return http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	if h.queue != nil {
+		return h.enqueueReceipt(c, receipt)
 	}
 
 	id, err := h.receiptProcessor.ProcessReceipt(c.Request().Context(), receipt)
+	if errors.Is(err, ierrors.ErrDuplicateReceipt) {
+		h.log.Info("Rejected duplicate receipt")
+		return http.StatusConflict, ierrors.NewErrorResponse(http.StatusConflict, "This receipt has already been submitted")
+	}
 	if err != nil {
 		h.log.Error("Error processing receipt", zap.Error(err))
+		return http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	return http.StatusOK, models.ProcessReceiptResponse{ID: id}
+}
+
+func (h *receiptHandler) enqueueReceipt(c echo.Context, receipt models.Receipt) (int, any) {
+	id, err := h.queue.Enqueue(c.Request().Context(), receipt)
+	if errors.Is(err, services.ErrQueueFull) || errors.Is(err, services.ErrQueueClosed) {
+		h.log.Warn("Receipt not queued", zap.Error(err))
+		c.Response().Header().Set("Retry-After", "1")
+		return http.StatusServiceUnavailable, ierrors.NewErrorResponse(http.StatusServiceUnavailable, "The processing queue is not accepting receipts; retry later")
+	}
+	if err != nil {
+		h.log.Error("Error queueing receipt", zap.Error(err))
+		return http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	h.log.Info("Queued receipt", zap.String("id", id))
+	return http.StatusAccepted, models.ReceiptJobResponse{ID: id, Status: string(services.JobPending)}
+}
+
+func (h *receiptHandler) GetReceiptsId(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.GetReceiptsId").End()
+	h.log.Info("Getting receipt")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	record, err := h.receiptProcessor.GetReceipt(c.Request().Context(), id)
+	if err != nil {
+		h.log.Error("Error getting receipt", zap.Error(err))
+		if errors.Is(err, ierrors.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+		}
 		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
-	return c.JSON(http.StatusOK, models.ProcessReceiptResponse{ID: id})
+	return c.JSON(http.StatusOK, newGetReceiptResponse(record))
+}
+
+func (h *receiptHandler) GetReceipts(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.GetReceipts").End()
+	h.log.Info("Listing receipts")
+
+	q, err := receiptQueryFromRequest(c)
+	if err != nil {
+		h.log.Error("Invalid receipt query", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	page, err := h.receiptProcessor.ListReceipts(c.Request().Context(), q)
+	if err != nil {
+		h.log.Error("Error listing receipts", zap.Error(err))
+		if errors.Is(err, ierrors.ErrInvalidCursor) {
+			return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "The cursor is invalid for this query"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response := models.ListReceiptsResponse{
+		Receipts:   make([]models.GetReceiptResponse, 0, len(page.Records)),
+		NextCursor: page.NextCursor,
+	}
+	for _, record := range page.Records {
+		response.Receipts = append(response.Receipts, newGetReceiptResponse(record))
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *receiptHandler) PostReceiptsIdReverse(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.PostReceiptsIdReverse").End()
+	h.log.Info("Reversing receipt")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	if err := h.authorizeReceipt(c, id); err != nil {
+		if errors.Is(err, ierrors.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+		}
+		h.log.Error("Error getting receipt", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	record, entry, err := h.receiptProcessor.ReverseReceipt(c.Request().Context(), id)
+	if err != nil {
+		h.log.Error("Error reversing receipt", zap.Error(err))
+		switch {
+		case errors.Is(err, ierrors.ErrNotFound):
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+		case errors.Is(err, ierrors.ErrReceiptVoided):
+			return c.JSON(http.StatusConflict, ierrors.NewErrorResponse(http.StatusConflict, "This receipt has already been reversed"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, models.ReverseReceiptResponse{ID: record.ID, VoidedAt: *record.VoidedAt, LedgerEntry: entry})
+}
+
+// restrictedCustomer returns the customer the request is limited to, if it was
+// authenticated with a customer's bearer token.
+func restrictedCustomer(c echo.Context) (string, bool) {
+	principal, ok := middlewares.PrincipalFromContext(c)
+	if !ok {
+		return "", false
+	}
+	return principal.Customer()
+}
+
+// authorizeReceipt returns ierrors.ErrNotFound if the request is limited to a
+// customer other than the owner of receipt id. Customers' requests for others'
+// receipts are answered as if the receipt did not exist so IDs cannot be probed.
+func (h *receiptHandler) authorizeReceipt(c echo.Context, id string) error {
+	customer, ok := restrictedCustomer(c)
+	if !ok {
+		return nil
+	}
+	record, err := h.receiptProcessor.GetReceipt(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	if record.Receipt.CustomerID != customer {
+		h.log.Info("Refused access to another customer's receipt", zap.String("id", id), zap.String("customerId", customer))
+		return ierrors.ErrNotFound
+	}
+	return nil
+}
+
+// assignCustomer makes customer, unless empty, the owner of receipt. It fails
+// if the receipt already names another customer.
+func assignCustomer(receipt *models.Receipt, customer string) error {
+	if customer == "" {
+		return nil
+	}
+	if receipt.CustomerID != "" && receipt.CustomerID != customer {
+		return errForeignCustomer
+	}
+	receipt.CustomerID = customer
+	return nil
+}
+
+func newGetReceiptResponse(record models.ReceiptRecord) models.GetReceiptResponse {
+	return models.GetReceiptResponse{
+		ID:           record.ID,
+		Receipt:      record.Receipt,
+		Points:       record.Points.Total,
+		ProcessedAt:  record.ProcessedAt,
+		RulesVersion: record.RulesVersion,
+		VoidedAt:     record.VoidedAt,
+	}
+}
+
+// receiptQueryFromRequest reads the GET /receipts query parameters. sort names a
+// storage.SortField, prefixed with "-" for descending order.
+func receiptQueryFromRequest(c echo.Context) (storage.ReceiptQuery, error) {
+	q := storage.ReceiptQuery{
+		Retailer: c.QueryParam("retailer"),
+		Cursor:   c.QueryParam("cursor"),
+	}
+
+	for param, dst := range map[string]*string{
+		"purchaseDateFrom": &q.PurchaseDateFrom,
+		"purchaseDateTo":   &q.PurchaseDateTo,
+	} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.DateOnly, value); err != nil {
+			return storage.ReceiptQuery{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", param)
+		}
+		*dst = value
+	}
+
+	for param, dst := range map[string]**int{
+		"minPoints": &q.MinPoints,
+		"maxPoints": &q.MaxPoints,
+	} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return storage.ReceiptQuery{}, fmt.Errorf("%s must be an integer", param)
+		}
+		*dst = &n
+	}
+
+	for param, dst := range map[string]*time.Time{
+		"processedAfter":  &q.ProcessedAfter,
+		"processedBefore": &q.ProcessedBefore,
+	} {
+		value := c.QueryParam(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return storage.ReceiptQuery{}, fmt.Errorf("%s must be an RFC 3339 date-time", param)
+		}
+		*dst = t
+	}
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		q.Descending = strings.HasPrefix(sort, "-")
+		q.SortBy = storage.SortField(strings.TrimPrefix(sort, "-"))
+		if !q.SortBy.Valid() {
+			return storage.ReceiptQuery{}, fmt.Errorf("cannot sort by %q", q.SortBy)
+		}
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > storage.MaxPageSize {
+			return storage.ReceiptQuery{}, fmt.Errorf("limit must be an integer between 1 and %d", storage.MaxPageSize)
+		}
+		q.Limit = n
+	}
+
+	return q, nil
 }
 
 func (h *receiptHandler) GetReceiptsIdPoints(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.GetReceiptsIdPoints").End()
 	h.log.Info("Getting points")
 
 	id := c.Param("id")
@@ -70,6 +426,34 @@ func (h *receiptHandler) GetReceiptsIdPoints(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
 	}
 
+	response := models.GetReceiptPointsResponse{}
+	if h.queue != nil {
+		response.Status = string(services.JobProcessed)
+		if job, ok := h.queue.Status(id); ok {
+			// Others' jobs are not found, like others' receipts.
+			if customer, ok := restrictedCustomer(c); ok && job.CustomerID != customer {
+				h.log.Info("Refused status of another customer's receipt", zap.String("id", id), zap.String("customerId", customer))
+				return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+			}
+			switch job.Status {
+			case services.JobPending:
+				return c.JSON(http.StatusAccepted, models.ReceiptJobResponse{ID: job.ID, Status: string(job.Status)})
+			case services.JobFailed:
+				return c.JSON(http.StatusUnprocessableEntity, models.ReceiptJobResponse{ID: job.ID, Status: string(job.Status), Error: job.Error})
+			default:
+				id = job.ReceiptID
+			}
+		}
+	}
+
+	if err := h.authorizeReceipt(c, id); err != nil {
+		if errors.Is(err, ierrors.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+		}
+		h.log.Error("Error getting receipt", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
 	points, err := h.receiptProcessor.GetPoints(c.Request().Context(), id)
 	if err != nil {
 		h.log.Error("Error getting points", zap.Error(err))
@@ -79,5 +463,32 @@ func (h *receiptHandler) GetReceiptsIdPoints(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
-	return c.JSON(http.StatusOK, models.GetReceiptPointsResponse{Points: points})
+	response.Points = points
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *receiptHandler) GetReceiptsIdPointsBreakdown(c echo.Context) error {
+	defer startSpan(c, "ReceiptHandler.GetReceiptsIdPointsBreakdown").End()
+	h.log.Info("Getting points breakdown")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	breakdown, err := h.receiptProcessor.GetPointsBreakdown(c.Request().Context(), id)
+	if err != nil {
+		h.log.Error("Error getting points breakdown", zap.Error(err))
+		if errors.Is(err, ierrors.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No receipt found for that ID"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, models.GetReceiptPointsBreakdownResponse{
+		Points: breakdown.Total,
+		Rules:  breakdown.Rules,
+	})
 }
@@ -2,17 +2,30 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"ticket-processor/internal/api/middlewares"
+	"ticket-processor/internal/auth"
+	"ticket-processor/internal/config"
 	"ticket-processor/internal/ierrors"
 	"ticket-processor/internal/models"
+	"ticket-processor/internal/receipt"
+	"ticket-processor/internal/services"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/tracing/tracingtest"
+	"ticket-processor/internal/validation"
+	"time"
 )
 
 type MockReceiptProcessor struct {
@@ -24,11 +37,41 @@ func (m *MockReceiptProcessor) ProcessReceipt(ctx context.Context, receipt model
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockReceiptProcessor) ProcessReceiptOutcome(ctx context.Context, receipt models.Receipt) (services.ProcessOutcome, error) {
+	args := m.Called(ctx, receipt)
+	return args.Get(0).(services.ProcessOutcome), args.Error(1)
+}
+
+func (m *MockReceiptProcessor) ProcessReceiptWithID(ctx context.Context, id string, receipt models.Receipt) (string, error) {
+	args := m.Called(ctx, id, receipt)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockReceiptProcessor) GetPoints(ctx context.Context, id string) (int, error) {
 	args := m.Called(ctx, id)
 	return args.Int(0), args.Error(1)
 }
 
+func (m *MockReceiptProcessor) GetPointsBreakdown(ctx context.Context, id string) (models.PointsBreakdown, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.PointsBreakdown), args.Error(1)
+}
+
+func (m *MockReceiptProcessor) GetReceipt(ctx context.Context, id string) (models.ReceiptRecord, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.ReceiptRecord), args.Error(1)
+}
+
+func (m *MockReceiptProcessor) ListReceipts(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error) {
+	args := m.Called(ctx, q)
+	return args.Get(0).(storage.ReceiptPage), args.Error(1)
+}
+
+func (m *MockReceiptProcessor) ReverseReceipt(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(models.ReceiptRecord), args.Get(1).(*models.LedgerEntry), args.Error(2)
+}
+
 func TestReceiptHandler_PostReceiptsProcess_Success(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(`{
@@ -45,7 +88,7 @@ func TestReceiptHandler_PostReceiptsProcess_Success(t *testing.T) {
 	mockProcessor := new(MockReceiptProcessor)
 	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("123", nil)
 
-	handler := NewReceiptHandler(zap.NewNop(), mockProcessor)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.PostReceiptsProcess(c)
 	require.NoError(t, err)
@@ -60,7 +103,7 @@ func TestReceiptHandler_PostReceiptsProcess_InvalidJSON(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor))
+	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.PostReceiptsProcess(c)
 	require.NoError(t, err)
@@ -79,7 +122,7 @@ func TestReceiptHandler_GetReceiptsIdPoints_Success(t *testing.T) {
 	mockProcessor := new(MockReceiptProcessor)
 	mockProcessor.On("GetPoints", mock.Anything, "123").Return(100, nil)
 
-	handler := NewReceiptHandler(zap.NewNop(), mockProcessor)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.GetReceiptsIdPoints(c)
 	require.NoError(t, err)
@@ -87,6 +130,31 @@ func TestReceiptHandler_GetReceiptsIdPoints_Success(t *testing.T) {
 	assert.JSONEq(t, `{"points":100}`, rec.Body.String())
 }
 
+func TestReceiptHandler_GetReceiptsIdPoints_Span(t *testing.T) {
+	recorder := tracingtest.Record(t)
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts/123/points", nil).WithContext(ctx)
+	c := e.NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	var processorSpan trace.SpanContext
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("GetPoints", mock.Anything, "123").Return(100, nil).Run(func(args mock.Arguments) {
+		processorSpan = trace.SpanContextFromContext(args.Get(0).(context.Context))
+	})
+
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+	require.NoError(t, handler.GetReceiptsIdPoints(c))
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"ReceiptHandler.GetReceiptsIdPoints", "request"}, tracingtest.Names(recorder))
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, spans[0].SpanContext(), processorSpan)
+}
+
 func TestReceiptHandler_GetReceiptsIdPoints_NotFound(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/receipts/123/points", nil)
@@ -98,7 +166,7 @@ func TestReceiptHandler_GetReceiptsIdPoints_NotFound(t *testing.T) {
 	mockProcessor := new(MockReceiptProcessor)
 	mockProcessor.On("GetPoints", mock.Anything, "123").Return(0, ierrors.ErrNotFound)
 
-	handler := NewReceiptHandler(zap.NewNop(), mockProcessor)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.GetReceiptsIdPoints(c)
 	require.NoError(t, err)
@@ -112,7 +180,7 @@ func TestReceiptHandler_GetReceiptsIdPoints_MissingID(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
-	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor))
+	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.GetReceiptsIdPoints(c)
 	require.NoError(t, err)
@@ -128,7 +196,7 @@ func TestReceiptHandler_PostReceiptsProcess_MissingFields(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	mockProcessor := new(MockReceiptProcessor)
-	handler := NewReceiptHandler(zap.NewNop(), mockProcessor)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
 
 	err := handler.PostReceiptsProcess(c)
 	require.NoError(t, err)
@@ -136,3 +204,804 @@ func TestReceiptHandler_PostReceiptsProcess_MissingFields(t *testing.T) {
 	assert.JSONEq(t, `{"statusText":"Bad Request","message":"Invalid JSON format"}`, rec.Body.String())
 
 }
+
+func TestReceiptHandler_GetReceiptsIdPointsBreakdown_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts/123/points/breakdown", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("GetPointsBreakdown", mock.Anything, "123").Return(models.PointsBreakdown{
+		Total: 16,
+		Rules: []models.RuleAward{
+			{Rule: "retailer_name", Points: 6, Reason: "6 alphanumeric characters in retailer name"},
+			{Rule: "item_pairs", Points: 10, Reason: "4 items make 2 pairs"},
+		},
+	}, nil)
+
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	err := handler.GetReceiptsIdPointsBreakdown(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"points":16,"rules":[
+		{"rule":"retailer_name","points":6,"reason":"6 alphanumeric characters in retailer name"},
+		{"rule":"item_pairs","points":10,"reason":"4 items make 2 pairs"}
+	]}`, rec.Body.String())
+}
+
+func TestReceiptHandler_GetReceiptsIdPointsBreakdown_NotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts/123/points/breakdown", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("GetPointsBreakdown", mock.Anything, "123").Return(models.PointsBreakdown{}, ierrors.ErrNotFound)
+
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	err := handler.GetReceiptsIdPointsBreakdown(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"statusText":"Not Found","message":"No receipt found for that ID"}`, rec.Body.String())
+}
+
+func TestReceiptHandler_GetReceiptsIdPointsBreakdown_Reversed(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	_, err := store.Store(context.Background(), models.ReceiptRecord{
+		ID:      "123",
+		Receipt: models.Receipt{Retailer: "Target"},
+		Points: models.PointsBreakdown{
+			Total: 16,
+			Rules: []models.RuleAward{
+				{Rule: "retailer_name", Points: 6, Reason: "6 alphanumeric characters in retailer name"},
+				{Rule: "item_pairs", Points: 10, Reason: "4 items make 2 pairs"},
+			},
+		},
+	})
+	require.NoError(t, err)
+	cache := storage.NewInMemoryCache(zap.NewNop(), 10, time.Minute)
+	t.Cleanup(cache.Close)
+	processor := services.NewReceiptProcessor(zap.NewNop(), store, cache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	handler := NewReceiptHandler(zap.NewNop(), processor, nil, nil, 10, validation.TotalTolerance{})
+
+	serve := func(h echo.HandlerFunc, method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(method, target, nil), rec)
+		c.SetParamNames("id")
+		c.SetParamValues("123")
+		require.NoError(t, h(c))
+		return rec
+	}
+
+	require.Equal(t, http.StatusOK, serve(handler.GetReceiptsIdPoints, http.MethodGet, "/receipts/123/points").Code)
+	require.Equal(t, http.StatusOK, serve(handler.PostReceiptsIdReverse, http.MethodPost, "/receipts/123/reverse").Code)
+
+	rec := serve(handler.GetReceiptsIdPoints, http.MethodGet, "/receipts/123/points")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"points":0}`, rec.Body.String())
+
+	rec = serve(handler.GetReceiptsIdPointsBreakdown, http.MethodGet, "/receipts/123/points/breakdown")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"points":0,"rules":[
+		{"rule":"retailer_name","points":0,"reason":"6 alphanumeric characters in retailer name"},
+		{"rule":"item_pairs","points":0,"reason":"4 items make 2 pairs"}
+	]}`, rec.Body.String())
+}
+
+func TestReceiptHandler_GetReceiptsId_Success(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts/123", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("GetReceipt", mock.Anything, "123").Return(models.ReceiptRecord{
+		ID: "123",
+		Receipt: models.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Items:        []models.Item{{ShortDescription: "Mountain Dew 12PK", Price: 649}},
+			Total:        649,
+		},
+		Points:       models.PointsBreakdown{Total: 12},
+		ProcessedAt:  time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		RulesVersion: "abc123",
+	}, nil)
+
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	err := handler.GetReceiptsId(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{
+		"id":"123",
+		"receipt":{
+			"retailer":"Target",
+			"purchaseDate":"2022-01-01",
+			"purchaseTime":"13:01",
+			"items":[{"shortDescription":"Mountain Dew 12PK","price":"6.49"}],
+			"total":"6.49"
+		},
+		"points":12,
+		"processedAt":"2024-05-01T12:30:00Z",
+		"rulesVersion":"abc123"
+	}`, rec.Body.String())
+}
+
+func TestReceiptHandler_GetReceiptsId_NotFound(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts/123", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("123")
+
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("GetReceipt", mock.Anything, "123").Return(models.ReceiptRecord{}, ierrors.ErrNotFound)
+
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	err := handler.GetReceiptsId(c)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"statusText":"Not Found","message":"No receipt found for that ID"}`, rec.Body.String())
+}
+
+func TestReceiptHandler_PostReceiptsIdReverse(t *testing.T) {
+	voidedAt := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	entry := &models.LedgerEntry{
+		ID:         "entry-1",
+		CustomerID: "alice",
+		Type:       models.LedgerEntryReversal,
+		ReceiptID:  "123",
+		Points:     -24,
+		Balance:    0,
+		CreatedAt:  voidedAt,
+	}
+
+	tests := []struct {
+		name       string
+		entry      *models.LedgerEntry
+		err        error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "with customer",
+			entry:      entry,
+			wantStatus: http.StatusOK,
+			wantBody: `{"id":"123","voidedAt":"2024-05-02T09:00:00Z","ledgerEntry":{"id":"entry-1","customerId":"alice",
+				"type":"reversal","receiptId":"123","points":-24,"balance":0,"createdAt":"2024-05-02T09:00:00Z"}}`,
+		},
+		{
+			name:       "without customer",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"123","voidedAt":"2024-05-02T09:00:00Z"}`,
+		},
+		{
+			name:       "not found",
+			err:        ierrors.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"statusText":"Not Found","message":"No receipt found for that ID"}`,
+		},
+		{
+			name:       "already reversed",
+			err:        ierrors.ErrReceiptVoided,
+			wantStatus: http.StatusConflict,
+			wantBody:   `{"statusText":"Conflict","message":"This receipt has already been reversed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record models.ReceiptRecord
+			if tt.err == nil {
+				record = models.ReceiptRecord{ID: "123", VoidedAt: &voidedAt}
+			}
+			mockProcessor := new(MockReceiptProcessor)
+			mockProcessor.On("ReverseReceipt", mock.Anything, "123").Return(record, tt.entry, tt.err)
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/receipts/123/reverse", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues("123")
+			require.NoError(t, handler.PostReceiptsIdReverse(c))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			mockProcessor.AssertExpectations(t)
+		})
+	}
+}
+
+const idempotentReceiptBody = `{
+	"retailer":"Retailer",
+	"purchaseDate":"2023-10-10",
+	"purchaseTime":"10:10",
+	"items":[{"shortDescription":"Item","price":"1.00"}],
+	"total":"1.00"
+}`
+
+func postReceipt(t *testing.T, handler ReceiptHandler, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.PostReceiptsProcess(e.NewContext(req, rec)))
+	return rec
+}
+
+func TestReceiptHandler_PostReceiptsProcess_IdempotencyKey(t *testing.T) {
+	otherBody := strings.Replace(idempotentReceiptBody, "Retailer", "Other", 1)
+
+	tests := []struct {
+		name          string
+		secondKey     string
+		secondBody    string
+		wantStatus    int
+		wantBody      string
+		wantReplayed  bool
+		wantProcessed int
+	}{
+		{
+			name:          "same key and body replays first response",
+			secondKey:     "key-1",
+			secondBody:    idempotentReceiptBody,
+			wantStatus:    http.StatusOK,
+			wantBody:      `{"id":"first"}`,
+			wantReplayed:  true,
+			wantProcessed: 1,
+		},
+		{
+			name:          "same key with different body is rejected",
+			secondKey:     "key-1",
+			secondBody:    otherBody,
+			wantStatus:    http.StatusUnprocessableEntity,
+			wantBody:      `{"statusText":"Unprocessable Entity","message":"idempotency key was already used with a different request body"}`,
+			wantProcessed: 1,
+		},
+		{
+			name:          "different key is processed again",
+			secondKey:     "key-2",
+			secondBody:    idempotentReceiptBody,
+			wantStatus:    http.StatusOK,
+			wantBody:      `{"id":"second"}`,
+			wantProcessed: 2,
+		},
+		{
+			name:          "no key is processed again",
+			secondBody:    idempotentReceiptBody,
+			wantStatus:    http.StatusOK,
+			wantBody:      `{"id":"second"}`,
+			wantProcessed: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockReceiptProcessor)
+			mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("first", nil).Once()
+			mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("second", nil).Once()
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, storage.NewInMemoryIdempotencyStore(time.Hour), 10, validation.TotalTolerance{})
+
+			first := postReceipt(t, handler, "key-1", idempotentReceiptBody)
+			require.Equal(t, http.StatusOK, first.Code)
+
+			rec := postReceipt(t, handler, tt.secondKey, tt.secondBody)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, tt.wantReplayed, rec.Header().Get(IdempotentReplayedHeader) == "true")
+			mockProcessor.AssertNumberOfCalls(t, "ProcessReceipt", tt.wantProcessed)
+		})
+	}
+}
+
+func TestReceiptHandler_PostReceiptsProcess_IdempotencyKeyInProgress(t *testing.T) {
+	idempotency := storage.NewInMemoryIdempotencyStore(time.Hour)
+	sum := sha256.Sum256([]byte(idempotentReceiptBody))
+	_, err := idempotency.Begin(context.Background(), "key-1", hex.EncodeToString(sum[:]))
+	require.NoError(t, err)
+	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), nil, idempotency, 10, validation.TotalTolerance{})
+
+	rec := postReceipt(t, handler, "key-1", idempotentReceiptBody)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestReceiptHandler_PostReceiptsProcess_IdempotencyKeyServerErrorIsRetryable(t *testing.T) {
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("", assert.AnError).Once()
+	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("123", nil).Once()
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, storage.NewInMemoryIdempotencyStore(time.Hour), 10, validation.TotalTolerance{})
+
+	rec := postReceipt(t, handler, "key-1", idempotentReceiptBody)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	rec = postReceipt(t, handler, "key-1", idempotentReceiptBody)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"id":"123"}`, rec.Body.String())
+}
+
+func TestReceiptHandler_PostReceiptsProcess_IdempotencyKeyPerPrincipal(t *testing.T) {
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("alice-receipt", nil).Once()
+	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("bob-receipt", nil).Once()
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, storage.NewInMemoryIdempotencyStore(time.Hour), 10, validation.TotalTolerance{})
+
+	post := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(idempotentReceiptBody))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		return serveWithBearer(t, handler.PostReceiptsProcess, token, req)
+	}
+
+	rec := post("alice")
+	assert.JSONEq(t, `{"id":"alice-receipt"}`, rec.Body.String())
+
+	rec = post("bob")
+	assert.JSONEq(t, `{"id":"bob-receipt"}`, rec.Body.String(), "another principal's key is not replayed")
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = post("alice")
+	assert.JSONEq(t, `{"id":"alice-receipt"}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	mockProcessor.AssertNumberOfCalls(t, "ProcessReceipt", 2)
+}
+
+func TestReceiptHandler_PostReceiptsProcess_DuplicateRejected(t *testing.T) {
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ProcessReceipt", mock.Anything, mock.Anything).Return("", ierrors.ErrDuplicateReceipt)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	rec := postReceipt(t, handler, "", idempotentReceiptBody)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.JSONEq(t, `{"statusText":"Conflict","message":"This receipt has already been submitted"}`, rec.Body.String())
+}
+
+func TestReceiptHandler_PostReceiptsProcess_TotalDoesNotReconcile(t *testing.T) {
+	body := strings.Replace(idempotentReceiptBody, `"total":"1.00"`, `"total":"1000.00"`, 1)
+	handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), nil, nil, 10, validation.TotalTolerance{})
+
+	rec := postReceipt(t, handler, "", body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{
+		"statusText":"Bad Request",
+		"errorText":"The receipt is invalid.",
+		"errors":[{"field":"total","message":"item prices sum to 1.00 but total is 1000.00; allowed difference is 0.00"}]
+	}`, rec.Body.String())
+}
+
+func TestReceiptHandler_GetReceipts(t *testing.T) {
+	processedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	minPoints := 10
+	page := storage.ReceiptPage{
+		Records: []models.ReceiptRecord{{
+			ID:           "123",
+			Receipt:      models.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Items: []models.Item{{ShortDescription: "Item", Price: 100}}, Total: 100},
+			Points:       models.PointsBreakdown{Total: 12},
+			ProcessedAt:  processedAt,
+			RulesVersion: "v1",
+		}},
+		NextCursor: "next",
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantQuery  *storage.ReceiptQuery
+		pageErr    error
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:  "all parameters",
+			query: "?retailer=tar&purchaseDateFrom=2022-01-01&purchaseDateTo=2022-12-31&minPoints=10&processedAfter=2024-05-01T12:00:00Z&sort=-points&limit=5&cursor=abc",
+			wantQuery: &storage.ReceiptQuery{
+				Retailer:         "tar",
+				PurchaseDateFrom: "2022-01-01",
+				PurchaseDateTo:   "2022-12-31",
+				MinPoints:        &minPoints,
+				ProcessedAfter:   processedAt,
+				SortBy:           storage.SortByPoints,
+				Descending:       true,
+				Limit:            5,
+				Cursor:           "abc",
+			},
+			wantStatus: http.StatusOK,
+			wantBody: `{"receipts":[{
+				"id":"123",
+				"receipt":{"retailer":"Target","purchaseDate":"2022-01-01","purchaseTime":"13:01","items":[{"shortDescription":"Item","price":"1.00"}],"total":"1.00"},
+				"points":12,
+				"processedAt":"2024-05-01T12:00:00Z",
+				"rulesVersion":"v1"
+			}],"nextCursor":"next"}`,
+		},
+		{
+			name:       "invalid cursor",
+			query:      "?cursor=abc",
+			wantQuery:  &storage.ReceiptQuery{Cursor: "abc"},
+			pageErr:    ierrors.ErrInvalidCursor,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"The cursor is invalid for this query"}`,
+		},
+		{
+			name:       "unknown sort field",
+			query:      "?sort=total",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"cannot sort by \"total\""}`,
+		},
+		{
+			name:       "limit out of range",
+			query:      "?limit=1000",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"limit must be an integer between 1 and 100"}`,
+		},
+		{
+			name:       "malformed date",
+			query:      "?purchaseDateTo=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"purchaseDateTo must be a date in YYYY-MM-DD format"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/receipts"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			mockProcessor := new(MockReceiptProcessor)
+			if tt.wantQuery != nil {
+				mockProcessor.On("ListReceipts", mock.Anything, *tt.wantQuery).Return(page, tt.pageErr)
+			}
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			require.NoError(t, handler.GetReceipts(c))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			mockProcessor.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReceiptHandler_GetReceipts_EmptyPage(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/receipts", nil)
+	rec := httptest.NewRecorder()
+
+	mockProcessor := new(MockReceiptProcessor)
+	mockProcessor.On("ListReceipts", mock.Anything, storage.ReceiptQuery{}).Return(storage.ReceiptPage{}, nil)
+	handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+	require.NoError(t, handler.GetReceipts(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"receipts":[]}`, rec.Body.String())
+}
+
+type MockReceiptQueue struct {
+	mock.Mock
+}
+
+func (m *MockReceiptQueue) Enqueue(ctx context.Context, receipt models.Receipt) (string, error) {
+	args := m.Called(ctx, receipt)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockReceiptQueue) Status(id string) (services.ReceiptJob, bool) {
+	args := m.Called(id)
+	return args.Get(0).(services.ReceiptJob), args.Bool(1)
+}
+
+func (m *MockReceiptQueue) Shutdown(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+func TestReceiptHandler_PostReceiptsProcess_Async(t *testing.T) {
+	tests := []struct {
+		name        string
+		enqueueID   string
+		enqueueErr  error
+		wantStatus  int
+		wantBody    string
+		wantRetryIn string
+	}{
+		{
+			name:       "queued",
+			enqueueID:  "job-1",
+			wantStatus: http.StatusAccepted,
+			wantBody:   `{"id":"job-1","status":"pending"}`,
+		},
+		{
+			name:        "queue full",
+			enqueueErr:  services.ErrQueueFull,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    `{"statusText":"Service Unavailable","message":"The processing queue is not accepting receipts; retry later"}`,
+			wantRetryIn: "1",
+		},
+		{
+			name:        "shutting down",
+			enqueueErr:  services.ErrQueueClosed,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    `{"statusText":"Service Unavailable","message":"The processing queue is not accepting receipts; retry later"}`,
+			wantRetryIn: "1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := new(MockReceiptQueue)
+			queue.On("Enqueue", mock.Anything, mock.AnythingOfType("models.Receipt")).Return(tt.enqueueID, tt.enqueueErr)
+			processor := new(MockReceiptProcessor)
+			handler := NewReceiptHandler(zap.NewNop(), processor, queue, nil, 10, validation.TotalTolerance{})
+
+			rec := postReceipt(t, handler, "", idempotentReceiptBody)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, tt.wantRetryIn, rec.Header().Get("Retry-After"))
+			processor.AssertNotCalled(t, "ProcessReceipt", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestReceiptHandler_GetReceiptsIdPoints_Async(t *testing.T) {
+	tests := []struct {
+		name       string
+		job        services.ReceiptJob
+		queued     bool
+		pointsID   string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "pending",
+			job:        services.ReceiptJob{ID: "job-1", Status: services.JobPending},
+			queued:     true,
+			wantStatus: http.StatusAccepted,
+			wantBody:   `{"id":"job-1","status":"pending"}`,
+		},
+		{
+			name:       "failed",
+			job:        services.ReceiptJob{ID: "job-1", Status: services.JobFailed, Error: "This receipt has already been submitted"},
+			queued:     true,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"id":"job-1","status":"failed","error":"This receipt has already been submitted"}`,
+		},
+		{
+			name:       "processed as duplicate",
+			job:        services.ReceiptJob{ID: "job-1", Status: services.JobProcessed, ReceiptID: "original"},
+			queued:     true,
+			pointsID:   "original",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"points":42,"status":"processed"}`,
+		},
+		{
+			name:       "processed",
+			pointsID:   "job-1",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"points":42,"status":"processed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := new(MockReceiptQueue)
+			queue.On("Status", "job-1").Return(tt.job, tt.queued)
+			processor := new(MockReceiptProcessor)
+			if tt.pointsID != "" {
+				processor.On("GetPoints", mock.Anything, tt.pointsID).Return(42, nil)
+			}
+			handler := NewReceiptHandler(zap.NewNop(), processor, queue, nil, 10, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodGet, "/receipts/job-1/points", nil)
+			rec := httptest.NewRecorder()
+			c := echo.New().NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("job-1")
+
+			require.NoError(t, handler.GetReceiptsIdPoints(c))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			processor.AssertExpectations(t)
+		})
+	}
+}
+
+type stubVerifier map[string]auth.Token
+
+func (s stubVerifier) Verify(_ context.Context, raw string) (auth.Token, error) {
+	token, ok := s[raw]
+	if !ok {
+		return auth.Token{}, auth.ErrInvalidToken
+	}
+	return token, nil
+}
+
+// serveWithBearer calls h as authenticated by the bearer token, if any:
+// "alice" and "bob" are customers' tokens and "admin" an administrator's.
+func serveWithBearer(t *testing.T, h echo.HandlerFunc, token string, req *http.Request, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	verifier := stubVerifier{
+		"alice": {Subject: "alice", Scopes: []string{models.ScopeReceiptsWrite}},
+		"bob":   {Subject: "bob", Scopes: []string{models.ScopeReceiptsWrite}},
+		"admin": {Subject: "root", Scopes: []string{models.ScopeAdmin}},
+	}
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if len(params) == 2 {
+		c.SetParamNames(params[0])
+		c.SetParamValues(params[1])
+	}
+	require.NoError(t, middlewares.BearerAuthMiddleware(zap.NewNop(), verifier)(h)(c))
+	return rec
+}
+
+func TestReceiptHandler_PostReceiptsProcess_CustomerToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		token        string
+		customerID   string
+		wantCustomer string
+		wantStatus   int
+	}{
+		{name: "token subject becomes the customer", token: "alice", wantCustomer: "alice", wantStatus: http.StatusOK},
+		{name: "matching customer", token: "alice", customerID: "alice", wantCustomer: "alice", wantStatus: http.StatusOK},
+		{name: "another customer", token: "alice", customerID: "bob", wantStatus: http.StatusForbidden},
+		{name: "admin submits for a customer", token: "admin", customerID: "bob", wantCustomer: "bob", wantStatus: http.StatusOK},
+		{name: "admin without customer", token: "admin", wantStatus: http.StatusOK},
+		{name: "API key or no auth", customerID: "bob", wantCustomer: "bob", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := idempotentReceiptBody
+			if tt.customerID != "" {
+				body = strings.Replace(body, `"total":"1.00"`, `"total":"1.00","customerId":"`+tt.customerID+`"`, 1)
+			}
+			mockProcessor := new(MockReceiptProcessor)
+			mockProcessor.On("ProcessReceipt", mock.Anything, mock.MatchedBy(func(r models.Receipt) bool {
+				return r.CustomerID == tt.wantCustomer
+			})).Return("123", nil)
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodPost, "/receipts/process", strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := serveWithBearer(t, handler.PostReceiptsProcess, tt.token, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				mockProcessor.AssertExpectations(t)
+			} else {
+				mockProcessor.AssertNotCalled(t, "ProcessReceipt", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_GetReceiptsIdPoints_CustomerToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		id         string
+		wantStatus int
+	}{
+		{name: "own receipt", token: "alice", id: "alice-receipt", wantStatus: http.StatusOK},
+		{name: "another customer's receipt", token: "alice", id: "bob-receipt", wantStatus: http.StatusNotFound},
+		{name: "receipt without customer", token: "alice", id: "anonymous-receipt", wantStatus: http.StatusNotFound},
+		{name: "unknown receipt", token: "alice", id: "unknown", wantStatus: http.StatusNotFound},
+		{name: "admin", token: "admin", id: "bob-receipt", wantStatus: http.StatusOK},
+		{name: "API key or no auth", id: "bob-receipt", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockReceiptProcessor)
+			for id, customer := range map[string]string{"alice-receipt": "alice", "bob-receipt": "bob", "anonymous-receipt": ""} {
+				mockProcessor.On("GetReceipt", mock.Anything, id).Return(models.ReceiptRecord{ID: id, Receipt: models.Receipt{CustomerID: customer}}, nil)
+				mockProcessor.On("GetPoints", mock.Anything, id).Return(100, nil)
+			}
+			mockProcessor.On("GetReceipt", mock.Anything, "unknown").Return(models.ReceiptRecord{}, ierrors.ErrNotFound)
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodGet, "/receipts/"+tt.id+"/points", nil)
+			rec := serveWithBearer(t, handler.GetReceiptsIdPoints, tt.token, req, "id", tt.id)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.JSONEq(t, `{"points":100}`, rec.Body.String())
+			} else {
+				assert.JSONEq(t, `{"statusText":"Not Found","message":"No receipt found for that ID"}`, rec.Body.String())
+				mockProcessor.AssertNotCalled(t, "GetPoints", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_PostReceiptsIdReverse_CustomerToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		id         string
+		wantStatus int
+	}{
+		{name: "own receipt", token: "alice", id: "alice-receipt", wantStatus: http.StatusOK},
+		{name: "another customer's receipt", token: "alice", id: "bob-receipt", wantStatus: http.StatusNotFound},
+		{name: "receipt without customer", token: "alice", id: "anonymous-receipt", wantStatus: http.StatusNotFound},
+		{name: "unknown receipt", token: "alice", id: "unknown", wantStatus: http.StatusNotFound},
+		{name: "admin", token: "admin", id: "bob-receipt", wantStatus: http.StatusOK},
+		{name: "API key or no auth", id: "bob-receipt", wantStatus: http.StatusOK},
+	}
+
+	voidedAt := time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := new(MockReceiptProcessor)
+			for id, customer := range map[string]string{"alice-receipt": "alice", "bob-receipt": "bob", "anonymous-receipt": ""} {
+				mockProcessor.On("GetReceipt", mock.Anything, id).Return(models.ReceiptRecord{ID: id, Receipt: models.Receipt{CustomerID: customer}}, nil)
+				mockProcessor.On("ReverseReceipt", mock.Anything, id).Return(models.ReceiptRecord{ID: id, VoidedAt: &voidedAt}, (*models.LedgerEntry)(nil), nil)
+			}
+			mockProcessor.On("GetReceipt", mock.Anything, "unknown").Return(models.ReceiptRecord{}, ierrors.ErrNotFound)
+			handler := NewReceiptHandler(zap.NewNop(), mockProcessor, nil, nil, 10, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodPost, "/receipts/"+tt.id+"/reverse", nil)
+			rec := serveWithBearer(t, handler.PostReceiptsIdReverse, tt.token, req, "id", tt.id)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				mockProcessor.AssertCalled(t, "ReverseReceipt", mock.Anything, tt.id)
+			} else {
+				assert.JSONEq(t, `{"statusText":"Not Found","message":"No receipt found for that ID"}`, rec.Body.String())
+				mockProcessor.AssertNotCalled(t, "ReverseReceipt", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReceiptHandler_GetReceiptsIdPoints_AsyncCustomerToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		job        services.ReceiptJob
+		wantStatus int
+	}{
+		{name: "own pending job", token: "alice", job: services.ReceiptJob{ID: "job-1", Status: services.JobPending, CustomerID: "alice"}, wantStatus: http.StatusAccepted},
+		{name: "another customer's pending job", token: "alice", job: services.ReceiptJob{ID: "job-1", Status: services.JobPending, CustomerID: "bob"}, wantStatus: http.StatusNotFound},
+		{name: "another customer's failed job", token: "alice", job: services.ReceiptJob{ID: "job-1", Status: services.JobFailed, Error: "storage unavailable", CustomerID: "bob"}, wantStatus: http.StatusNotFound},
+		{name: "job without customer", token: "alice", job: services.ReceiptJob{ID: "job-1", Status: services.JobPending}, wantStatus: http.StatusNotFound},
+		{name: "admin", token: "admin", job: services.ReceiptJob{ID: "job-1", Status: services.JobPending, CustomerID: "bob"}, wantStatus: http.StatusAccepted},
+		{name: "API key or no auth", job: services.ReceiptJob{ID: "job-1", Status: services.JobPending, CustomerID: "bob"}, wantStatus: http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := new(MockReceiptQueue)
+			queue.On("Status", "job-1").Return(tt.job, true)
+			handler := NewReceiptHandler(zap.NewNop(), new(MockReceiptProcessor), queue, nil, 10, validation.TotalTolerance{})
+
+			req := httptest.NewRequest(http.MethodGet, "/receipts/job-1/points", nil)
+			rec := serveWithBearer(t, handler.GetReceiptsIdPoints, tt.token, req, "id", "job-1")
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusNotFound {
+				assert.JSONEq(t, `{"statusText":"Not Found","message":"No receipt found for that ID"}`, rec.Body.String())
+			}
+		})
+	}
+}
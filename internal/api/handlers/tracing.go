@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a span named name as a child of the request's span and makes
+// it the parent of the spans started while handling the request.
+func startSpan(c echo.Context, name string) trace.Span {
+	ctx, span := otel.Tracer("ticket-processor/internal/api/handlers").Start(c.Request().Context(), name)
+	c.SetRequest(c.Request().WithContext(ctx))
+	return span
+}
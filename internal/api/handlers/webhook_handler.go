@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"net/http"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/validation"
+)
+
+type WebhookHandler interface {
+	PostAdminWebhooks(c echo.Context) error
+	GetAdminWebhooks(c echo.Context) error
+	DeleteAdminWebhooksId(c echo.Context) error
+	GetAdminWebhooksDeadLetters(c echo.Context) error
+}
+
+type webhookHandler struct {
+	log   *zap.Logger
+	store storage.WebhookStore
+}
+
+func NewWebhookHandler(log *zap.Logger, store storage.WebhookStore) WebhookHandler {
+	return &webhookHandler{
+		log:   log,
+		store: store,
+	}
+}
+
+func (h *webhookHandler) PostAdminWebhooks(c echo.Context) error {
+	h.log.Info("Registering webhook")
+
+	var req models.CreateWebhookRequest
+	if err := c.Bind(&req); err != nil {
+		h.log.Error("Invalid JSON format", zap.Error(err))
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Invalid JSON format"))
+	}
+	if err := validation.ValidateRequest(&req); err != nil {
+		h.log.Error("Validation failed", zap.Error(err))
+		response := ierrors.NewValidationErrorResponse(err)
+		response.ErrorText = "The webhook is invalid."
+		return c.JSON(http.StatusBadRequest, response)
+	}
+
+	if req.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			h.log.Error("Error generating webhook secret", zap.Error(err))
+			return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		req.Secret = hex.EncodeToString(secret)
+	}
+
+	sub, err := h.store.CreateSubscription(c.Request().Context(), models.WebhookSubscription{URL: req.URL, Secret: req.Secret})
+	if err != nil {
+		h.log.Error("Error registering webhook", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	h.log.Info("Registered webhook", zap.String("id", sub.ID), zap.String("url", sub.URL))
+	return c.JSON(http.StatusCreated, sub)
+}
+
+func (h *webhookHandler) GetAdminWebhooks(c echo.Context) error {
+	h.log.Info("Listing webhooks")
+
+	subs, err := h.store.ListSubscriptions(c.Request().Context())
+	if err != nil {
+		h.log.Error("Error listing webhooks", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response := models.ListWebhooksResponse{Webhooks: make([]models.WebhookSubscription, 0, len(subs))}
+	for _, sub := range subs {
+		sub.Secret = ""
+		response.Webhooks = append(response.Webhooks, sub)
+	}
+	return c.JSON(http.StatusOK, response)
+}
+
+func (h *webhookHandler) DeleteAdminWebhooksId(c echo.Context) error {
+	h.log.Info("Deleting webhook")
+
+	id := c.Param("id")
+
+	if id == "" {
+		h.log.Error("Missing id parameter")
+		return c.JSON(http.StatusBadRequest, ierrors.NewErrorResponse(http.StatusBadRequest, "Missing id parameter"))
+	}
+
+	if err := h.store.DeleteSubscription(c.Request().Context(), id); err != nil {
+		h.log.Error("Error deleting webhook", zap.Error(err))
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.JSON(http.StatusNotFound, ierrors.NewErrorResponse(http.StatusNotFound, "No webhook found for that ID"))
+		}
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (h *webhookHandler) GetAdminWebhooksDeadLetters(c echo.Context) error {
+	h.log.Info("Listing webhook dead letters")
+
+	letters, err := h.store.ListDeadLetters(c.Request().Context())
+	if err != nil {
+		h.log.Error("Error listing webhook dead letters", zap.Error(err))
+		return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if letters == nil {
+		letters = []models.WebhookDeadLetter{}
+	}
+	return c.JSON(http.StatusOK, models.ListWebhookDeadLettersResponse{DeadLetters: letters})
+}
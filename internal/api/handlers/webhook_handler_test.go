@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+func TestWebhookHandler_PostAdminWebhooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantSecret string
+		wantBody   string
+	}{
+		{
+			name:       "with secret",
+			body:       `{"url":"https://crm.example/hooks","secret":"0123456789abcdef"}`,
+			wantStatus: http.StatusCreated,
+			wantSecret: "0123456789abcdef",
+		},
+		{
+			name:       "generated secret",
+			body:       `{"url":"https://crm.example/hooks"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "invalid url",
+			body:       `{"url":"not a url"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","errorText":"The webhook is invalid.","errors":[{"field":"URL","message":"Key: 'CreateWebhookRequest.URL' Error:Field validation for 'URL' failed on the 'http_url' tag"}]}`,
+		},
+		{
+			name:       "short secret",
+			body:       `{"url":"https://crm.example/hooks","secret":"short"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","errorText":"The webhook is invalid.","errors":[{"field":"Secret","message":"Key: 'CreateWebhookRequest.Secret' Error:Field validation for 'Secret' failed on the 'min' tag"}]}`,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"statusText":"Bad Request","message":"Invalid JSON format"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := storage.NewInMemoryWebhookStore(10)
+			handler := NewWebhookHandler(zap.NewNop(), store)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			require.NoError(t, handler.PostAdminWebhooks(echo.New().NewContext(req, rec)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusCreated {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+				return
+			}
+
+			var sub models.WebhookSubscription
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sub))
+			assert.NotEmpty(t, sub.ID)
+			assert.Equal(t, "https://crm.example/hooks", sub.URL)
+			if tt.wantSecret != "" {
+				assert.Equal(t, tt.wantSecret, sub.Secret)
+			} else {
+				assert.Len(t, sub.Secret, 64)
+			}
+
+			stored, err := store.ListSubscriptions(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []models.WebhookSubscription{sub}, stored)
+		})
+	}
+}
+
+func TestWebhookHandler_ListAndDelete(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	sub, err := store.CreateSubscription(context.Background(), models.WebhookSubscription{URL: "https://crm.example/hooks", Secret: "0123456789abcdef"})
+	require.NoError(t, err)
+	handler := NewWebhookHandler(zap.NewNop(), store)
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.GetAdminWebhooks(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil), rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "0123456789abcdef", "secrets are never listed")
+	var list models.ListWebhooksResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Webhooks, 1)
+	assert.Equal(t, sub.ID, list.Webhooks[0].ID)
+
+	deleteWebhook := func(id string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/admin/webhooks/"+id, nil), rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		require.NoError(t, handler.DeleteAdminWebhooksId(c))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNoContent, deleteWebhook(sub.ID).Code)
+
+	rec = deleteWebhook(sub.ID)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"statusText":"Not Found","message":"No webhook found for that ID"}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, handler.GetAdminWebhooks(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil), rec)))
+	assert.JSONEq(t, `{"webhooks":[]}`, rec.Body.String())
+}
+
+func TestWebhookHandler_GetAdminWebhooksDeadLetters(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	handler := NewWebhookHandler(zap.NewNop(), store)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.GetAdminWebhooksDeadLetters(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/webhooks/dead-letters", nil), rec)))
+		return rec
+	}
+
+	assert.JSONEq(t, `{"deadLetters":[]}`, get().Body.String())
+
+	require.NoError(t, store.AddDeadLetter(context.Background(), models.WebhookDeadLetter{
+		SubscriptionID: "sub-1",
+		URL:            "https://crm.example/hooks",
+		Event:          models.WebhookEvent{ID: "evt-1", Type: models.EventReceiptProcessed, Data: json.RawMessage(`{}`)},
+		Attempts:       5,
+		LastError:      "subscriber responded with status 500",
+	}))
+
+	rec := get()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"deadLetters":[{
+		"subscriptionId":"sub-1",
+		"url":"https://crm.example/hooks",
+		"event":{"id":"evt-1","type":"receipt.processed","createdAt":"0001-01-01T00:00:00Z","data":{}},
+		"attempts":5,
+		"lastError":"subscriber responded with status 500",
+		"failedAt":"0001-01-01T00:00:00Z"
+	}]}`, rec.Body.String())
+}
@@ -0,0 +1,147 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"ticket-processor/internal/auth"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+)
+
+// APIKeyHeader carries the secret of the client's API key.
+const APIKeyHeader = "X-API-Key"
+
+const principalKey = "principal"
+
+// Principal is the client a request was authenticated as.
+type Principal struct {
+	ID     string
+	Name   string
+	Scopes []string
+	// Subject is the customer a bearer token was issued to. It is empty for API keys.
+	Subject string
+}
+
+// HasScope reports whether the principal was granted scope, which the admin
+// scope implies for every scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, models.ScopeAdmin)
+}
+
+// Customer returns the customer the principal may act for only, which is the
+// subject of a bearer token without the admin scope.
+func (p Principal) Customer() (string, bool) {
+	if p.Subject == "" || p.HasScope(models.ScopeAdmin) {
+		return "", false
+	}
+	return p.Subject, true
+}
+
+// APIKeyAuthenticator resolves the secret presented in APIKeyHeader, see
+// services.APIKeyService.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, secret string) (models.APIKey, error)
+}
+
+// APIKeyAuthMiddleware authenticates requests that present an API key and keeps
+// the key's Principal for RequireScope. Requests without a key are passed on
+// unauthenticated; requests with an unknown or revoked key are refused.
+func APIKeyAuthMiddleware(logger *zap.Logger, authenticator APIKeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			secret := c.Request().Header.Get(APIKeyHeader)
+			if secret == "" {
+				return next(c)
+			}
+
+			key, err := authenticator.Authenticate(c.Request().Context(), secret)
+			if err != nil {
+				if errors.Is(err, ierrors.ErrUnauthorized) {
+					logger.Info("Invalid API key", zap.String("path", c.Request().URL.Path))
+					return c.JSON(http.StatusUnauthorized, ierrors.NewErrorResponse(http.StatusUnauthorized, "The API key is invalid or revoked"))
+				}
+				logger.Error("Error authenticating API key", zap.Error(err))
+				return c.JSON(http.StatusInternalServerError, ierrors.NewErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			c.Set(principalKey, Principal{ID: key.ID, Name: key.Name, Scopes: key.Scopes})
+			return next(c)
+		}
+	}
+}
+
+// BearerAuthMiddleware authenticates requests with an Authorization: Bearer
+// token, keeping the token's Principal for RequireScope. Like
+// APIKeyAuthMiddleware, it passes requests without a token on and refuses
+// invalid ones.
+func BearerAuthMiddleware(logger *zap.Logger, verifier auth.Verifier) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scheme, raw, found := strings.Cut(c.Request().Header.Get(echo.HeaderAuthorization), " ")
+			if !found || !strings.EqualFold(scheme, "Bearer") {
+				return next(c)
+			}
+
+			token, err := verifier.Verify(c.Request().Context(), strings.TrimSpace(raw))
+			if err != nil {
+				logger.Info("Invalid bearer token", zap.String("path", c.Request().URL.Path), zap.Error(err))
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				message := "The bearer token is invalid or expired"
+				if errors.Is(err, auth.ErrInvalidSubject) {
+					message = "The bearer token's subject is not a valid customer ID"
+				}
+				return c.JSON(http.StatusUnauthorized, ierrors.NewErrorResponse(http.StatusUnauthorized, message))
+			}
+
+			c.Set(principalKey, Principal{ID: token.Subject, Name: token.Subject, Scopes: token.Scopes, Subject: token.Subject})
+			return next(c)
+		}
+	}
+}
+
+// RequireScope refuses requests that were not authenticated, or whose
+// principal lacks scope. It is meant to be attached to individual routes.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, ok := PrincipalFromContext(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, ierrors.NewErrorResponse(http.StatusUnauthorized, "An API key or bearer token is required"))
+			}
+			if !principal.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, ierrors.NewErrorResponse(http.StatusForbidden, "The credentials lack the "+scope+" scope"))
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireScopeOrCustomer is RequireScope, except that it also admits customers
+// holding a bearer token, which the handler must then restrict to their own
+// data, see Principal.Customer.
+func RequireScopeOrCustomer(scope string) echo.MiddlewareFunc {
+	requireScope := RequireScope(scope)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		checked := requireScope(next)
+		return func(c echo.Context) error {
+			if principal, ok := PrincipalFromContext(c); ok {
+				if _, ok := principal.Customer(); ok {
+					return next(c)
+				}
+			}
+			return checked(c)
+		}
+	}
+}
+
+// PrincipalFromContext returns the principal the request was authenticated as, if any.
+func PrincipalFromContext(c echo.Context) (Principal, bool) {
+	principal, ok := c.Get(principalKey).(Principal)
+	return principal, ok
+}
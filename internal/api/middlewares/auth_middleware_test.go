@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"ticket-processor/internal/auth"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+)
+
+type stubAuthenticator map[string]models.APIKey
+
+func (s stubAuthenticator) Authenticate(_ context.Context, secret string) (models.APIKey, error) {
+	if secret == "broken" {
+		return models.APIKey{}, errors.New("storage error")
+	}
+	key, ok := s[secret]
+	if !ok {
+		return models.APIKey{}, ierrors.ErrUnauthorized
+	}
+	return key, nil
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	authenticator := stubAuthenticator{
+		"reader": {ID: "reader-id", Name: "reader", Scopes: []string{models.ScopeReceiptsRead}},
+		"writer": {ID: "writer-id", Name: "writer", Scopes: []string{models.ScopeReceiptsWrite}},
+		"admin":  {ID: "admin-id", Name: "admin", Scopes: []string{models.ScopeAdmin}},
+	}
+
+	tests := []struct {
+		name       string
+		key        string
+		target     string
+		wantStatus int
+	}{
+		{name: "public route without key", target: "/public", wantStatus: http.StatusOK},
+		{name: "public route with invalid key", key: "unknown", target: "/public", wantStatus: http.StatusUnauthorized},
+		{name: "missing key", target: "/receipts", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", key: "unknown", target: "/receipts", wantStatus: http.StatusUnauthorized},
+		{name: "authenticator error", key: "broken", target: "/receipts", wantStatus: http.StatusInternalServerError},
+		{name: "scope granted", key: "reader", target: "/receipts", wantStatus: http.StatusOK},
+		{name: "scope missing", key: "writer", target: "/receipts", wantStatus: http.StatusForbidden},
+		{name: "admin implies every scope", key: "admin", target: "/receipts", wantStatus: http.StatusOK},
+		{name: "admin route", key: "admin", target: "/admin", wantStatus: http.StatusOK},
+		{name: "admin route without admin scope", key: "reader", target: "/admin", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(APIKeyAuthMiddleware(zap.NewNop(), authenticator))
+			ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			e.GET("/public", ok)
+			e.GET("/receipts", ok, RequireScope(models.ScopeReceiptsRead))
+			e.GET("/admin", ok, RequireScope(models.ScopeAdmin))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	e := echo.New()
+	e.Use(APIKeyAuthMiddleware(zap.NewNop(), stubAuthenticator{
+		"writer": {ID: "writer-id", Name: "writer", Scopes: []string{models.ScopeReceiptsWrite}},
+	}))
+
+	var got Principal
+	var found bool
+	e.GET("/", func(c echo.Context) error {
+		got, found = PrincipalFromContext(c)
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(APIKeyHeader, "writer")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.True(t, found)
+	assert.Equal(t, Principal{ID: "writer-id", Name: "writer", Scopes: []string{models.ScopeReceiptsWrite}}, got)
+}
+
+type stubVerifier map[string]auth.Token
+
+func (s stubVerifier) Verify(_ context.Context, raw string) (auth.Token, error) {
+	if raw == "auth0|alice" {
+		return auth.Token{}, auth.ErrInvalidSubject
+	}
+	token, ok := s[raw]
+	if !ok {
+		return auth.Token{}, auth.ErrInvalidToken
+	}
+	return token, nil
+}
+
+func TestBearerAuth(t *testing.T) {
+	verifier := stubVerifier{
+		"alice": {Subject: "alice", Scopes: []string{models.ScopeReceiptsWrite}},
+		"admin": {Subject: "root", Scopes: []string{models.ScopeAdmin}},
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		target        string
+		wantStatus    int
+		wantChallenge bool
+		wantMessage   string
+	}{
+		{name: "no token", target: "/receipts", wantStatus: http.StatusUnauthorized},
+		{name: "other scheme", authorization: "Basic YWxpY2U6c2VjcmV0", target: "/receipts", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer forged", target: "/receipts", wantStatus: http.StatusUnauthorized, wantChallenge: true},
+		{
+			name:          "subject is not a customer ID",
+			authorization: "Bearer auth0|alice",
+			target:        "/receipts",
+			wantStatus:    http.StatusUnauthorized,
+			wantChallenge: true,
+			wantMessage:   "The bearer token's subject is not a valid customer ID",
+		},
+		{name: "scope granted", authorization: "Bearer alice", target: "/receipts", wantStatus: http.StatusOK},
+		{name: "lower case scheme", authorization: "bearer alice", target: "/receipts", wantStatus: http.StatusOK},
+		{name: "scope missing", authorization: "Bearer alice", target: "/receipts/1", wantStatus: http.StatusForbidden},
+		{name: "customer may reach owned resource", authorization: "Bearer alice", target: "/receipts/1/points", wantStatus: http.StatusOK},
+		{name: "admin role", authorization: "Bearer admin", target: "/receipts/1", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			e.Use(BearerAuthMiddleware(zap.NewNop(), verifier))
+			ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+			e.POST("/receipts", ok, RequireScope(models.ScopeReceiptsWrite))
+			e.GET("/receipts/:id", ok, RequireScope(models.ScopeReceiptsRead))
+			e.GET("/receipts/:id/points", ok, RequireScopeOrCustomer(models.ScopeReceiptsRead))
+
+			method := http.MethodGet
+			if tt.target == "/receipts" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tt.target, nil)
+			if tt.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantChallenge {
+				assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get(echo.HeaderWWWAuthenticate))
+			}
+			if tt.wantMessage != "" {
+				assert.Contains(t, rec.Body.String(), tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRequireScopeOrCustomer(t *testing.T) {
+	tests := []struct {
+		name       string
+		principal  *Principal
+		wantStatus int
+	}{
+		{name: "unauthenticated", wantStatus: http.StatusUnauthorized},
+		{name: "API key with scope", principal: &Principal{ID: "key", Scopes: []string{models.ScopeReceiptsRead}}, wantStatus: http.StatusOK},
+		{name: "API key without scope", principal: &Principal{ID: "key", Scopes: []string{models.ScopeReceiptsWrite}}, wantStatus: http.StatusForbidden},
+		{name: "customer", principal: &Principal{ID: "alice", Subject: "alice"}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			if tt.principal != nil {
+				c.Set(principalKey, *tt.principal)
+			}
+			h := RequireScopeOrCustomer(models.ScopeReceiptsRead)(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			assert.NoError(t, h(c))
+			assert.Equal(t, tt.wantStatus, c.Response().Status)
+		})
+	}
+}
+
+func TestPrincipal_Customer(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal Principal
+		want      string
+		wantOK    bool
+	}{
+		{name: "API key", principal: Principal{ID: "key", Scopes: []string{models.ScopeReceiptsRead}}},
+		{name: "customer token", principal: Principal{ID: "alice", Subject: "alice", Scopes: []string{models.ScopeReceiptsWrite}}, want: "alice", wantOK: true},
+		{name: "admin token", principal: Principal{ID: "root", Subject: "root", Scopes: []string{models.ScopeAdmin}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.principal.Customer()
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -13,6 +14,10 @@ func ZapLoggerMiddleware(logger *zap.Logger) echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			start := time.Now()
 			requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+			var traceID string
+			if sc := trace.SpanContextFromContext(c.Request().Context()); sc.HasTraceID() {
+				traceID = sc.TraceID().String()
+			}
 
 			err := next(c)
 
@@ -21,6 +26,7 @@ func ZapLoggerMiddleware(logger *zap.Logger) echo.MiddlewareFunc {
 
 			log := logger.With(
 				zap.String("request_id", requestID),
+				zap.String("trace_id", traceID),
 				zap.String("method", c.Request().Method),
 				zap.String("path", c.Request().URL.Path),
 				zap.Int("status", res.Status),
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unmatchedRoute labels requests that matched no route, so arbitrary paths do
+// not each create a series.
+const unmatchedRoute = "unmatched"
+
+// RequestObserver records handled requests, see metrics.Metrics.
+type RequestObserver interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// MetricsMiddleware reports every request to observer by its route pattern and
+// the status code it will be answered with, including errors that are only
+// turned into a response by Echo's error handler.
+func MetricsMiddleware(observer RequestObserver) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+
+			route := c.Path()
+			if route == "" || route == "/*" {
+				route = unmatchedRoute
+			}
+			observer.ObserveRequest(c.Request().Method, route, responseStatus(c, err), time.Since(start))
+
+			return err
+		}
+	}
+}
+
+func responseStatus(c echo.Context, err error) int {
+	if err == nil || c.Response().Committed {
+		return c.Response().Status
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		return he.Code
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,80 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type observedRequest struct {
+	method string
+	route  string
+	status int
+}
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	requests []observedRequest
+}
+
+func (o *recordingObserver) ObserveRequest(method, route string, status int, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, observedRequest{method: method, route: route, status: status})
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   observedRequest
+	}{
+		{
+			name:   "route pattern",
+			method: http.MethodGet,
+			target: "/receipts/abc",
+			want:   observedRequest{method: http.MethodGet, route: "/receipts/:id", status: http.StatusOK},
+		},
+		{
+			name:   "handler response",
+			method: http.MethodPost,
+			target: "/receipts/process",
+			want:   observedRequest{method: http.MethodPost, route: "/receipts/process", status: http.StatusBadRequest},
+		},
+		{
+			name:   "returned error",
+			method: http.MethodGet,
+			target: "/fail",
+			want:   observedRequest{method: http.MethodGet, route: "/fail", status: http.StatusServiceUnavailable},
+		},
+		{
+			name:   "unknown path",
+			method: http.MethodGet,
+			target: "/no/such/path",
+			want:   observedRequest{method: http.MethodGet, route: unmatchedRoute, status: http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observer := &recordingObserver{}
+			e := echo.New()
+			e.Use(MetricsMiddleware(observer))
+			e.GET("/receipts/:id", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			e.POST("/receipts/process", func(c echo.Context) error { return c.NoContent(http.StatusBadRequest) })
+			e.GET("/fail", func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable) })
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+
+			assert.Equal(t, tt.want.status, rec.Code)
+			assert.Equal(t, []observedRequest{tt.want}, observer.requests)
+		})
+	}
+}
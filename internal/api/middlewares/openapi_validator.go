@@ -0,0 +1,133 @@
+package middlewares
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/legacy"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"ticket-processor/internal/ierrors"
+)
+
+// MIMEApplicationNDJSON is newline-delimited JSON, one document per line.
+const MIMEApplicationNDJSON = "application/x-ndjson"
+
+func init() {
+	// NDJSON bodies are passed through as raw bytes; their documents are validated by the handler.
+	openapi3filter.RegisterBodyDecoder(MIMEApplicationNDJSON, openapi3filter.FileBodyDecoder)
+}
+
+// StreamedRequestBody is the extension marking operations whose handler reads
+// the request body as a stream. Their body is not validated, which would read
+// it into memory; the handler validates what it reads.
+const StreamedRequestBody = "x-streamed-request-body"
+
+// OpenAPIValidatorMiddleware validates every request that matches an operation
+// in swagger against its parameters and request body schema, except bodies
+// marked with StreamedRequestBody. Requests for paths the spec does not
+// describe (Swagger UI, health checks) are passed through.
+func OpenAPIValidatorMiddleware(logger *zap.Logger, swagger *openapi3.T) (echo.MiddlewareFunc, error) {
+	// Match on path only, whatever host the server is reached through.
+	spec := *swagger
+	spec.Servers = nil
+
+	router, err := legacy.NewRouter(&spec)
+	if err != nil {
+		return nil, fmt.Errorf("error building OpenAPI router: %w", err)
+	}
+
+	options := &openapi3filter.Options{
+		MultiError:         true,
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+	streamedOptions := *options
+	streamedOptions.ExcludeRequestBody = true
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+
+			route, pathParams, err := router.FindRoute(req)
+			if err != nil {
+				// Unknown paths and methods are left to Echo's own routing.
+				var routeErr *routers.RouteError
+				if errors.As(err, &routeErr) {
+					return next(c)
+				}
+				return err
+			}
+
+			input := &openapi3filter.RequestValidationInput{
+				Request:    req,
+				PathParams: pathParams,
+				Route:      route,
+				Options:    options,
+			}
+			if streamed, _ := route.Operation.Extensions[StreamedRequestBody].(bool); streamed {
+				input.Options = &streamedOptions
+			}
+			err = openapi3filter.ValidateRequest(req.Context(), input)
+			if err != nil {
+				fieldErrors := openAPIFieldErrors(err)
+				logger.Info("Request does not match the API spec",
+					zap.String("path", req.URL.Path),
+					zap.Any("errors", fieldErrors),
+				)
+				return c.JSON(http.StatusBadRequest, ierrors.NewValidationErrorResponse(fieldErrors))
+			}
+
+			return next(c)
+		}
+	}, nil
+}
+
+// openAPIFieldErrors flattens the errors reported by openapi3filter into one
+// FieldError per offending parameter or body field. It switches on concrete
+// types because errors.As would see through RequestError to the errors it wraps.
+func openAPIFieldErrors(err error) ierrors.ValidationErrors {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var fieldErrors ierrors.ValidationErrors
+		for _, inner := range e {
+			fieldErrors = append(fieldErrors, openAPIFieldErrors(inner)...)
+		}
+		return fieldErrors
+	case *openapi3filter.RequestError:
+		if e.Parameter != nil {
+			message := requestErrorMessage(e)
+			var schemaErr *openapi3.SchemaError
+			if errors.As(e.Err, &schemaErr) {
+				message = schemaErr.Reason
+			}
+			return ierrors.ValidationErrors{{Field: e.Parameter.Name, Message: message}}
+		}
+		switch e.Err.(type) {
+		case openapi3.MultiError, *openapi3.SchemaError:
+			return openAPIFieldErrors(e.Err)
+		}
+		return ierrors.ValidationErrors{{Message: requestErrorMessage(e)}}
+	case *openapi3.SchemaError:
+		return ierrors.ValidationErrors{{
+			Field:   strings.Join(e.JSONPointer(), "."),
+			Message: e.Reason,
+		}}
+	}
+
+	return ierrors.ValidationErrors{{Message: err.Error()}}
+}
+
+func requestErrorMessage(reqErr *openapi3filter.RequestError) string {
+	if reqErr.Reason != "" {
+		return reqErr.Reason
+	}
+	if reqErr.Err != nil {
+		return reqErr.Err.Error()
+	}
+	return reqErr.Error()
+}
@@ -0,0 +1,121 @@
+package middlewares_test
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/api"
+	"ticket-processor/internal/api/middlewares"
+)
+
+const validReceipt = `{
+	"retailer":"M&M Corner Market",
+	"purchaseDate":"2022-03-20",
+	"purchaseTime":"14:33",
+	"items":[{"shortDescription":"Gatorade","price":"2.25"}],
+	"total":"2.25"
+}`
+
+func TestOpenAPIValidatorMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "valid receipt reaches the handler with its body",
+			method:     http.MethodPost,
+			path:       "/receipts/process",
+			body:       validReceipt,
+			wantStatus: http.StatusOK,
+			wantBody:   validReceipt,
+		},
+		{
+			name:       "pattern and minItems violations",
+			method:     http.MethodPost,
+			path:       "/receipts/process",
+			body:       `{"retailer":"Tar!get","purchaseDate":"2022-01-01","purchaseTime":"13:01","items":[],"total":"6.4"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"statusText":"Bad Request","errorText":"The receipt is invalid.","errors":[
+				{"field":"items","message":"minimum number of items is 1"},
+				{"field":"retailer","message":"string doesn't match the regular expression \"^[\\w\\s\\-&]+$\""},
+				{"field":"total","message":"string doesn't match the regular expression \"^\\d+\\.\\d{2}$\""}
+			]}`,
+		},
+		{
+			name:       "nested item field",
+			method:     http.MethodPost,
+			path:       "/receipts/process",
+			body:       strings.Replace(validReceipt, `"2.25"}]`, `"2.5"}]`, 1),
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"statusText":"Bad Request","errorText":"The receipt is invalid.","errors":[
+				{"field":"items.0.price","message":"string doesn't match the regular expression \"^\\d+\\.\\d{2}$\""}
+			]}`,
+		},
+		{
+			name:       "path parameter",
+			method:     http.MethodGet,
+			path:       "/receipts/abc%20d/points",
+			wantStatus: http.StatusBadRequest,
+			wantBody: `{"statusText":"Bad Request","errorText":"The receipt is invalid.","errors":[
+				{"field":"id","message":"string doesn't match the regular expression \"^\\S+$\""}
+			]}`,
+		},
+		{
+			name:       "streamed body is left to the handler",
+			method:     http.MethodPost,
+			path:       "/receipts/batch",
+			body:       `{"not":"an array"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"not":"an array"}`,
+		},
+		{
+			name:       "path outside the spec is passed through",
+			method:     http.MethodGet,
+			path:       "/swagger.json",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "method outside the spec is passed through",
+			method:     http.MethodDelete,
+			path:       "/receipts/process",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	swagger, err := api.GetSwagger()
+	require.NoError(t, err)
+	validator, err := middlewares.OpenAPIValidatorMiddleware(zap.NewNop(), swagger)
+	require.NoError(t, err)
+
+	handler := validator(func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.Blob(http.StatusOK, echo.MIMEApplicationJSON, body)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+
+			require.NoError(t, handler(echo.New().NewContext(req, rec)))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
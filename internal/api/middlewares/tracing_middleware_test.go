@@ -0,0 +1,129 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"ticket-processor/internal/tracing/tracingtest"
+)
+
+const (
+	incomingTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incomingSpanID  = "00f067aa0ba902b7"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		traceparent string
+		wantName    string
+		wantStatus  int
+		wantError   bool
+	}{
+		{
+			name:        "continues incoming trace",
+			method:      http.MethodGet,
+			target:      "/receipts/abc",
+			traceparent: "00-" + incomingTraceID + "-" + incomingSpanID + "-01",
+			wantName:    "GET /receipts/:id",
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:       "new trace",
+			method:     http.MethodGet,
+			target:     "/receipts/abc",
+			wantName:   "GET /receipts/:id",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "server error",
+			method:     http.MethodGet,
+			target:     "/fail",
+			wantName:   "GET /fail",
+			wantStatus: http.StatusServiceUnavailable,
+			wantError:  true,
+		},
+		{
+			name:       "unknown path",
+			method:     http.MethodGet,
+			target:     "/no/such/path",
+			wantName:   "GET " + unmatchedRoute,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracingtest.Record(t)
+			var handlerSpan trace.SpanContext
+			e := echo.New()
+			e.Use(TracingMiddleware())
+			e.GET("/receipts/:id", func(c echo.Context) error {
+				handlerSpan = trace.SpanContextFromContext(c.Request().Context())
+				return c.NoContent(http.StatusOK)
+			})
+			e.GET("/fail", func(c echo.Context) error { return echo.NewHTTPError(http.StatusServiceUnavailable) })
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			if tt.traceparent != "" {
+				req.Header.Set("traceparent", tt.traceparent)
+			}
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			spans := recorder.Ended()
+			require.Len(t, spans, 1)
+			span := spans[0]
+			assert.Equal(t, tt.wantName, span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Contains(t, span.Attributes(), semconv.HTTPResponseStatusCode(tt.wantStatus))
+			if tt.wantError {
+				assert.Equal(t, codes.Error, span.Status().Code)
+			} else {
+				assert.Equal(t, codes.Unset, span.Status().Code)
+			}
+			if tt.traceparent != "" {
+				assert.Equal(t, incomingTraceID, span.SpanContext().TraceID().String())
+				assert.Equal(t, incomingSpanID, span.Parent().SpanID().String())
+				assert.True(t, span.Parent().IsRemote())
+			} else {
+				assert.False(t, span.Parent().IsValid())
+			}
+			if handlerSpan.IsValid() {
+				assert.Equal(t, span.SpanContext(), handlerSpan, "handlers run inside the request span")
+			}
+		})
+	}
+}
+
+func TestZapLoggerMiddleware_TraceID(t *testing.T) {
+	tracingtest.Record(t)
+	core, logs := observer.New(zap.InfoLevel)
+	e := echo.New()
+	e.Use(TracingMiddleware(), ZapLoggerMiddleware(zap.New(core)))
+	e.POST("/receipts/process", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/receipts/process", nil)
+	req.Header.Set(echo.HeaderXRequestID, "request-1")
+	req.Header.Set("traceparent", "00-"+incomingTraceID+"-"+incomingSpanID+"-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "request-1", fields["request_id"])
+	assert.Equal(t, incomingTraceID, fields["trace_id"])
+}
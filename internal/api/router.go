@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	openapiMW "github.com/go-openapi/runtime/middleware"
 	"github.com/labstack/echo/v4"
 	echoMW "github.com/labstack/echo/v4/middleware"
@@ -8,20 +9,60 @@ import (
 	"net/http"
 	"ticket-processor/internal/api/handlers"
 	"ticket-processor/internal/api/middlewares"
+	"ticket-processor/internal/auth"
 	"ticket-processor/internal/config"
+	"ticket-processor/internal/metrics"
+	"ticket-processor/internal/models"
 )
 
-func SetupRouter(log *zap.Logger, cfg *config.Config, h handlers.ReceiptHandler) *echo.Echo {
+// batchPath is the route of POST /receipts/batch, which streams its body.
+const batchPath = "/receipts/batch"
+
+// SetupRouter registers every route. When authentication is enabled, each API
+// route requires the scope it is registered with; requests are checked against
+// the OpenAPI spec only after that, so unauthorized clients learn nothing from
+// validation errors. Bearer tokens are accepted if tokens is not nil.
+func SetupRouter(log *zap.Logger, cfg *config.Config, h handlers.ReceiptHandler, wh handlers.WebhookHandler, ch handlers.CustomerHandler, hh handlers.HealthHandler, akh handlers.APIKeyHandler, apiKeys middlewares.APIKeyAuthenticator, tokens auth.Verifier, m *metrics.Metrics) (*echo.Echo, error) {
+	swagger, err := GetSwagger()
+	if err != nil {
+		return nil, fmt.Errorf("error loading Swagger spec: %w", err)
+	}
+	validator, err := middlewares.OpenAPIValidatorMiddleware(log, swagger)
+	if err != nil {
+		return nil, err
+	}
+
 	e := echo.New()
 
 	e.Use(echoMW.Logger())
 	e.Use(echoMW.RequestID())
+	e.Use(middlewares.TracingMiddleware())
+	e.Use(middlewares.MetricsMiddleware(m))
 	e.Use(echoMW.Recover())
 	e.Use(echoMW.TimeoutWithConfig(echoMW.TimeoutConfig{
 		Timeout: cfg.HTTPServer.Timeout,
-		Skipper: echoMW.DefaultSkipper,
+		// Batches get their own timeout, see below.
+		Skipper: func(c echo.Context) bool { return c.Path() == batchPath },
 	}))
 	e.Use(middlewares.ZapLoggerMiddleware(log))
+	if cfg.Auth.Enabled {
+		e.Use(middlewares.APIKeyAuthMiddleware(log, apiKeys))
+		if tokens != nil {
+			e.Use(middlewares.BearerAuthMiddleware(log, tokens))
+		}
+	}
+
+	guard := func(require func(string) echo.MiddlewareFunc, scope string) []echo.MiddlewareFunc {
+		if !cfg.Auth.Enabled {
+			return []echo.MiddlewareFunc{validator}
+		}
+		return []echo.MiddlewareFunc{require(scope), validator}
+	}
+	read := guard(middlewares.RequireScope, models.ScopeReceiptsRead)
+	write := guard(middlewares.RequireScope, models.ScopeReceiptsWrite)
+	admin := guard(middlewares.RequireScope, models.ScopeAdmin)
+	// Customers may read the points of their own receipts, see the handler.
+	readOwn := guard(middlewares.RequireScopeOrCustomer, models.ScopeReceiptsRead)
 
 	opts := openapiMW.SwaggerUIOpts{
 		SpecURL: "/swagger.json",
@@ -41,12 +82,50 @@ func SetupRouter(log *zap.Logger, cfg *config.Config, h handlers.ReceiptHandler)
 
 	e.GET("/swagger/*", echo.WrapHandler(swaggerHandler))
 
+	e.GET("/metrics", echo.WrapHandler(m.Handler()))
+	e.GET("/healthz", hh.GetHealthz)
+	e.GET("/readyz", hh.GetReadyz)
+
 	e.GET("/", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, map[string]string{"message": "Welcome to the Ticket Processor API"})
 	})
 
-	e.POST("/receipts/process", h.PostReceiptsProcess)
-	e.GET("/receipts/:id/points", h.GetReceiptsIdPoints)
+	e.POST("/receipts/process", h.PostReceiptsProcess, write...)
+	batch := append([]echo.MiddlewareFunc{
+		echoMW.TimeoutWithConfig(echoMW.TimeoutConfig{Timeout: cfg.Batch.Timeout}),
+		limitBody(cfg.Batch.MaxBodySize),
+	}, write...)
+	e.POST(batchPath, h.PostReceiptsBatch, batch...)
+	e.GET("/receipts", h.GetReceipts, read...)
+	e.GET("/receipts/:id", h.GetReceiptsId, read...)
+	e.GET("/receipts/:id/points", h.GetReceiptsIdPoints, readOwn...)
+	e.GET("/receipts/:id/points/breakdown", h.GetReceiptsIdPointsBreakdown, read...)
+	e.POST("/receipts/:id/reverse", h.PostReceiptsIdReverse, write...)
+
+	e.GET("/customers/:id/balance", ch.GetCustomersIdBalance, read...)
+	e.GET("/customers/:id/ledger", ch.GetCustomersIdLedger, read...)
+	e.POST("/customers/:id/redemptions", ch.PostCustomersIdRedemptions, write...)
+
+	e.POST("/admin/webhooks", wh.PostAdminWebhooks, admin...)
+	e.GET("/admin/webhooks", wh.GetAdminWebhooks, admin...)
+	e.GET("/admin/webhooks/dead-letters", wh.GetAdminWebhooksDeadLetters, admin...)
+	e.DELETE("/admin/webhooks/:id", wh.DeleteAdminWebhooksId, admin...)
+
+	e.POST("/admin/api-keys", akh.PostAdminApiKeys, admin...)
+	e.GET("/admin/api-keys", akh.GetAdminApiKeys, admin...)
+	e.POST("/admin/api-keys/:id/rotate", akh.PostAdminApiKeysIdRotate, admin...)
+	e.DELETE("/admin/api-keys/:id", akh.DeleteAdminApiKeysId, admin...)
 
-	return e
+	return e, nil
+}
+
+// limitBody fails reads of request bodies beyond limit bytes with an
+// *http.MaxBytesError, for handlers that read the body as a stream.
+func limitBody(limit int64) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, limit)
+			return next(c)
+		}
+	}
 }
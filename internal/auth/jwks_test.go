@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParseJWKS_SkipsUnusableKeys(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	encryption := rsaKey.jwk()
+	encryption["kid"] = "enc"
+	encryption["use"] = "enc"
+	data, err := json.Marshal(map[string]any{"keys": []any{
+		rsaKey.jwk(),
+		encryption,
+		map[string]string{"kty": "EC", "kid": "p384", "crv": "P-384", "x": "AA", "y": "AA"},
+		map[string]string{"kty": "oct", "kid": "hmac", "k": "c2VjcmV0"},
+	}})
+	require.NoError(t, err)
+
+	keys, skipped, err := parseJWKS(data)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Contains(t, keys, "rsa")
+	assert.Equal(t, []string{"enc", "p384", "hmac"}, skipped)
+}
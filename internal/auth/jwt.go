@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"slices"
+	"sync"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/validation"
+	"time"
+)
+
+// ErrInvalidToken is returned, wrapping the reason, for tokens that must be refused.
+var ErrInvalidToken = errors.New("invalid token")
+
+// ErrInvalidSubject is returned for otherwise valid customer tokens whose
+// subject cannot be used as a customer ID, see validation.ValidCustomerID. It
+// wraps ErrInvalidToken.
+var ErrInvalidSubject = fmt.Errorf("%w: the sub claim is not a valid customer ID", ErrInvalidToken)
+
+// Token is a verified bearer token.
+type Token struct {
+	// Subject is the token's sub claim, the customer it was issued to.
+	Subject string
+	Scopes  []string
+}
+
+// Admin reports whether the token was issued to an administrator.
+func (t Token) Admin() bool {
+	return slices.Contains(t.Scopes, models.ScopeAdmin)
+}
+
+type Verifier interface {
+	// Verify checks the signature, expiry and, if configured, issuer and
+	// audience of a compact JWT. It returns an error wrapping ErrInvalidToken
+	// if the token must be refused.
+	Verify(ctx context.Context, raw string) (Token, error)
+}
+
+type verifier struct {
+	log    *zap.Logger
+	cfg    config.JWT
+	source KeySource
+	parser *jwt.Parser
+
+	// refreshMu serializes reloads so a burst of tokens signed with a new key
+	// reads the JWKS once.
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]crypto.PublicKey
+	loadedAt  time.Time
+}
+
+// NewVerifier creates a Verifier for tokens signed by the keys source returns.
+// The keys are loaded before it returns, so a missing or malformed JWKS is
+// reported at startup.
+func NewVerifier(ctx context.Context, log *zap.Logger, cfg config.JWT, source KeySource) (Verifier, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(cfg.Leeway),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	v := &verifier{
+		log:    log,
+		cfg:    cfg,
+		source: source,
+		parser: jwt.NewParser(opts...),
+	}
+	if err := v.load(ctx); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *verifier) Verify(ctx context.Context, raw string) (Token, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	})
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return Token{}, fmt.Errorf("%w: the sub claim is required", ErrInvalidToken)
+	}
+
+	if slices.Contains(stringsClaim(claims[v.cfg.RolesClaim]), v.cfg.AdminRole) {
+		return Token{Subject: subject, Scopes: []string{models.ScopeAdmin}}, nil
+	}
+	// The subject of a customer token becomes the customerId of their receipts.
+	if !validation.ValidCustomerID(subject) {
+		return Token{}, fmt.Errorf("%w: %q", ErrInvalidSubject, subject)
+	}
+	return Token{Subject: subject, Scopes: slices.Clone(v.cfg.Scopes)}, nil
+}
+
+// key returns the key with ID kid, reading the JWKS again if it is unknown and
+// was last read more than RefreshInterval ago. A token without a kid may use
+// the only key of a single-key JWKS.
+func (v *verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+
+	v.mu.RLock()
+	stale := time.Since(v.loadedAt) >= v.cfg.RefreshInterval
+	v.mu.RUnlock()
+	if stale {
+		if err := v.load(ctx); err != nil {
+			v.log.Warn("Error reloading JWKS", zap.Error(err))
+		} else if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (v *verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+func (v *verifier) load(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, v.cfg.Timeout)
+	defer cancel()
+
+	data, err := v.source(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading JWKS: %w", err)
+	}
+	keys, skipped, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		v.log.Info("Skipped JWKS keys that cannot verify RS256 or ES256 signatures", zap.Strings("kids", skipped))
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.keys = keys
+	v.loadedAt = time.Now()
+	v.log.Info("Loaded JWKS", zap.Int("keys", len(keys)))
+	return nil
+}
+
+// stringsClaim reads a claim holding a string or a list of strings.
+func stringsClaim(claim any) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []any:
+		values := make([]string, 0, len(c))
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	key    any
+}
+
+func newRSAKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return signingKey{kid: kid, method: jwt.SigningMethodRS256, key: key}
+}
+
+func newECKey(t *testing.T, kid string) signingKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return signingKey{kid: kid, method: jwt.SigningMethodES256, key: key}
+}
+
+func (k signingKey) jwk() map[string]string {
+	b64 := func(n *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+	}
+	switch key := k.key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "use": "sig", "n": b64(key.N, key.Size()), "e": b64(big.NewInt(int64(key.E)), 3)}
+	case *ecdsa.PrivateKey:
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": b64(key.X, 32), "y": b64(key.Y, 32)}
+	}
+	panic("unsupported key")
+}
+
+func (k signingKey) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.kid
+	raw, err := token.SignedString(k.key)
+	require.NoError(t, err)
+	return raw
+}
+
+func jwks(t *testing.T, keys ...signingKey) []byte {
+	t.Helper()
+	set := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.jwk())
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func testJWTConfig() config.JWT {
+	return config.JWT{
+		Issuer:          "https://id.example.com",
+		Audience:        "ticket-processor",
+		RolesClaim:      "roles",
+		AdminRole:       "admin",
+		Scopes:          []string{models.ScopeReceiptsWrite},
+		Leeway:          time.Second,
+		RefreshInterval: time.Minute,
+		Timeout:         time.Second,
+	}
+}
+
+func validClaims(subject string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub": subject,
+		"iss": "https://id.example.com",
+		"aud": "ticket-processor",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	rsaKey := newRSAKey(t, "rsa")
+	ecKey := newECKey(t, "ec")
+	unknownKey := newRSAKey(t, "rsa")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks(t, rsaKey, ecKey), 0o600))
+	v, err := NewVerifier(context.Background(), zap.NewNop(), testJWTConfig(), FileKeySource(path))
+	require.NoError(t, err)
+
+	with := func(claims jwt.MapClaims, key string, value any) jwt.MapClaims {
+		claims[key] = value
+		return claims
+	}
+	without := func(claims jwt.MapClaims, key string) jwt.MapClaims {
+		delete(claims, key)
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    Token
+		wantErr bool
+		// wantErrIs, if set, is the specific reason the token is refused.
+		wantErrIs error
+	}{
+		{
+			name:  "RS256",
+			token: rsaKey.sign(t, validClaims("alice")),
+			want:  Token{Subject: "alice", Scopes: []string{models.ScopeReceiptsWrite}},
+		},
+		{
+			name:  "ES256",
+			token: ecKey.sign(t, validClaims("bob")),
+			want:  Token{Subject: "bob", Scopes: []string{models.ScopeReceiptsWrite}},
+		},
+		{
+			name:  "admin role",
+			token: rsaKey.sign(t, with(validClaims("carol"), "roles", []string{"user", "admin"})),
+			want:  Token{Subject: "carol", Scopes: []string{models.ScopeAdmin}},
+		},
+		{
+			name:  "admin role as a string",
+			token: rsaKey.sign(t, with(validClaims("carol"), "roles", "admin")),
+			want:  Token{Subject: "carol", Scopes: []string{models.ScopeAdmin}},
+		},
+		{name: "other roles", token: rsaKey.sign(t, with(validClaims("dave"), "roles", []string{"user"})), want: Token{Subject: "dave", Scopes: []string{models.ScopeReceiptsWrite}}},
+		{name: "subject with a provider prefix", token: rsaKey.sign(t, validClaims("auth0|abc123")), wantErr: true, wantErrIs: ErrInvalidSubject},
+		{name: "subject too long", token: rsaKey.sign(t, validClaims(strings.Repeat("a", 65))), wantErr: true, wantErrIs: ErrInvalidSubject},
+		{
+			name:  "admin subject with a provider prefix",
+			token: rsaKey.sign(t, with(validClaims("auth0|root"), "roles", "admin")),
+			want:  Token{Subject: "auth0|root", Scopes: []string{models.ScopeAdmin}},
+		},
+		{name: "expired", token: rsaKey.sign(t, with(validClaims("alice"), "exp", time.Now().Add(-time.Minute).Unix())), wantErr: true},
+		{name: "no expiry", token: rsaKey.sign(t, without(validClaims("alice"), "exp")), wantErr: true},
+		{name: "no subject", token: rsaKey.sign(t, without(validClaims("alice"), "sub")), wantErr: true},
+		{name: "wrong issuer", token: rsaKey.sign(t, with(validClaims("alice"), "iss", "https://evil.example.com")), wantErr: true},
+		{name: "wrong audience", token: rsaKey.sign(t, with(validClaims("alice"), "aud", "other")), wantErr: true},
+		{name: "signed by another key", token: unknownKey.sign(t, validClaims("alice")), wantErr: true},
+		{name: "unknown kid", token: signingKey{kid: "other", method: rsaKey.method, key: rsaKey.key}.sign(t, validClaims("alice")), wantErr: true},
+		{name: "HS256", token: signingKey{kid: "rsa", method: jwt.SigningMethodHS256, key: []byte("secret")}.sign(t, validClaims("alice")), wantErr: true},
+		{name: "malformed", token: "not.a.jwt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(context.Background(), tt.token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidToken)
+				if tt.wantErrIs != nil {
+					assert.ErrorIs(t, err, tt.wantErrIs)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestVerifier_ReloadsJWKSForUnknownKey(t *testing.T) {
+	oldKey := newRSAKey(t, "old")
+	newKey := newECKey(t, "new")
+
+	var fetches atomic.Int32
+	var served atomic.Value
+	served.Store(jwks(t, oldKey))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(served.Load().([]byte))
+	}))
+	defer server.Close()
+
+	cfg := testJWTConfig()
+	cfg.RefreshInterval = 0
+	v, err := NewVerifier(context.Background(), zap.NewNop(), cfg, URLKeySource(server.Client(), server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	_, err = v.Verify(context.Background(), oldKey.sign(t, validClaims("alice")))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load(), "known keys are not fetched again")
+
+	served.Store(jwks(t, oldKey, newKey))
+	_, err = v.Verify(context.Background(), newKey.sign(t, validClaims("alice")))
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestVerifier_ThrottlesReloads(t *testing.T) {
+	key := newRSAKey(t, "key")
+	var fetches atomic.Int32
+	source := func(context.Context) ([]byte, error) {
+		fetches.Add(1)
+		return jwks(t, key), nil
+	}
+
+	v, err := NewVerifier(context.Background(), zap.NewNop(), testJWTConfig(), source)
+	require.NoError(t, err)
+
+	stranger := newRSAKey(t, "stranger")
+	for i := 0; i < 3; i++ {
+		_, err = v.Verify(context.Background(), stranger.sign(t, validClaims("alice")))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	}
+	assert.Equal(t, int32(1), fetches.Load(), "the JWKS is read at most once per refresh interval")
+}
+
+func TestNewVerifier_InvalidJWKS(t *testing.T) {
+	tests := []struct {
+		name   string
+		source KeySource
+	}{
+		{name: "missing file", source: FileKeySource(filepath.Join(t.TempDir(), "missing.json"))},
+		{name: "source error", source: func(context.Context) ([]byte, error) { return nil, errors.New("unreachable") }},
+		{name: "not JSON", source: func(context.Context) ([]byte, error) { return []byte("keys"), nil }},
+		{name: "bad modulus", source: func(context.Context) ([]byte, error) {
+			return []byte(`{"keys":[{"kty":"RSA","kid":"k","n":"!!","e":"AQAB"}]}`), nil
+		}},
+		{name: "point not on curve", source: func(context.Context) ([]byte, error) {
+			zero := base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+			return []byte(`{"keys":[{"kty":"EC","kid":"k","crv":"P-256","x":"` + zero + `","y":"` + zero + `"}]}`), nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewVerifier(context.Background(), zap.NewNop(), testJWTConfig(), tt.source)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -3,14 +3,27 @@ package config
 import (
 	"fmt"
 	"os"
+	"ticket-processor/internal/models"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	Env        string `yaml:"env" env:"ENV" env-default:"local"`
-	HTTPServer `yaml:"http-server"`
+	Env         string `yaml:"env" env:"ENV" env-default:"local"`
+	HTTPServer  `yaml:"http-server"`
+	Rules       Rules       `yaml:"rules"`
+	Storage     Storage     `yaml:"storage"`
+	Cache       Cache       `yaml:"cache"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Duplicates  Duplicates  `yaml:"duplicates"`
+	Validation  Validation  `yaml:"validation"`
+	Batch       Batch       `yaml:"batch"`
+	Async       Async       `yaml:"async"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Health      Health      `yaml:"health"`
+	Auth        Auth        `yaml:"auth"`
 }
 
 type HTTPServer struct {
@@ -20,6 +33,167 @@ type HTTPServer struct {
 	ShutdownTimeout time.Duration `yaml:"server_shutdown_timeout" env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" env-default:"10s"`
 }
 
+const (
+	StorageDriverMemory = "memory"
+	StorageDriverSQLite = "sqlite"
+	StorageDriverFile   = "file"
+)
+
+type Storage struct {
+	Driver string      `yaml:"driver" env:"STORAGE_DRIVER" env-default:"memory"`
+	SQLite SQLite      `yaml:"sqlite"`
+	File   FileStorage `yaml:"file"`
+}
+
+type SQLite struct {
+	Path string `yaml:"path" env:"STORAGE_SQLITE_PATH" env-default:"receipts.db"`
+}
+
+type FileStorage struct {
+	Dir           string `yaml:"dir" env:"STORAGE_FILE_DIR" env-default:"data"`
+	SnapshotEvery int    `yaml:"snapshot_every" env:"STORAGE_FILE_SNAPSHOT_EVERY" env-default:"1000"`
+}
+
+const (
+	CacheDriverMemory = "memory"
+	CacheDriverRedis  = "redis"
+)
+
+type Cache struct {
+	Driver        string        `yaml:"driver" env:"CACHE_DRIVER" env-default:"memory"`
+	MaxEntries    int           `yaml:"max_entries" env:"CACHE_MAX_ENTRIES" env-default:"100000"`
+	SweepInterval time.Duration `yaml:"sweep_interval" env:"CACHE_SWEEP_INTERVAL" env-default:"1m"`
+	Redis         Redis         `yaml:"redis"`
+}
+
+type Redis struct {
+	Address   string        `yaml:"address" env:"CACHE_REDIS_ADDRESS" env-default:"localhost:6379"`
+	Password  string        `yaml:"password" env:"CACHE_REDIS_PASSWORD"`
+	DB        int           `yaml:"db" env:"CACHE_REDIS_DB" env-default:"0"`
+	KeyPrefix string        `yaml:"key_prefix" env:"CACHE_REDIS_KEY_PREFIX" env-default:"receipt-points:"`
+	Timeout   time.Duration `yaml:"timeout" env:"CACHE_REDIS_TIMEOUT" env-default:"500ms"`
+}
+
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env:"IDEMPOTENCY_TTL" env-default:"24h"`
+}
+
+const (
+	DuplicatePolicyReturnOriginal = "return_original"
+	DuplicatePolicyReject         = "reject"
+)
+
+// Duplicates controls what happens when a receipt with the same content is submitted again.
+type Duplicates struct {
+	Policy string `yaml:"policy" env:"DUPLICATES_POLICY" env-default:"return_original"`
+}
+
+// Validation controls how far the item prices may sum from the receipt total:
+// TotalTolerance (an amount such as "0.50") plus TotalTolerancePercent of the item sum.
+type Validation struct {
+	TotalTolerance        string  `yaml:"total_tolerance" env:"VALIDATION_TOTAL_TOLERANCE" env-default:"0.00"`
+	TotalTolerancePercent float64 `yaml:"total_tolerance_percent" env:"VALIDATION_TOTAL_TOLERANCE_PERCENT" env-default:"0"`
+}
+
+// Batch bounds POST /receipts/batch, which may hold MaxSize receipts in a body
+// of at most MaxBodySize bytes. Batches are given Timeout instead of the
+// HTTPServer timeout.
+type Batch struct {
+	MaxSize     int           `yaml:"max_size" env:"BATCH_MAX_SIZE" env-default:"1000"`
+	MaxBodySize int64         `yaml:"max_body_size" env:"BATCH_MAX_BODY_SIZE" env-default:"16777216"`
+	Timeout     time.Duration `yaml:"timeout" env:"BATCH_TIMEOUT" env-default:"60s"`
+}
+
+// Async makes POST /receipts/process queue receipts for Workers to score in the
+// background. QueueSize receipts may wait before submissions are refused, and the
+// outcome of failed receipts is kept for StatusTTL.
+type Async struct {
+	Enabled   bool          `yaml:"enabled" env:"ASYNC_ENABLED" env-default:"false"`
+	Workers   int           `yaml:"workers" env:"ASYNC_WORKERS" env-default:"4"`
+	QueueSize int           `yaml:"queue_size" env:"ASYNC_QUEUE_SIZE" env-default:"1000"`
+	StatusTTL time.Duration `yaml:"status_ttl" env:"ASYNC_STATUS_TTL" env-default:"1h"`
+}
+
+// Webhooks tunes event delivery. A delivery is attempted MaxAttempts times,
+// waiting InitialBackoff after the first failure and doubling up to MaxBackoff;
+// then it is kept among the last MaxDeadLetters undelivered events.
+// Subscriptions and dead letters are kept in the configured Storage.
+type Webhooks struct {
+	Workers        int           `yaml:"workers" env:"WEBHOOKS_WORKERS" env-default:"4"`
+	QueueSize      int           `yaml:"queue_size" env:"WEBHOOKS_QUEUE_SIZE" env-default:"1000"`
+	Timeout        time.Duration `yaml:"timeout" env:"WEBHOOKS_TIMEOUT" env-default:"5s"`
+	MaxAttempts    int           `yaml:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS" env-default:"5"`
+	InitialBackoff time.Duration `yaml:"initial_backoff" env:"WEBHOOKS_INITIAL_BACKOFF" env-default:"1s"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" env:"WEBHOOKS_MAX_BACKOFF" env-default:"1m"`
+	MaxDeadLetters int           `yaml:"max_dead_letters" env:"WEBHOOKS_MAX_DEAD_LETTERS" env-default:"1000"`
+}
+
+const (
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+)
+
+// Tracing selects where OpenTelemetry spans are exported. SampleRatio is the
+// fraction of new traces that are recorded; incoming traceparent sampling
+// decisions are respected.
+type Tracing struct {
+	Exporter    string      `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	ServiceName string      `yaml:"service_name" env:"TRACING_SERVICE_NAME" env-default:"ticket-processor"`
+	SampleRatio float64     `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	OTLP        TracingOTLP `yaml:"otlp"`
+}
+
+// TracingOTLP configures the OTLP/HTTP exporter.
+type TracingOTLP struct {
+	Endpoint string `yaml:"endpoint" env:"TRACING_OTLP_ENDPOINT" env-default:"localhost:4318"`
+	Insecure bool   `yaml:"insecure" env:"TRACING_OTLP_INSECURE" env-default:"false"`
+}
+
+// Health tunes the readiness probe. Each dependency must answer within
+// CheckTimeout. On shutdown the server reports not ready but keeps serving for
+// DrainDelay, so load balancers stop sending traffic before it stops listening.
+type Health struct {
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" env-default:"2s"`
+	DrainDelay   time.Duration `yaml:"drain_delay" env:"HEALTH_DRAIN_DELAY" env-default:"0s"`
+}
+
+// minAdminKeyLength keeps the configured admin key at least as hard to guess as
+// the keys the API issues.
+const minAdminKeyLength = 32
+
+// Auth requires API keys or bearer tokens on every route except the health
+// checks, metrics and API docs. AdminKey, if set, is accepted as a key with the
+// admin scope; it is how the first keys are created.
+type Auth struct {
+	Enabled  bool   `yaml:"enabled" env:"AUTH_ENABLED" env-default:"false"`
+	AdminKey string `yaml:"admin_key" env:"AUTH_ADMIN_KEY"`
+	JWT      JWT    `yaml:"jwt"`
+}
+
+// JWT accepts RS256 and ES256 bearer tokens signed by a key in the JWKS read
+// from JWKSFile or fetched from JWKSURL. The JWKS is read again when a token
+// names an unknown key, at most once per RefreshInterval. Tokens carry the
+// customer in their subject and are granted Scopes, or the admin scope if the
+// RolesClaim lists AdminRole. Scopes may only grant receipts:write.
+type JWT struct {
+	JWKSFile        string        `yaml:"jwks_file" env:"AUTH_JWT_JWKS_FILE"`
+	JWKSURL         string        `yaml:"jwks_url" env:"AUTH_JWT_JWKS_URL"`
+	Issuer          string        `yaml:"issuer" env:"AUTH_JWT_ISSUER"`
+	Audience        string        `yaml:"audience" env:"AUTH_JWT_AUDIENCE"`
+	RolesClaim      string        `yaml:"roles_claim" env:"AUTH_JWT_ROLES_CLAIM" env-default:"roles"`
+	AdminRole       string        `yaml:"admin_role" env:"AUTH_JWT_ADMIN_ROLE" env-default:"admin"`
+	Scopes          []string      `yaml:"scopes" env:"AUTH_JWT_SCOPES" env-separator:"," env-default:"receipts:write"`
+	Leeway          time.Duration `yaml:"leeway" env:"AUTH_JWT_LEEWAY" env-default:"30s"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env:"AUTH_JWT_REFRESH_INTERVAL" env-default:"5m"`
+	Timeout         time.Duration `yaml:"timeout" env:"AUTH_JWT_TIMEOUT" env-default:"5s"`
+}
+
+// Enabled reports whether bearer tokens are accepted.
+func (j JWT) Enabled() bool {
+	return j.JWKSFile != "" || j.JWKSURL != ""
+}
+
 func (c *Config) Validate() error {
 	if c.HTTPServer.Timeout <= 0 {
 		return fmt.Errorf("http server timeout must be positive")
@@ -27,6 +201,126 @@ func (c *Config) Validate() error {
 	if c.HTTPServer.ShutdownTimeout <= 0 {
 		return fmt.Errorf("http server shutdown timeout must be positive")
 	}
+	switch c.Storage.Driver {
+	case StorageDriverMemory:
+	case StorageDriverSQLite:
+		if c.Storage.SQLite.Path == "" {
+			return fmt.Errorf("storage sqlite path must be set")
+		}
+	case StorageDriverFile:
+		if c.Storage.File.Dir == "" {
+			return fmt.Errorf("storage file dir must be set")
+		}
+		if c.Storage.File.SnapshotEvery <= 0 {
+			return fmt.Errorf("storage file snapshot_every must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown storage driver %q", c.Storage.Driver)
+	}
+	switch c.Cache.Driver {
+	case CacheDriverMemory:
+		if c.Cache.MaxEntries <= 0 {
+			return fmt.Errorf("cache max_entries must be positive")
+		}
+		if c.Cache.SweepInterval <= 0 {
+			return fmt.Errorf("cache sweep_interval must be positive")
+		}
+	case CacheDriverRedis:
+		if c.Cache.Redis.Address == "" {
+			return fmt.Errorf("cache redis address must be set")
+		}
+		if c.Cache.Redis.Timeout <= 0 {
+			return fmt.Errorf("cache redis timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown cache driver %q", c.Cache.Driver)
+	}
+	if c.Idempotency.TTL <= 0 {
+		return fmt.Errorf("idempotency ttl must be positive")
+	}
+	switch c.Duplicates.Policy {
+	case DuplicatePolicyReturnOriginal, DuplicatePolicyReject:
+	default:
+		return fmt.Errorf("unknown duplicates policy %q", c.Duplicates.Policy)
+	}
+	if _, err := models.ParseMoney(c.Validation.TotalTolerance); err != nil {
+		return fmt.Errorf("validation total_tolerance: %w", err)
+	}
+	if c.Validation.TotalTolerancePercent < 0 {
+		return fmt.Errorf("validation total_tolerance_percent must not be negative")
+	}
+	if c.Batch.MaxSize <= 0 {
+		return fmt.Errorf("batch max_size must be positive")
+	}
+	if c.Batch.MaxBodySize <= 0 {
+		return fmt.Errorf("batch max_body_size must be positive")
+	}
+	if c.Batch.Timeout <= 0 {
+		return fmt.Errorf("batch timeout must be positive")
+	}
+	if c.Async.Enabled {
+		if c.Async.Workers <= 0 {
+			return fmt.Errorf("async workers must be positive")
+		}
+		if c.Async.QueueSize <= 0 {
+			return fmt.Errorf("async queue_size must be positive")
+		}
+		if c.Async.StatusTTL <= 0 {
+			return fmt.Errorf("async status_ttl must be positive")
+		}
+	}
+	if c.Webhooks.Workers <= 0 || c.Webhooks.QueueSize <= 0 || c.Webhooks.MaxAttempts <= 0 || c.Webhooks.MaxDeadLetters <= 0 {
+		return fmt.Errorf("webhooks workers, queue_size, max_attempts and max_dead_letters must be positive")
+	}
+	if c.Webhooks.Timeout <= 0 || c.Webhooks.InitialBackoff <= 0 || c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		return fmt.Errorf("webhooks timeout and initial_backoff must be positive and max_backoff at least initial_backoff")
+	}
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if c.Tracing.OTLP.Endpoint == "" {
+			return fmt.Errorf("tracing otlp endpoint must be set")
+		}
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return fmt.Errorf("tracing sample_ratio must be between 0 and 1")
+	}
+	if c.Health.CheckTimeout <= 0 {
+		return fmt.Errorf("health check_timeout must be positive")
+	}
+	if c.Health.DrainDelay < 0 {
+		return fmt.Errorf("health drain_delay must not be negative")
+	}
+	if c.Auth.AdminKey != "" && len(c.Auth.AdminKey) < minAdminKeyLength {
+		return fmt.Errorf("auth admin_key must be at least %d characters", minAdminKeyLength)
+	}
+	if c.Auth.JWT.Enabled() {
+		if !c.Auth.Enabled {
+			return fmt.Errorf("auth jwt requires auth to be enabled")
+		}
+		if c.Auth.JWT.JWKSFile != "" && c.Auth.JWT.JWKSURL != "" {
+			return fmt.Errorf("auth jwt jwks_file and jwks_url are mutually exclusive")
+		}
+		if c.Auth.JWT.RolesClaim == "" || c.Auth.JWT.AdminRole == "" {
+			return fmt.Errorf("auth jwt roles_claim and admin_role must be set")
+		}
+		// Customer tokens are only restricted to their own data on the routes
+		// they may use without a scope and on the write routes; receipts:read
+		// or admin would let them read every customer's receipts.
+		for _, scope := range c.Auth.JWT.Scopes {
+			if scope != models.ScopeReceiptsWrite {
+				return fmt.Errorf("auth jwt scopes may only include %s, not %q", models.ScopeReceiptsWrite, scope)
+			}
+		}
+		if c.Auth.JWT.Leeway < 0 || c.Auth.JWT.RefreshInterval <= 0 || c.Auth.JWT.Timeout <= 0 {
+			return fmt.Errorf("auth jwt leeway must not be negative and refresh_interval and timeout must be positive")
+		}
+	}
+	if err := c.Rules.Validate(); err != nil {
+		return fmt.Errorf("rules: %w", err)
+	}
 
 	return nil
 }
@@ -41,7 +335,7 @@ func MustLoad() (*Config, error) {
 		return nil, fmt.Errorf("config file does not exist: %s", configPath)
 	}
 
-	var cfg Config
+	cfg := Config{Rules: DefaultRules()}
 
 	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadConfig loads a config file with the given content through MustLoad.
+func loadConfig(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	t.Setenv("CONFIG_PATH", path)
+	return MustLoad()
+}
+
+func TestMustLoad_Rules(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantRules func(r *Rules)
+		wantErr   string
+	}{
+		{
+			name:      "no rules block keeps the defaults",
+			content:   "env: test\n",
+			wantRules: func(r *Rules) {},
+		},
+		{
+			name: "partial rules block keeps the defaults for omitted fields",
+			content: `rules:
+  round_dollar:
+    points: 75
+  purchase_time:
+    end: "17:00"
+`,
+			wantRules: func(r *Rules) {
+				r.RoundDollar.Points = 75
+				r.PurchaseTime.End = "17:00"
+			},
+		},
+		{
+			name: "rule disabled",
+			content: `rules:
+  odd_day:
+    enabled: false
+`,
+			wantRules: func(r *Rules) { r.OddDay.Enabled = false },
+		},
+		{
+			name: "every weight",
+			content: `rules:
+  retailer_name:
+    points_per_character: 2
+  quarter_multiple:
+    points: 30
+  item_pairs:
+    points_per_pair: 4
+  item_description:
+    length_multiple: 5
+    price_percent: 10
+  odd_day:
+    points: 8
+  purchase_time:
+    start: "13:30"
+    points: 12
+`,
+			wantRules: func(r *Rules) {
+				r.RetailerName.PointsPerCharacter = 2
+				r.QuarterMultiple.Points = 30
+				r.ItemPairs.PointsPerPair = 4
+				r.ItemDescription.LengthMultiple = 5
+				r.ItemDescription.PricePercent = 10
+				r.OddDay.Points = 8
+				r.PurchaseTime.Start = "13:30"
+				r.PurchaseTime.Points = 12
+			},
+		},
+		{
+			name: "negative multiplier",
+			content: `rules:
+  item_pairs:
+    points_per_pair: -5
+`,
+			wantErr: "rules: item_pairs points_per_pair must not be negative",
+		},
+		{
+			name: "zero divisor",
+			content: `rules:
+  item_description:
+    length_multiple: 0
+`,
+			wantErr: "rules: item_description length_multiple must be positive",
+		},
+		{
+			name: "window ending before it starts",
+			content: `rules:
+  purchase_time:
+    start: "18:00"
+`,
+			wantErr: "rules: purchase_time start must be before end",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(t, tt.content)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			want := DefaultRules()
+			tt.wantRules(&want)
+			assert.Equal(t, want, cfg.Rules)
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			modify: func(c *Config) {},
+		},
+		{
+			name:    "zero http server timeout",
+			modify:  func(c *Config) { c.HTTPServer.Timeout = 0 },
+			wantErr: "http server timeout must be positive",
+		},
+		{
+			name:    "unknown storage driver",
+			modify:  func(c *Config) { c.Storage.Driver = "postgres" },
+			wantErr: `unknown storage driver "postgres"`,
+		},
+		{
+			name: "file storage without snapshots",
+			modify: func(c *Config) {
+				c.Storage.Driver = StorageDriverFile
+				c.Storage.File.SnapshotEvery = 0
+			},
+			wantErr: "storage file snapshot_every must be positive",
+		},
+		{
+			name:    "unknown duplicates policy",
+			modify:  func(c *Config) { c.Duplicates.Policy = "ignore" },
+			wantErr: `unknown duplicates policy "ignore"`,
+		},
+		{
+			name:    "malformed total tolerance",
+			modify:  func(c *Config) { c.Validation.TotalTolerance = "half" },
+			wantErr: "validation total_tolerance",
+		},
+		{
+			name:    "negative total tolerance percent",
+			modify:  func(c *Config) { c.Validation.TotalTolerancePercent = -1 },
+			wantErr: "validation total_tolerance_percent must not be negative",
+		},
+		{
+			name:    "zero batch body size",
+			modify:  func(c *Config) { c.Batch.MaxBodySize = 0 },
+			wantErr: "batch max_body_size must be positive",
+		},
+		{
+			name: "async without workers",
+			modify: func(c *Config) {
+				c.Async.Enabled = true
+				c.Async.Workers = 0
+			},
+			wantErr: "async workers must be positive",
+		},
+		{
+			name:    "webhook backoff shrinking",
+			modify:  func(c *Config) { c.Webhooks.MaxBackoff = c.Webhooks.InitialBackoff - time.Millisecond },
+			wantErr: "max_backoff at least initial_backoff",
+		},
+		{
+			name:    "sample ratio above one",
+			modify:  func(c *Config) { c.Tracing.SampleRatio = 1.5 },
+			wantErr: "tracing sample_ratio must be between 0 and 1",
+		},
+		{
+			name:    "short admin key",
+			modify:  func(c *Config) { c.Auth.AdminKey = "secret" },
+			wantErr: "auth admin_key must be at least 32 characters",
+		},
+		{
+			name:    "jwt without auth",
+			modify:  func(c *Config) { c.Auth.JWT.JWKSFile = "jwks.json" },
+			wantErr: "auth jwt requires auth to be enabled",
+		},
+		{
+			name:    "invalid rules",
+			modify:  func(c *Config) { c.Rules.ItemDescription.LengthMultiple = 0 },
+			wantErr: "rules: item_description length_multiple must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(t, "env: test\n")
+			require.NoError(t, err)
+			tt.modify(cfg)
+
+			err = cfg.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const RuleTimeLayout = "15:04"
+
+// Rules configures the points rules. Values omitted from the config file
+// keep the defaults returned by DefaultRules.
+type Rules struct {
+	RetailerName    RetailerNameRule    `yaml:"retailer_name"`
+	RoundDollar     BonusRule           `yaml:"round_dollar"`
+	QuarterMultiple BonusRule           `yaml:"quarter_multiple"`
+	ItemPairs       ItemPairsRule       `yaml:"item_pairs"`
+	ItemDescription ItemDescriptionRule `yaml:"item_description"`
+	OddDay          BonusRule           `yaml:"odd_day"`
+	PurchaseTime    PurchaseTimeRule    `yaml:"purchase_time"`
+}
+
+type BonusRule struct {
+	Enabled bool `yaml:"enabled"`
+	Points  int  `yaml:"points"`
+}
+
+type RetailerNameRule struct {
+	Enabled            bool `yaml:"enabled"`
+	PointsPerCharacter int  `yaml:"points_per_character"`
+}
+
+type ItemPairsRule struct {
+	Enabled       bool `yaml:"enabled"`
+	PointsPerPair int  `yaml:"points_per_pair"`
+}
+
+type ItemDescriptionRule struct {
+	Enabled        bool `yaml:"enabled"`
+	LengthMultiple int  `yaml:"length_multiple"`
+	PricePercent   int  `yaml:"price_percent"`
+}
+
+// PurchaseTimeRule awards Points for purchases made at or after Start and before End.
+type PurchaseTimeRule struct {
+	Enabled bool   `yaml:"enabled"`
+	Start   string `yaml:"start"`
+	End     string `yaml:"end"`
+	Points  int    `yaml:"points"`
+}
+
+func DefaultRules() Rules {
+	return Rules{
+		RetailerName:    RetailerNameRule{Enabled: true, PointsPerCharacter: 1},
+		RoundDollar:     BonusRule{Enabled: true, Points: 50},
+		QuarterMultiple: BonusRule{Enabled: true, Points: 25},
+		ItemPairs:       ItemPairsRule{Enabled: true, PointsPerPair: 5},
+		ItemDescription: ItemDescriptionRule{Enabled: true, LengthMultiple: 3, PricePercent: 20},
+		OddDay:          BonusRule{Enabled: true, Points: 6},
+		PurchaseTime:    PurchaseTimeRule{Enabled: true, Start: "14:00", End: "16:00", Points: 10},
+	}
+}
+
+func (r Rules) Validate() error {
+	if r.RetailerName.PointsPerCharacter < 0 {
+		return fmt.Errorf("retailer_name points_per_character must not be negative")
+	}
+	if r.RoundDollar.Points < 0 {
+		return fmt.Errorf("round_dollar points must not be negative")
+	}
+	if r.QuarterMultiple.Points < 0 {
+		return fmt.Errorf("quarter_multiple points must not be negative")
+	}
+	if r.ItemPairs.PointsPerPair < 0 {
+		return fmt.Errorf("item_pairs points_per_pair must not be negative")
+	}
+	if r.ItemDescription.LengthMultiple <= 0 {
+		return fmt.Errorf("item_description length_multiple must be positive")
+	}
+	if r.ItemDescription.PricePercent < 0 {
+		return fmt.Errorf("item_description price_percent must not be negative")
+	}
+	if r.OddDay.Points < 0 {
+		return fmt.Errorf("odd_day points must not be negative")
+	}
+	if r.PurchaseTime.Points < 0 {
+		return fmt.Errorf("purchase_time points must not be negative")
+	}
+
+	start, err := time.Parse(RuleTimeLayout, r.PurchaseTime.Start)
+	if err != nil {
+		return fmt.Errorf("purchase_time start must be in HH:MM format: %w", err)
+	}
+	end, err := time.Parse(RuleTimeLayout, r.PurchaseTime.End)
+	if err != nil {
+		return fmt.Errorf("purchase_time end must be in HH:MM format: %w", err)
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("purchase_time start must be before end")
+	}
+
+	return nil
+}
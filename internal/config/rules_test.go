@@ -0,0 +1,102 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRules_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *Rules)
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			modify: func(r *Rules) {},
+		},
+		{
+			name:   "zero points",
+			modify: func(r *Rules) { r.RoundDollar.Points = 0 },
+		},
+		{
+			name:    "negative points per character",
+			modify:  func(r *Rules) { r.RetailerName.PointsPerCharacter = -1 },
+			wantErr: "retailer_name points_per_character must not be negative",
+		},
+		{
+			name:    "negative round dollar points",
+			modify:  func(r *Rules) { r.RoundDollar.Points = -50 },
+			wantErr: "round_dollar points must not be negative",
+		},
+		{
+			name:    "negative quarter multiple points",
+			modify:  func(r *Rules) { r.QuarterMultiple.Points = -25 },
+			wantErr: "quarter_multiple points must not be negative",
+		},
+		{
+			name:    "negative points per pair",
+			modify:  func(r *Rules) { r.ItemPairs.PointsPerPair = -5 },
+			wantErr: "item_pairs points_per_pair must not be negative",
+		},
+		{
+			name:    "zero length multiple",
+			modify:  func(r *Rules) { r.ItemDescription.LengthMultiple = 0 },
+			wantErr: "item_description length_multiple must be positive",
+		},
+		{
+			name:    "negative length multiple",
+			modify:  func(r *Rules) { r.ItemDescription.LengthMultiple = -3 },
+			wantErr: "item_description length_multiple must be positive",
+		},
+		{
+			name:    "negative price percent",
+			modify:  func(r *Rules) { r.ItemDescription.PricePercent = -20 },
+			wantErr: "item_description price_percent must not be negative",
+		},
+		{
+			name:    "negative odd day points",
+			modify:  func(r *Rules) { r.OddDay.Points = -6 },
+			wantErr: "odd_day points must not be negative",
+		},
+		{
+			name:    "negative purchase time points",
+			modify:  func(r *Rules) { r.PurchaseTime.Points = -10 },
+			wantErr: "purchase_time points must not be negative",
+		},
+		{
+			name:    "malformed start",
+			modify:  func(r *Rules) { r.PurchaseTime.Start = "2pm" },
+			wantErr: "purchase_time start must be in HH:MM format",
+		},
+		{
+			name:    "out of range end",
+			modify:  func(r *Rules) { r.PurchaseTime.End = "25:00" },
+			wantErr: "purchase_time end must be in HH:MM format",
+		},
+		{
+			name:    "start after end",
+			modify:  func(r *Rules) { r.PurchaseTime.Start, r.PurchaseTime.End = "16:00", "14:00" },
+			wantErr: "purchase_time start must be before end",
+		},
+		{
+			name:    "empty window",
+			modify:  func(r *Rules) { r.PurchaseTime.Start, r.PurchaseTime.End = "14:00", "14:00" },
+			wantErr: "purchase_time start must be before end",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := DefaultRules()
+			tt.modify(&rules)
+
+			err := rules.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
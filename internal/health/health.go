@@ -0,0 +1,116 @@
+// Package health decides whether the service is ready to take traffic.
+package health
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+// HealthChecker is a dependency the service needs to handle requests.
+type HealthChecker interface {
+	// CheckHealth returns an error if the dependency cannot be used right now.
+	CheckHealth(ctx context.Context) error
+}
+
+type Checker interface {
+	// Register adds a dependency checked by Ready under name.
+	Register(name string, c HealthChecker)
+	// Ready checks every dependency concurrently and reports whether the service
+	// should receive traffic: all dependencies are up and it is not draining.
+	Ready(ctx context.Context) (models.HealthResponse, bool)
+	// Drain makes Ready report the service as shutting down from now on.
+	Drain()
+}
+
+type checker struct {
+	log     *zap.Logger
+	timeout time.Duration
+
+	mu         sync.RWMutex
+	components map[string]HealthChecker
+	draining   atomic.Bool
+}
+
+// NewChecker creates a Checker that gives each dependency timeout to answer.
+func NewChecker(log *zap.Logger, timeout time.Duration) Checker {
+	return &checker{
+		log:        log,
+		timeout:    timeout,
+		components: make(map[string]HealthChecker),
+	}
+}
+
+func (c *checker) Register(name string, hc HealthChecker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.components[name] = hc
+}
+
+func (c *checker) Drain() {
+	c.draining.Store(true)
+}
+
+func (c *checker) Ready(ctx context.Context) (models.HealthResponse, bool) {
+	if c.draining.Load() {
+		return models.HealthResponse{Status: models.HealthStatusShuttingDown}, false
+	}
+
+	c.mu.RLock()
+	components := make(map[string]HealthChecker, len(c.components))
+	for name, hc := range c.components {
+		components[name] = hc
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type result struct {
+		name string
+		err  error
+	}
+	// Buffered so that checks ignoring ctx do not leak blocked goroutines.
+	done := make(chan result, len(components))
+	for name, hc := range components {
+		go func() { done <- result{name: name, err: hc.CheckHealth(ctx)} }()
+	}
+
+	response := models.HealthResponse{
+		Status:     models.HealthStatusReady,
+		Components: make(map[string]models.ComponentHealth, len(components)),
+	}
+collect:
+	for range components {
+		select {
+		case r := <-done:
+			response.Components[r.name] = componentHealth(r.err)
+		case <-ctx.Done():
+			break collect
+		}
+	}
+	// Checks that did not answer in time are down.
+	for name := range components {
+		if _, ok := response.Components[name]; !ok {
+			response.Components[name] = componentHealth(ctx.Err())
+		}
+	}
+
+	for name, component := range response.Components {
+		if component.Status != models.ComponentStatusUp {
+			c.log.Warn("Health check failed", zap.String("component", name), zap.String("error", component.Error))
+			response.Status = models.HealthStatusNotReady
+		}
+	}
+	return response, response.Status == models.HealthStatusReady
+}
+
+func componentHealth(err error) models.ComponentHealth {
+	if err != nil {
+		return models.ComponentHealth{Status: models.ComponentStatusDown, Error: err.Error()}
+	}
+	return models.ComponentHealth{Status: models.ComponentStatusUp}
+}
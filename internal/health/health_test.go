@@ -0,0 +1,90 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+type checkFunc func(ctx context.Context) error
+
+func (f checkFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+var (
+	up   = checkFunc(func(context.Context) error { return nil })
+	down = checkFunc(func(context.Context) error { return errors.New("connection refused") })
+	// hung ignores its context, like a driver stuck on a dead connection.
+	hung = checkFunc(func(context.Context) error { select {} })
+)
+
+func TestChecker_Ready(t *testing.T) {
+	tests := []struct {
+		name       string
+		components map[string]HealthChecker
+		want       models.HealthResponse
+	}{
+		{
+			name: "no dependencies",
+			want: models.HealthResponse{Status: models.HealthStatusReady, Components: map[string]models.ComponentHealth{}},
+		},
+		{
+			name:       "all up",
+			components: map[string]HealthChecker{"storage": up, "cache": up},
+			want: models.HealthResponse{Status: models.HealthStatusReady, Components: map[string]models.ComponentHealth{
+				"storage": {Status: models.ComponentStatusUp},
+				"cache":   {Status: models.ComponentStatusUp},
+			}},
+		},
+		{
+			name:       "one down",
+			components: map[string]HealthChecker{"storage": up, "cache": down},
+			want: models.HealthResponse{Status: models.HealthStatusNotReady, Components: map[string]models.ComponentHealth{
+				"storage": {Status: models.ComponentStatusUp},
+				"cache":   {Status: models.ComponentStatusDown, Error: "connection refused"},
+			}},
+		},
+		{
+			name:       "timeout",
+			components: map[string]HealthChecker{"storage": up, "queue": hung},
+			want: models.HealthResponse{Status: models.HealthStatusNotReady, Components: map[string]models.ComponentHealth{
+				"storage": {Status: models.ComponentStatusUp},
+				"queue":   {Status: models.ComponentStatusDown, Error: context.DeadlineExceeded.Error()},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChecker(zap.NewNop(), 20*time.Millisecond)
+			for name, hc := range tt.components {
+				c.Register(name, hc)
+			}
+
+			got, ready := c.Ready(context.Background())
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want.Status == models.HealthStatusReady, ready)
+		})
+	}
+}
+
+func TestChecker_Drain(t *testing.T) {
+	checked := false
+	c := NewChecker(zap.NewNop(), time.Second)
+	c.Register("storage", checkFunc(func(context.Context) error {
+		checked = true
+		return nil
+	}))
+
+	c.Drain()
+	got, ready := c.Ready(context.Background())
+
+	assert.False(t, ready)
+	assert.Equal(t, models.HealthResponse{Status: models.HealthStatusShuttingDown}, got)
+	assert.False(t, checked, "dependencies are not checked once draining")
+}
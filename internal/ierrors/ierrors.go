@@ -36,6 +36,13 @@ var (
 	ErrBadRequest          = &ErrResponse{HTTPCode: http.StatusBadRequest, StatusText: "Bad Request"}
 	ErrInternalServerError = &ErrResponse{HTTPCode: http.StatusInternalServerError, StatusText: "Internal Server Error"}
 	ErrNotFound            = &ErrResponse{HTTPCode: http.StatusNotFound, StatusText: "Not Found", ErrorText: "No receipt found for that ID"}
+	ErrDuplicateReceipt    = &ErrResponse{HTTPCode: http.StatusConflict, StatusText: "Conflict", ErrorText: "This receipt has already been submitted"}
+	ErrInvalidCursor       = &ErrResponse{HTTPCode: http.StatusBadRequest, StatusText: "Bad Request", ErrorText: "The cursor is invalid for this query"}
+	ErrInsufficientBalance = &ErrResponse{HTTPCode: http.StatusConflict, StatusText: "Conflict", ErrorText: "The customer's balance is too low for this redemption"}
+	ErrReceiptVoided       = &ErrResponse{HTTPCode: http.StatusConflict, StatusText: "Conflict", ErrorText: "This receipt has already been reversed"}
+	ErrUnauthorized        = &ErrResponse{HTTPCode: http.StatusUnauthorized, StatusText: "Unauthorized", ErrorText: "A valid API key is required"}
+	ErrAPIKeyNotFound      = &ErrResponse{HTTPCode: http.StatusNotFound, StatusText: "Not Found", ErrorText: "No API key found for that ID"}
+	ErrAPIKeyRevoked       = &ErrResponse{HTTPCode: http.StatusConflict, StatusText: "Conflict", ErrorText: "This API key has been revoked"}
 )
 
 type FieldError struct {
@@ -89,6 +96,16 @@ func NewValidationErrorResponse(err error) *ValidationErrorResponse {
 		}
 	}
 
+	var fieldErrors ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		return &ValidationErrorResponse{
+			HTTPCode:   http.StatusBadRequest,
+			StatusText: "Bad Request",
+			ErrorText:  "The receipt is invalid.",
+			Errors:     fieldErrors,
+		}
+	}
+
 	return &ValidationErrorResponse{
 		HTTPCode:   http.StatusBadRequest,
 		StatusText: "Bad Request",
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector reads the cache's counters on every scrape, so the cache
+// itself does not depend on Prometheus.
+type cacheCollector struct {
+	cache StatsCache
+
+	entries     *prometheus.Desc
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	evictions   *prometheus.Desc
+	expirations *prometheus.Desc
+}
+
+func newCacheCollector(driver string, c StatsCache) *cacheCollector {
+	labels := prometheus.Labels{"driver": driver}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help, nil, labels)
+	}
+	return &cacheCollector{
+		cache:       c,
+		entries:     desc("entries", "Entries currently held by the points cache."),
+		hits:        desc("hits_total", "Points cache lookups that found an entry."),
+		misses:      desc("misses_total", "Points cache lookups that found no live entry."),
+		evictions:   desc("evictions_total", "Entries dropped from the points cache to stay within capacity."),
+		expirations: desc("expirations_total", "Entries dropped from the points cache because they expired."),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.evictions
+	ch <- c.expirations
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(stats.Entries))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(stats.Expirations))
+}
@@ -0,0 +1,121 @@
+// Package metrics collects the service's Prometheus metrics and serves them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+const namespace = "ticket_processor"
+
+// PointsBuckets are the upper bounds of the points-awarded histogram.
+var PointsBuckets = []float64{0, 10, 25, 50, 75, 100, 150, 200, 300, 500, 1000}
+
+// Metrics owns a registry with every metric of the service. Its methods are
+// safe for concurrent use.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	receiptsProcessed prometheus.Counter
+	pointsAwarded     prometheus.Histogram
+	ruleAwards        *prometheus.CounterVec
+	rulePoints        *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		receiptsProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "receipts_processed_total",
+			Help:      "Receipts scored and stored, not counting duplicates.",
+		}),
+		pointsAwarded: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "receipt_points",
+			Help:      "Points awarded per processed receipt.",
+			Buckets:   PointsBuckets,
+		}),
+		ruleAwards: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rule_awards_total",
+			Help:      "Processed receipts a points rule awarded points to.",
+		}, []string{"rule"}),
+		rulePoints: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rule_points_total",
+			Help:      "Points awarded by a points rule across processed receipts.",
+		}, []string{"rule"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.receiptsProcessed,
+		m.pointsAwarded,
+		m.ruleAwards,
+		m.rulePoints,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records a handled request. route is the route pattern, such as
+// "/receipts/:id", never the raw path, to keep the number of series bounded.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(method, route, code).Inc()
+	m.httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// PublishReceiptProcessed records the points of a newly stored receipt. It
+// makes Metrics a services.EventPublisher.
+func (m *Metrics) PublishReceiptProcessed(_ context.Context, record models.ReceiptRecord) {
+	m.receiptsProcessed.Inc()
+	m.pointsAwarded.Observe(float64(record.Points.Total))
+	for _, award := range record.Points.Rules {
+		if award.Points <= 0 {
+			continue
+		}
+		m.ruleAwards.WithLabelValues(award.Rule).Inc()
+		m.rulePoints.WithLabelValues(award.Rule).Add(float64(award.Points))
+	}
+}
+
+// StatsCache is a cache that keeps its own counters.
+type StatsCache interface {
+	Stats() storage.CacheStats
+}
+
+// RegisterCache exports the counters of c, labelled with the cache driver.
+func (m *Metrics) RegisterCache(driver string, c StatsCache) error {
+	return m.registry.Register(newCacheCollector(driver, c))
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+type fixedStats storage.CacheStats
+
+func (s fixedStats) Stats() storage.CacheStats {
+	return storage.CacheStats(s)
+}
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_ObserveRequest(t *testing.T) {
+	m := New()
+	m.ObserveRequest(http.MethodGet, "/receipts/:id", http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/receipts/:id", http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest(http.MethodGet, "/receipts/:id", http.StatusNotFound, time.Millisecond)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/receipts/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.httpRequests.WithLabelValues("GET", "/receipts/:id", "404")))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `ticket_processor_http_request_duration_seconds_count{method="GET",route="/receipts/:id",status="200"} 2`)
+	assert.Contains(t, body, `ticket_processor_http_request_duration_seconds_sum{method="GET",route="/receipts/:id",status="200"} 0.05`)
+}
+
+func TestMetrics_PublishReceiptProcessed(t *testing.T) {
+	m := New()
+	for _, total := range []int{24, 109} {
+		m.PublishReceiptProcessed(context.Background(), models.ReceiptRecord{
+			Points: models.PointsBreakdown{
+				Total: total,
+				Rules: []models.RuleAward{
+					{Rule: "retailer_name", Points: total - 10},
+					{Rule: "purchase_time", Points: 10},
+					{Rule: "round_dollar_total", Points: 0},
+				},
+			},
+		})
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.receiptsProcessed))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.ruleAwards.WithLabelValues("purchase_time")))
+	assert.Equal(t, 20.0, testutil.ToFloat64(m.rulePoints.WithLabelValues("purchase_time")))
+	assert.Equal(t, 113.0, testutil.ToFloat64(m.rulePoints.WithLabelValues("retailer_name")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.ruleAwards), "rules awarding nothing are not counted")
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `ticket_processor_receipt_points_bucket{le="25"} 1`)
+	assert.Contains(t, body, `ticket_processor_receipt_points_bucket{le="150"} 2`)
+	assert.Contains(t, body, "ticket_processor_receipt_points_sum 133")
+}
+
+func TestMetrics_RegisterCache(t *testing.T) {
+	m := New()
+	require.NoError(t, m.RegisterCache("memory", fixedStats{Entries: 3, Hits: 7, Misses: 2, Evictions: 1, Expirations: 4}))
+
+	expected := `
+# HELP ticket_processor_cache_entries Entries currently held by the points cache.
+# TYPE ticket_processor_cache_entries gauge
+ticket_processor_cache_entries{driver="memory"} 3
+# HELP ticket_processor_cache_evictions_total Entries dropped from the points cache to stay within capacity.
+# TYPE ticket_processor_cache_evictions_total counter
+ticket_processor_cache_evictions_total{driver="memory"} 1
+# HELP ticket_processor_cache_expirations_total Entries dropped from the points cache because they expired.
+# TYPE ticket_processor_cache_expirations_total counter
+ticket_processor_cache_expirations_total{driver="memory"} 4
+# HELP ticket_processor_cache_hits_total Points cache lookups that found an entry.
+# TYPE ticket_processor_cache_hits_total counter
+ticket_processor_cache_hits_total{driver="memory"} 7
+# HELP ticket_processor_cache_misses_total Points cache lookups that found no live entry.
+# TYPE ticket_processor_cache_misses_total counter
+ticket_processor_cache_misses_total{driver="memory"} 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(m.registry, strings.NewReader(expected),
+		"ticket_processor_cache_entries",
+		"ticket_processor_cache_evictions_total",
+		"ticket_processor_cache_expirations_total",
+		"ticket_processor_cache_hits_total",
+		"ticket_processor_cache_misses_total",
+	))
+
+	assert.Error(t, m.RegisterCache("memory", fixedStats{}), "a cache can only be registered once")
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	ScopeReceiptsRead  = "receipts:read"
+	ScopeReceiptsWrite = "receipts:write"
+	// ScopeAdmin grants every other scope as well.
+	ScopeAdmin = "admin"
+)
+
+// APIKey is a client credential. Only the SHA-256 Hash of its secret is stored;
+// the secret itself is shown once, when the key is created or rotated.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=128"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=receipts:read receipts:write admin"`
+}
+
+// APIKeyResponse describes a key without its hash. Key, the secret, is only
+// set in the responses to creating and rotating the key.
+type APIKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Key       string     `json:"key,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RotatedAt *time.Time `json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+type ListAPIKeysResponse struct {
+	APIKeys []APIKeyResponse `json:"apiKeys"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+const (
+	LedgerEntryCredit     = "credit"
+	LedgerEntryRedemption = "redemption"
+	LedgerEntryReversal   = "reversal"
+)
+
+// LedgerEntry is an immutable change to a customer's points balance. Points is
+// negative for redemptions and reversals; Balance is the customer's balance
+// after the entry.
+type LedgerEntry struct {
+	ID          string    `json:"id"`
+	CustomerID  string    `json:"customerId"`
+	Type        string    `json:"type"`
+	ReceiptID   string    `json:"receiptId,omitempty"`
+	Points      int       `json:"points"`
+	Balance     int       `json:"balance"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type GetCustomerBalanceResponse struct {
+	CustomerID string `json:"customerId"`
+	Balance    int    `json:"balance"`
+}
+
+type GetCustomerLedgerResponse struct {
+	Entries    []LedgerEntry `json:"entries"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type RedeemPointsRequest struct {
+	Points      int    `json:"points" validate:"required,min=1"`
+	Description string `json:"description" validate:"omitempty,max=256"`
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	HealthStatusOK           = "ok"
+	HealthStatusReady        = "ready"
+	HealthStatusNotReady     = "not_ready"
+	HealthStatusShuttingDown = "shutting_down"
+
+	ComponentStatusUp   = "up"
+	ComponentStatusDown = "down"
+)
+
+// HealthResponse answers the liveness and readiness probes. Components is only
+// set by readiness checks that ran.
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// Money is an exact monetary amount in integer cents.
+type Money int64
+
+var moneyRe = regexp.MustCompile(`^\d+\.\d{2}$`)
+
+// ParseMoney parses an amount in the "^\d+\.\d{2}$" format used by the API.
+func ParseMoney(s string) (Money, error) {
+	if !moneyRe.MatchString(s) {
+		return 0, fmt.Errorf("amount %q must match the format 0.00", s)
+	}
+
+	dollars, err := strconv.ParseInt(s[:len(s)-3], 10, 64)
+	if err != nil || dollars > math.MaxInt64/100 {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	cents, err := strconv.ParseInt(s[len(s)-2:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("amount %q has invalid cents: %w", s, err)
+	}
+
+	total := dollars*100 + cents
+	if total < 0 {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Money(total), nil
+}
+
+func (m Money) Cents() int64 {
+	return int64(m)
+}
+
+func (m Money) String() string {
+	sign := ""
+	cents := uint64(m)
+	if m < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("amount must be a string: %w", err)
+	}
+
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
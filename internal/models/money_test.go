@@ -0,0 +1,132 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Money
+		wantErr  bool
+	}{
+		{name: "Simple", input: "6.49", expected: 649},
+		{name: "RoundDollar", input: "12.00", expected: 1200},
+		{name: "Zero", input: "0.00", expected: 0},
+		{name: "LeadingZeros", input: "007.05", expected: 705},
+		{name: "MaxValue", input: "92233720368547758.07", expected: math.MaxInt64},
+		{name: "Overflow", input: "92233720368547758.08", wantErr: true},
+		{name: "HugeOverflow", input: "99999999999999999999.99", wantErr: true},
+		{name: "OneDecimal", input: "6.5", wantErr: true},
+		{name: "NoDecimals", input: "6", wantErr: true},
+		{name: "ThreeDecimals", input: "6.499", wantErr: true},
+		{name: "Negative", input: "-6.49", wantErr: true},
+		{name: "Exponent", input: "1e2", wantErr: true},
+		{name: "Empty", input: "", wantErr: true},
+		{name: "Whitespace", input: " 6.49", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseMoney(tt.input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, m)
+		})
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	assert.Equal(t, "0.00", Money(0).String())
+	assert.Equal(t, "0.05", Money(5).String())
+	assert.Equal(t, "35.35", Money(3535).String())
+	assert.Equal(t, "-10.25", Money(-1025).String())
+	assert.Equal(t, "92233720368547758.07", Money(math.MaxInt64).String())
+	assert.Equal(t, "-92233720368547758.08", Money(math.MinInt64).String())
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var item Item
+	require.NoError(t, json.Unmarshal([]byte(`{"shortDescription":"Gatorade","price":"2.25"}`), &item))
+	assert.Equal(t, Money(225), item.Price)
+
+	data, err := json.Marshal(item)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"shortDescription":"Gatorade","price":"2.25"}`, string(data))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"shortDescription":"Gatorade","price":"2.2"}`), &item))
+	assert.Error(t, json.Unmarshal([]byte(`{"shortDescription":"Gatorade","price":2.25}`), &item))
+}
+
+// FuzzParseMoney_ValidAmounts checks that every amount in the API format is
+// parsed to exactly dollars*100+cents and formats back to the same string.
+func FuzzParseMoney_ValidAmounts(f *testing.F) {
+	f.Add(uint64(35), uint8(35))
+	f.Add(uint64(0), uint8(1))
+	f.Add(uint64(12), uint8(0))
+	f.Add(uint64(math.MaxInt64/100), uint8(7))
+	f.Add(uint64(1<<53), uint8(99))
+
+	f.Fuzz(func(t *testing.T, dollars uint64, cents uint8) {
+		cents %= 100
+		if dollars > math.MaxInt64/100 || (dollars == math.MaxInt64/100 && int64(cents) > math.MaxInt64%100) {
+			t.Skip()
+		}
+		s := fmt.Sprintf("%d.%02d", dollars, cents)
+
+		m, err := ParseMoney(s)
+		require.NoError(t, err)
+		assert.Equal(t, int64(dollars)*100+int64(cents), m.Cents())
+		assert.Equal(t, s, m.String())
+
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		var decoded Money
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, m, decoded)
+	})
+}
+
+// FuzzParseMoney compares every accepted input against exact rational arithmetic.
+func FuzzParseMoney(f *testing.F) {
+	f.Add("6.49")
+	f.Add("0.00")
+	f.Add("000123.45")
+	f.Add("92233720368547758.07")
+	f.Add("92233720368547758.08")
+	f.Add("1.005")
+	f.Add("0.1")
+
+	format := regexp.MustCompile(`^\d+\.\d{2}$`)
+	f.Fuzz(func(t *testing.T, s string) {
+		m, err := ParseMoney(s)
+		if err != nil {
+			return
+		}
+		require.True(t, format.MatchString(s), "accepted malformed amount %q", s)
+
+		exact, ok := new(big.Rat).SetString(s)
+		require.True(t, ok)
+		exact.Mul(exact, big.NewRat(100, 1))
+		require.True(t, exact.IsInt())
+		assert.Equal(t, exact.Num().Int64(), m.Cents())
+
+		canonical := strings.TrimLeft(s[:len(s)-3], "0")
+		if canonical == "" {
+			canonical = "0"
+		}
+		assert.Equal(t, canonical+s[len(s)-3:], m.String())
+	})
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+type RuleAward struct {
+	Rule   string `json:"rule"`
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+}
+
+// PointsBreakdown is the itemized result of scoring a receipt.
+type PointsBreakdown struct {
+	Total int         `json:"total"`
+	Rules []RuleAward `json:"rules"`
+}
+
+// ReceiptRecord is a scored receipt as persisted by the storage layer.
+type ReceiptRecord struct {
+	ID           string          `json:"id"`
+	Receipt      Receipt         `json:"receipt"`
+	Points       PointsBreakdown `json:"points"`
+	ProcessedAt  time.Time       `json:"processedAt"`
+	RulesVersion string          `json:"rulesVersion"`
+	// Fingerprint identifies the receipt's content; see receipt.Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// VoidedAt is set once the receipt has been reversed.
+	VoidedAt *time.Time `json:"voidedAt,omitempty"`
+}
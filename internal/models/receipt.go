@@ -2,27 +2,80 @@ package models
 
 import (
 	"encoding/json"
-	"fmt"
-	"strconv"
+	"ticket-processor/internal/ierrors"
+	"time"
 )
 
 type Item struct {
-	ShortDescription string  `json:"shortDescription" validate:"required,notblank"`
-	Price            float64 `json:"price" validate:"required"`
+	ShortDescription string `json:"shortDescription" validate:"required,notblank,shortDesc"`
+	Price            Money  `json:"price" validate:"required"`
 }
 
 type Receipt struct {
-	Retailer     string  `json:"retailer" validate:"required,notblank"`
-	PurchaseDate string  `json:"purchaseDate" validate:"required,date"`
-	PurchaseTime string  `json:"purchaseTime" validate:"required,time"`
-	Items        []Item  `json:"items" validate:"required,dive"`
-	Total        float64 `json:"total" validate:"required"`
+	Retailer     string `json:"retailer" validate:"required,notblank,retailer"`
+	PurchaseDate string `json:"purchaseDate" validate:"required,date"`
+	PurchaseTime string `json:"purchaseTime" validate:"required,time"`
+	Items        []Item `json:"items" validate:"required,min=1,dive"`
+	Total        Money  `json:"total" validate:"required"`
+	// CustomerID, when set, is credited with the receipt's points.
+	CustomerID string `json:"customerId,omitempty" validate:"omitempty,max=64,customerId"`
 }
 type ProcessReceiptResponse struct {
 	ID string `json:"id"`
 }
 type GetReceiptPointsResponse struct {
 	Points int `json:"points"`
+	// Status is "processed" when receipts are processed asynchronously.
+	Status string `json:"status,omitempty"`
+}
+
+// ReceiptJobResponse describes a queued receipt that has no points yet.
+type ReceiptJobResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+type GetReceiptResponse struct {
+	ID           string     `json:"id"`
+	Receipt      Receipt    `json:"receipt"`
+	Points       int        `json:"points"`
+	ProcessedAt  time.Time  `json:"processedAt"`
+	RulesVersion string     `json:"rulesVersion"`
+	VoidedAt     *time.Time `json:"voidedAt,omitempty"`
+}
+
+// ReverseReceiptResponse describes a voided receipt. LedgerEntry is the
+// compensating entry, absent for receipts without a customer.
+type ReverseReceiptResponse struct {
+	ID          string       `json:"id"`
+	VoidedAt    time.Time    `json:"voidedAt"`
+	LedgerEntry *LedgerEntry `json:"ledgerEntry,omitempty"`
+}
+type ListReceiptsResponse struct {
+	Receipts   []GetReceiptResponse `json:"receipts"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// BatchReceiptResult is the outcome for the receipt at Index in a batch: either
+// its ID and points, the ID of the receipt it duplicates, or the errors that
+// kept it from being processed.
+type BatchReceiptResult struct {
+	Index     int                  `json:"index"`
+	ID        string               `json:"id,omitempty"`
+	Points    *int                 `json:"points,omitempty"`
+	Duplicate bool                 `json:"duplicate,omitempty"`
+	Errors    []ierrors.FieldError `json:"errors,omitempty"`
+}
+type ProcessReceiptsBatchResponse struct {
+	Results   []BatchReceiptResult `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	// Error is why the batch was cut short after the receipts in Results.
+	Error string `json:"error,omitempty"`
+}
+type GetReceiptPointsBreakdownResponse struct {
+	Points int         `json:"points"`
+	Rules  []RuleAward `json:"rules"`
 }
 
 func (r *Receipt) UnmarshalJSON(data []byte) error {
@@ -32,6 +85,7 @@ func (r *Receipt) UnmarshalJSON(data []byte) error {
 		PurchaseTime string `json:"purchaseTime"`
 		Items        []Item `json:"items"`
 		Total        string `json:"total"`
+		CustomerID   string `json:"customerId"`
 	}
 
 	if err := json.Unmarshal(data, &raw); err != nil {
@@ -42,10 +96,11 @@ func (r *Receipt) UnmarshalJSON(data []byte) error {
 	r.PurchaseDate = raw.PurchaseDate
 	r.PurchaseTime = raw.PurchaseTime
 	r.Items = raw.Items
+	r.CustomerID = raw.CustomerID
 
-	total, err := strconv.ParseFloat(raw.Total, 64)
+	total, err := ParseMoney(raw.Total)
 	if err != nil {
-		return fmt.Errorf("invalid total format: %w", err)
+		return ierrors.FieldError{Field: "total", Message: err.Error()}
 	}
 	r.Total = total
 
@@ -64,9 +119,9 @@ func (i *Item) UnmarshalJSON(data []byte) error {
 
 	i.ShortDescription = raw.ShortDescription
 
-	price, err := strconv.ParseFloat(raw.Price, 64)
+	price, err := ParseMoney(raw.Price)
 	if err != nil {
-		return fmt.Errorf("invalid price format: %w", err)
+		return ierrors.FieldError{Field: "price", Message: err.Error()}
 	}
 	i.Price = price
 
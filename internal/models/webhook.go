@@ -0,0 +1,56 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const EventReceiptProcessed = "receipt.processed"
+
+// WebhookSubscription is an endpoint that receives events. Secret signs the
+// events sent to it and is only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" validate:"required,http_url"`
+	// Secret is generated when empty.
+	Secret string `json:"secret" validate:"omitempty,min=16"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []WebhookSubscription `json:"webhooks"`
+}
+
+// WebhookEvent is the body POSTed to subscribers.
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type ReceiptProcessedData struct {
+	ReceiptID    string    `json:"receiptId"`
+	Points       int       `json:"points"`
+	ProcessedAt  time.Time `json:"processedAt"`
+	RulesVersion string    `json:"rulesVersion"`
+}
+
+// WebhookDeadLetter is an event that could not be delivered to a subscriber.
+type WebhookDeadLetter struct {
+	SubscriptionID string       `json:"subscriptionId"`
+	URL            string       `json:"url"`
+	Event          WebhookEvent `json:"event"`
+	Attempts       int          `json:"attempts"`
+	LastError      string       `json:"lastError"`
+	FailedAt       time.Time    `json:"failedAt"`
+}
+
+type ListWebhookDeadLettersResponse struct {
+	DeadLetters []WebhookDeadLetter `json:"deadLetters"`
+}
@@ -0,0 +1,56 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+	"time"
+	"unicode"
+)
+
+// Fingerprint identifies a receipt by its content, so the same paper receipt
+// submitted twice yields the same value. Retailer and item descriptions are
+// compared case-insensitively ignoring punctuation and spacing, and item order
+// does not matter.
+func Fingerprint(r models.Receipt) string {
+	items := make([]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		items = append(items, fmt.Sprintf("%s=%d", normalizeText(item.ShortDescription), item.Price.Cents()))
+	}
+	sort.Strings(items)
+
+	canonical := strings.Join([]string{
+		normalizeText(r.Retailer),
+		normalizeTimestamp(r.PurchaseDate, time.DateOnly),
+		normalizeTimestamp(r.PurchaseTime, config.RuleTimeLayout),
+		strings.Join(items, ","),
+		fmt.Sprint(r.Total.Cents()),
+	}, "\n")
+
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeText lowercases s and drops everything but letters and digits.
+func normalizeText(s string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func normalizeTimestamp(value, layout string) string {
+	value = strings.TrimSpace(value)
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return value
+	}
+	return t.Format(layout)
+}
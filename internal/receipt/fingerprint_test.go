@@ -0,0 +1,90 @@
+package receipt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"ticket-processor/internal/models"
+)
+
+func TestFingerprint(t *testing.T) {
+	base := models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
+		},
+		Total: 874,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(r *models.Receipt)
+		same   bool
+	}{
+		{
+			name:   "retailer case and spacing",
+			modify: func(r *models.Receipt) { r.Retailer = "  m&m  corner MARKET " },
+			same:   true,
+		},
+		{
+			name:   "retailer punctuation",
+			modify: func(r *models.Receipt) { r.Retailer = "MM Corner-Market" },
+			same:   true,
+		},
+		{
+			name: "item order and description case",
+			modify: func(r *models.Receipt) {
+				r.Items = []models.Item{
+					{ShortDescription: "mountain dew 12pk ", Price: 649},
+					{ShortDescription: "GATORADE", Price: 225},
+				}
+			},
+			same: true,
+		},
+		{
+			name:   "different retailer",
+			modify: func(r *models.Receipt) { r.Retailer = "Target" },
+		},
+		{
+			name:   "different date",
+			modify: func(r *models.Receipt) { r.PurchaseDate = "2022-03-21" },
+		},
+		{
+			name:   "different time",
+			modify: func(r *models.Receipt) { r.PurchaseTime = "14:34" },
+		},
+		{
+			name:   "different item price",
+			modify: func(r *models.Receipt) { r.Items[0].Price = 226 },
+		},
+		{
+			name: "extra item",
+			modify: func(r *models.Receipt) {
+				r.Items = append(r.Items, models.Item{ShortDescription: "Gatorade", Price: 225})
+			},
+		},
+		{
+			name:   "different total",
+			modify: func(r *models.Receipt) { r.Total = 875 },
+		},
+	}
+
+	want := Fingerprint(base)
+	assert.Len(t, want, 64)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := base
+			r.Items = append([]models.Item(nil), base.Items...)
+			tt.modify(&r)
+
+			if tt.same {
+				assert.Equal(t, want, Fingerprint(r))
+			} else {
+				assert.NotEqual(t, want, Fingerprint(r))
+			}
+		})
+	}
+}
@@ -1,89 +1,79 @@
 package receipt
 
 import (
-	"math"
 	"regexp"
 	"strings"
+	"ticket-processor/internal/config"
 	"ticket-processor/internal/models"
 	"time"
 )
 
-func CalculatePoints(receipt models.Receipt) int {
-	points := 0
+var defaultRegistry = DefaultRegistry()
 
-	points += calculateRetailerPoints(receipt.Retailer)
-	points += calculateRoundDollarBonus(receipt.Total)
-	points += calculateQuarterMultipleBonus(receipt.Total)
-	points += calculateItemCountBonus(len(receipt.Items))
-	points += calculateItemDescriptionPoints(receipt.Items)
-	points += calculateOddDayBonus(receipt.PurchaseDate)
-	points += calculatePurchaseTimeBonus(receipt.PurchaseTime)
+var alphanumericRe = regexp.MustCompile("[a-zA-Z0-9]")
 
-	return points
+// CalculatePoints scores the receipt using the default rule set.
+func CalculatePoints(receipt models.Receipt) models.PointsBreakdown {
+	return defaultRegistry.CalculatePoints(receipt)
 }
 
-func calculateRetailerPoints(retailer string) int {
-	re := regexp.MustCompile("[a-zA-Z0-9]")
-	matches := re.FindAllString(retailer, -1)
-	return len(matches)
+func countAlphanumeric(s string) int {
+	return len(alphanumericRe.FindAllString(s, -1))
 }
 
-func calculateRoundDollarBonus(amount float64) int {
-	if amount > 0 && amount == float64(int(amount)) {
-		return 50
-	}
-	return 0
+func calculateRetailerPoints(retailer string, pointsPerCharacter int) int {
+	return countAlphanumeric(retailer) * pointsPerCharacter
 }
 
-// very tricky one, as working with float numbers very painful
-// I have experience with this kind of task, in e-commerce project
-// for this particular case, lets assume our processor will always receive
-// already correctly rounded numbers
-// in other case solution will be more complex with comparing reminder with some epsilon
-func calculateQuarterMultipleBonus(amount float64) int {
-	if amount > 0 && math.Mod(amount*4, 1) == 0 {
-		return 25
-	}
-	return 0
+func isRoundDollar(amount models.Money) bool {
+	return amount > 0 && amount.Cents()%100 == 0
+}
+
+func isQuarterMultiple(amount models.Money) bool {
+	return amount > 0 && amount.Cents()%25 == 0
+}
+
+func calculateItemCountBonus(itemCount int, pointsPerPair int) int {
+	return (itemCount / 2) * pointsPerPair
 }
 
-func calculateItemCountBonus(itemCount int) int {
-	return (itemCount / 2) * 5
+func descriptionMatches(item models.Item, lengthMultiple int) bool {
+	trimmedDescription := strings.TrimSpace(item.ShortDescription)
+	return len(trimmedDescription)%lengthMultiple == 0
 }
 
-func calculateItemDescriptionPoints(items []models.Item) int {
+func calculateItemDescriptionPoints(items []models.Item, lengthMultiple int, pricePercent int) int {
 	points := 0
 	for _, item := range items {
-		trimmedDescription := strings.TrimSpace(item.ShortDescription)
-		if len(trimmedDescription)%3 == 0 {
-			points += int(math.Ceil(item.Price * 0.2))
+		if descriptionMatches(item, lengthMultiple) {
+			points += int(percentOfPriceCeil(item.Price, pricePercent))
 		}
 	}
 	return points
 }
 
-func calculateOddDayBonus(purchaseDate string) int {
-	d, err := time.Parse(time.DateOnly, purchaseDate)
-	if err != nil {
+// percentOfPriceCeil returns percent% of price in whole dollars, rounded up.
+// Whole dollars and remaining cents are scaled separately to keep cents*percent from overflowing.
+func percentOfPriceCeil(price models.Money, percent int) int64 {
+	if price <= 0 || percent <= 0 {
 		return 0
 	}
-	day := d.Day()
-
-	if day%2 != 0 {
-		return 6
-	}
-	return 0
+	const centsPerDollarPercent = 100 * 100
+	cents, p := price.Cents(), int64(percent)
+	whole := cents / centsPerDollarPercent * p
+	rem := cents % centsPerDollarPercent * p
+	return whole + (rem+centsPerDollarPercent-1)/centsPerDollarPercent
 }
 
-func calculatePurchaseTimeBonus(purchaseTime string) int {
-	t, err := time.Parse("15:04", purchaseTime)
+// calculatePurchaseTimeBonus awards points when the purchase time falls in [start, end).
+func calculatePurchaseTimeBonus(purchaseTime string, start, end time.Time, points int) int {
+	t, err := time.Parse(config.RuleTimeLayout, purchaseTime)
 	if err != nil {
 		return 0
 	}
 
-	after2pm := t.Hour() >= 14 && t.Hour() < 16
-	if after2pm {
-		return 10
+	if !t.Before(start) && t.Before(end) {
+		return points
 	}
 	return 0
 }
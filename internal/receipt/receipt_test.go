@@ -1,9 +1,19 @@
 package receipt
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
 	"testing"
+	"ticket-processor/internal/config"
 	"ticket-processor/internal/models"
+	"time"
+)
+
+var (
+	afternoonStart = time.Date(0, 1, 1, 14, 0, 0, 0, time.UTC)
+	afternoonEnd   = time.Date(0, 1, 1, 16, 0, 0, 0, time.UTC)
 )
 
 func TestCalculatePoints_ValidReceipt(t *testing.T) {
@@ -19,13 +29,13 @@ func TestCalculatePoints_ValidReceipt(t *testing.T) {
 				PurchaseDate: "2022-01-01",
 				PurchaseTime: "13:01",
 				Items: []models.Item{
-					{ShortDescription: "Mountain Dew 12PK", Price: 6.49},
-					{ShortDescription: "Emils Cheese Pizza", Price: 12.25},
-					{ShortDescription: "Knorr Creamy Chicken", Price: 1.26},
-					{ShortDescription: "Doritos Nacho Cheese", Price: 3.35},
-					{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: 12.00},
+					{ShortDescription: "Mountain Dew 12PK", Price: 649},
+					{ShortDescription: "Emils Cheese Pizza", Price: 1225},
+					{ShortDescription: "Knorr Creamy Chicken", Price: 126},
+					{ShortDescription: "Doritos Nacho Cheese", Price: 335},
+					{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: 1200},
 				},
-				Total: 35.35,
+				Total: 3535,
 			},
 			expected: 28,
 		},
@@ -36,12 +46,12 @@ func TestCalculatePoints_ValidReceipt(t *testing.T) {
 				PurchaseDate: "2022-03-20",
 				PurchaseTime: "14:33",
 				Items: []models.Item{
-					{ShortDescription: "Gatorade", Price: 2.25},
-					{ShortDescription: "Gatorade", Price: 2.25},
-					{ShortDescription: "Gatorade", Price: 2.25},
-					{ShortDescription: "Gatorade", Price: 2.25},
+					{ShortDescription: "Gatorade", Price: 225},
+					{ShortDescription: "Gatorade", Price: 225},
+					{ShortDescription: "Gatorade", Price: 225},
+					{ShortDescription: "Gatorade", Price: 225},
 				},
-				Total: 9.00,
+				Total: 900,
 			},
 			expected: 109,
 		},
@@ -49,11 +59,40 @@ func TestCalculatePoints_ValidReceipt(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			points := CalculatePoints(tt.receipt)
-			assert.Equal(t, tt.expected, points)
+			assert.Equal(t, tt.expected, points.Total)
 		})
 	}
 }
 
+func TestCalculatePoints_Breakdown(t *testing.T) {
+	receipt := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
+			{ShortDescription: "Emils Cheese Pizza", Price: 1225},
+			{ShortDescription: "Knorr Creamy Chicken", Price: 126},
+			{ShortDescription: "Doritos Nacho Cheese", Price: 335},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: 1200},
+		},
+		Total: 3535,
+	}
+
+	breakdown := CalculatePoints(receipt)
+
+	assert.Equal(t, 28, breakdown.Total)
+	assert.Equal(t, []models.RuleAward{
+		{Rule: RetailerNameRule, Points: 6, Reason: "6 alphanumeric characters in retailer name"},
+		{Rule: RoundDollarRule, Points: 0, Reason: "total 35.35 is not a round dollar amount"},
+		{Rule: QuarterMultipleRule, Points: 0, Reason: "total 35.35 is not a multiple of 0.25"},
+		{Rule: ItemPairsRule, Points: 10, Reason: "5 items make 2 pairs"},
+		{Rule: ItemDescriptionRule, Points: 6, Reason: "2 item descriptions with a trimmed length that is a multiple of 3, each earning 20% of its price"},
+		{Rule: OddDayRule, Points: 6, Reason: "purchase day 1 is odd"},
+		{Rule: PurchaseTimeRule, Points: 0, Reason: "purchase time 13:01 is not between 14:00 and 16:00"},
+	}, breakdown.Rules)
+}
+
 func TestCalculateRetailerPoints(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -79,84 +118,84 @@ func TestCalculateRetailerPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateRetailerPoints(tt.retailer)
+			points := calculateRetailerPoints(tt.retailer, 1)
 			assert.Equal(t, tt.expected, points)
 		})
 	}
 }
 
-func TestCalculateQuarterMultipleBonus(t *testing.T) {
+func TestQuarterMultipleRule(t *testing.T) {
 	tests := []struct {
 		name     string
-		amount   float64
+		amount   models.Money
 		expected int
 	}{
 		{
 			name:     "QuarterMultiple",
-			amount:   10.25,
+			amount:   1025,
 			expected: 25,
 		},
 		{
 			name:     "NotQuarterMultiple",
-			amount:   10.60,
+			amount:   1060,
 			expected: 0,
 		},
 		{
 			name:     "RoundDollar",
-			amount:   10.00,
+			amount:   1000,
 			expected: 25,
 		},
 		{
 			name:     "ZeroAmount",
-			amount:   0.00,
+			amount:   0,
 			expected: 0,
 		},
 		{
 			name:     "NegativeAmount",
-			amount:   -10.25,
+			amount:   -1025,
 			expected: 0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateQuarterMultipleBonus(tt.amount)
+			points, _ := quarterMultipleRule{points: 25}.Evaluate(models.Receipt{Total: tt.amount})
 			assert.Equal(t, tt.expected, points)
 		})
 	}
 }
 
-func TestCalculateRoundDollarBonus(t *testing.T) {
+func TestRoundDollarRule(t *testing.T) {
 	tests := []struct {
 		name     string
-		amount   float64
+		amount   models.Money
 		expected int
 	}{
 		{
 			name:     "RoundDollar",
-			amount:   10.00,
+			amount:   1000,
 			expected: 50,
 		},
 		{
 			name:     "NonRoundDollar",
-			amount:   10.50,
+			amount:   1050,
 			expected: 0,
 		},
 		{
 			name:     "ZeroAmount",
-			amount:   0.00,
+			amount:   0,
 			expected: 0,
 		},
 		{
 			name:     "NegativeAmount",
-			amount:   -10.00,
+			amount:   -1000,
 			expected: 0,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateRoundDollarBonus(tt.amount)
+			points, _ := roundDollarRule{points: 50}.Evaluate(models.Receipt{Total: tt.amount})
 			assert.Equal(t, tt.expected, points)
 		})
 	}
@@ -192,7 +231,7 @@ func TestCalculateItemCountBonus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateItemCountBonus(tt.itemCount)
+			points := calculateItemCountBonus(tt.itemCount, 5)
 			assert.Equal(t, tt.expected, points)
 		})
 	}
@@ -212,23 +251,23 @@ func TestCalculateItemDescriptionPoints(t *testing.T) {
 		{
 			name: "MultipleItems",
 			items: []models.Item{
-				{ShortDescription: "Candy Bar", Price: 0.75},
-				{ShortDescription: "2 lb Chicken", Price: 10.00},
-				{ShortDescription: "Milk", Price: 4.50},
+				{ShortDescription: "Candy Bar", Price: 75},
+				{ShortDescription: "2 lb Chicken", Price: 1000},
+				{ShortDescription: "Milk", Price: 450},
 			},
 			expected: 3,
 		},
 		{
 			name: "ItemWithDescriptionMultipleOfThreeAndRoundUp",
 			items: []models.Item{
-				{ShortDescription: "ItemDescription", Price: 10.50},
+				{ShortDescription: "ItemDescription", Price: 1050},
 			},
 			expected: 3,
 		},
 		{
 			name: "ItemWithDescriptionMultipleOfThreeWithoutRoundUp",
 			items: []models.Item{
-				{ShortDescription: "ItemDescription", Price: 10.00},
+				{ShortDescription: "ItemDescription", Price: 1000},
 			},
 			expected: 2,
 		},
@@ -236,13 +275,13 @@ func TestCalculateItemDescriptionPoints(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateItemDescriptionPoints(tt.items)
+			points := calculateItemDescriptionPoints(tt.items, 3, 20)
 			assert.Equal(t, tt.expected, points)
 		})
 	}
 }
 
-func TestCalculateOddDayBonus(t *testing.T) {
+func TestOddDayRule(t *testing.T) {
 	tests := []struct {
 		name         string
 		purchaseDate string
@@ -267,7 +306,7 @@ func TestCalculateOddDayBonus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculateOddDayBonus(tt.purchaseDate)
+			points, _ := oddDayRule{points: 6}.Evaluate(models.Receipt{PurchaseDate: tt.purchaseDate})
 			assert.Equal(t, tt.expected, points)
 		})
 	}
@@ -303,8 +342,124 @@ func TestCalculatePurchaseTimeBonus(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			points := calculatePurchaseTimeBonus(tt.purchaseTime)
+			points := calculatePurchaseTimeBonus(tt.purchaseTime, afternoonStart, afternoonEnd, 10)
 			assert.Equal(t, tt.expected, points)
 		})
 	}
 }
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	receipt := models.Receipt{
+		Retailer:     "M&M Corner Market",
+		PurchaseDate: "2022-03-20",
+		PurchaseTime: "14:33",
+		Items: []models.Item{
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+			{ShortDescription: "Gatorade", Price: 225},
+		},
+		Total: 900,
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(cfg *config.Rules)
+		expected int
+	}{
+		{
+			name:     "Defaults",
+			modify:   func(cfg *config.Rules) {},
+			expected: 109,
+		},
+		{
+			name: "RoundDollarDisabled",
+			modify: func(cfg *config.Rules) {
+				cfg.RoundDollar.Enabled = false
+			},
+			expected: 59,
+		},
+		{
+			name: "PurchaseTimeWindowMoved",
+			modify: func(cfg *config.Rules) {
+				cfg.PurchaseTime.Start = "15:00"
+				cfg.PurchaseTime.End = "17:00"
+			},
+			expected: 99,
+		},
+		{
+			name: "DoubledPoints",
+			modify: func(cfg *config.Rules) {
+				cfg.RetailerName.PointsPerCharacter = 2
+				cfg.ItemPairs.PointsPerPair = 10
+			},
+			expected: 133,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultRules()
+			tt.modify(&cfg)
+
+			reg, err := NewRegistryFromConfig(cfg)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, reg.CalculatePoints(receipt).Total)
+		})
+	}
+}
+
+func TestNewRegistryFromConfig_Invalid(t *testing.T) {
+	cfg := config.DefaultRules()
+	cfg.PurchaseTime.Start = "16:00"
+	cfg.PurchaseTime.End = "14:00"
+
+	_, err := NewRegistryFromConfig(cfg)
+	assert.Error(t, err)
+}
+
+// FuzzMoneyRules checks the amount based rules against exact rational arithmetic
+// for every valid amount, so no float rounding can creep back in.
+func FuzzMoneyRules(f *testing.F) {
+	f.Add(uint64(35), uint8(35), uint8(20))
+	f.Add(uint64(9), uint8(0), uint8(20))
+	f.Add(uint64(0), uint8(25), uint8(20))
+	f.Add(uint64(12), uint8(25), uint8(20))
+	f.Add(uint64(1), uint8(26), uint8(20))
+	f.Add(uint64(0), uint8(1), uint8(33))
+	f.Add(uint64(92233720368547758), uint8(7), uint8(100))
+
+	f.Fuzz(func(t *testing.T, dollars uint64, cents uint8, percent uint8) {
+		cents %= 100
+		s := fmt.Sprintf("%d.%02d", dollars, cents)
+		amount, err := models.ParseMoney(s)
+		if err != nil {
+			t.Skip()
+		}
+
+		exact, ok := new(big.Rat).SetString(s)
+		require.True(t, ok)
+		positive := exact.Sign() > 0
+
+		wantRound := 0
+		if positive && exact.IsInt() {
+			wantRound = 50
+		}
+		round, _ := roundDollarRule{points: 50}.Evaluate(models.Receipt{Total: amount})
+		assert.Equal(t, wantRound, round, s)
+
+		wantQuarter := 0
+		if positive && new(big.Rat).Mul(exact, big.NewRat(4, 1)).IsInt() {
+			wantQuarter = 25
+		}
+		quarter, _ := quarterMultipleRule{points: 25}.Evaluate(models.Receipt{Total: amount})
+		assert.Equal(t, wantQuarter, quarter, s)
+
+		scaled := new(big.Rat).Mul(exact, big.NewRat(int64(percent), 100))
+		wantDescription := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+		if !scaled.IsInt() {
+			wantDescription.Add(wantDescription, big.NewInt(1))
+		}
+		assert.Equal(t, wantDescription.Int64(), percentOfPriceCeil(amount, int(percent)), s)
+	})
+}
@@ -0,0 +1,146 @@
+package receipt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+)
+
+// Registry holds an ordered set of uniquely named rules.
+// It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewRegistry(rules ...Rule) (*Registry, error) {
+	reg := &Registry{}
+	for _, rule := range rules {
+		if err := reg.Register(rule); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// DefaultRegistry returns a new registry populated with DefaultRules.
+func DefaultRegistry() *Registry {
+	reg, err := NewRegistry(DefaultRules()...)
+	if err != nil {
+		panic(fmt.Errorf("failed to build default rule registry: %w", err))
+	}
+	return reg
+}
+
+// NewRegistryFromConfig returns a registry holding the rules enabled in cfg.
+func NewRegistryFromConfig(cfg config.Rules) (*Registry, error) {
+	rules, err := RulesFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewRegistry(rules...)
+}
+
+// Register appends rule to the end of the evaluation order.
+func (reg *Registry) Register(rule Rule) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if reg.indexOf(rule.Name()) >= 0 {
+		return fmt.Errorf("rule %q is already registered", rule.Name())
+	}
+	reg.rules = append(reg.rules, rule)
+	return nil
+}
+
+// Unregister removes the named rule and reports whether it was present.
+func (reg *Registry) Unregister(name string) bool {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	i := reg.indexOf(name)
+	if i < 0 {
+		return false
+	}
+	reg.rules = append(reg.rules[:i:i], reg.rules[i+1:]...)
+	return true
+}
+
+// Reorder sets the evaluation order. names must list every registered rule exactly once.
+func (reg *Registry) Reorder(names ...string) error {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if len(names) != len(reg.rules) {
+		return fmt.Errorf("expected %d rule names, got %d", len(reg.rules), len(names))
+	}
+
+	ordered := make([]Rule, 0, len(names))
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, dup := seen[name]; dup {
+			return fmt.Errorf("rule %q listed more than once", name)
+		}
+		seen[name] = struct{}{}
+
+		i := reg.indexOf(name)
+		if i < 0 {
+			return fmt.Errorf("rule %q is not registered", name)
+		}
+		ordered = append(ordered, reg.rules[i])
+	}
+	reg.rules = ordered
+	return nil
+}
+
+// Rules returns a snapshot of the registered rules in evaluation order.
+func (reg *Registry) Rules() []Rule {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+
+	rules := make([]Rule, len(reg.rules))
+	copy(rules, reg.rules)
+	return rules
+}
+
+// CalculatePoints evaluates every rule in order and returns the itemized result.
+func (reg *Registry) CalculatePoints(receipt models.Receipt) models.PointsBreakdown {
+	rules := reg.Rules()
+	breakdown := models.PointsBreakdown{Rules: make([]models.RuleAward, 0, len(rules))}
+	for _, rule := range rules {
+		points, reason := rule.Evaluate(receipt)
+		breakdown.Total += points
+		breakdown.Rules = append(breakdown.Rules, models.RuleAward{
+			Rule:   rule.Name(),
+			Points: points,
+			Reason: reason,
+		})
+	}
+	return breakdown
+}
+
+// Version identifies the registered rules, their order and their settings.
+// Registries with the same rules and settings report the same version.
+func (reg *Registry) Version() string {
+	h := sha256.New()
+	for _, rule := range reg.Rules() {
+		if fr, ok := rule.(*funcRule); ok {
+			fmt.Fprintf(h, "%s;", fr.name)
+			continue
+		}
+		fmt.Fprintf(h, "%s:%T%+v;", rule.Name(), rule, rule)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+func (reg *Registry) indexOf(name string) int {
+	for i, rule := range reg.rules {
+		if rule.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,142 @@
+package receipt
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+)
+
+func constRule(name string, points int) Rule {
+	return NewRule(name, func(models.Receipt) (int, string) { return points, "constant" })
+}
+
+func ruleNames(reg *Registry) []string {
+	var names []string
+	for _, rule := range reg.Rules() {
+		names = append(names, rule.Name())
+	}
+	return names
+}
+
+func TestDefaultRegistry_Order(t *testing.T) {
+	assert.Equal(t, []string{
+		RetailerNameRule,
+		RoundDollarRule,
+		QuarterMultipleRule,
+		ItemPairsRule,
+		ItemDescriptionRule,
+		OddDayRule,
+		PurchaseTimeRule,
+	}, ruleNames(DefaultRegistry()))
+}
+
+func TestRegistry_Register(t *testing.T) {
+	reg, err := NewRegistry(constRule("a", 1), constRule("b", 2))
+	require.NoError(t, err)
+
+	require.NoError(t, reg.Register(constRule("c", 4)))
+	assert.Equal(t, []string{"a", "b", "c"}, ruleNames(reg))
+	assert.Equal(t, 7, reg.CalculatePoints(models.Receipt{}).Total)
+
+	assert.Error(t, reg.Register(constRule("a", 10)))
+	assert.Equal(t, 7, reg.CalculatePoints(models.Receipt{}).Total)
+}
+
+func TestNewRegistry_DuplicateName(t *testing.T) {
+	_, err := NewRegistry(constRule("a", 1), constRule("a", 2))
+	assert.Error(t, err)
+}
+
+func TestRegistry_Unregister(t *testing.T) {
+	reg, err := NewRegistry(constRule("a", 1), constRule("b", 2), constRule("c", 4))
+	require.NoError(t, err)
+
+	assert.True(t, reg.Unregister("b"))
+	assert.False(t, reg.Unregister("b"))
+	assert.Equal(t, []string{"a", "c"}, ruleNames(reg))
+	assert.Equal(t, 5, reg.CalculatePoints(models.Receipt{}).Total)
+}
+
+func TestRegistry_Reorder(t *testing.T) {
+	tests := []struct {
+		name     string
+		order    []string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "Reversed",
+			order:    []string{"c", "b", "a"},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:     "MissingRule",
+			order:    []string{"c", "b"},
+			expected: []string{"a", "b", "c"},
+			wantErr:  true,
+		},
+		{
+			name:     "UnknownRule",
+			order:    []string{"c", "b", "d"},
+			expected: []string{"a", "b", "c"},
+			wantErr:  true,
+		},
+		{
+			name:     "DuplicateRule",
+			order:    []string{"c", "c", "a"},
+			expected: []string{"a", "b", "c"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg, err := NewRegistry(constRule("a", 1), constRule("b", 2), constRule("c", 4))
+			require.NoError(t, err)
+
+			err = reg.Reorder(tt.order...)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expected, ruleNames(reg))
+		})
+	}
+}
+
+func TestRegistry_RulesSnapshot(t *testing.T) {
+	reg, err := NewRegistry(constRule("a", 1))
+	require.NoError(t, err)
+
+	rules := reg.Rules()
+	rules[0] = constRule("z", 100)
+
+	assert.Equal(t, []string{"a"}, ruleNames(reg))
+}
+
+func TestRegistry_Version(t *testing.T) {
+	assert.Equal(t, DefaultRegistry().Version(), DefaultRegistry().Version())
+
+	reg := DefaultRegistry()
+	version := reg.Version()
+
+	reg.Unregister(OddDayRule)
+	assert.NotEqual(t, version, reg.Version())
+
+	cfg := config.DefaultRules()
+	cfg.RoundDollar.Points = 75
+	changed, err := NewRegistryFromConfig(cfg)
+	require.NoError(t, err)
+	assert.NotEqual(t, version, changed.Version())
+
+	a, err := NewRegistry(constRule("a", 1), constRule("b", 2))
+	require.NoError(t, err)
+	b, err := NewRegistry(constRule("a", 1), constRule("b", 2))
+	require.NoError(t, err)
+	assert.Equal(t, a.Version(), b.Version())
+	require.NoError(t, b.Reorder("b", "a"))
+	assert.NotEqual(t, a.Version(), b.Version())
+}
@@ -0,0 +1,196 @@
+package receipt
+
+import (
+	"fmt"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+const (
+	RetailerNameRule    = "retailer_name"
+	RoundDollarRule     = "round_dollar"
+	QuarterMultipleRule = "quarter_multiple"
+	ItemPairsRule       = "item_pairs"
+	ItemDescriptionRule = "item_description"
+	OddDayRule          = "odd_day"
+	PurchaseTimeRule    = "purchase_time"
+)
+
+// Rule awards points for a single aspect of a receipt and explains the award.
+// Name must be unique within a Registry.
+type Rule interface {
+	Name() string
+	Evaluate(receipt models.Receipt) (points int, reason string)
+}
+
+type funcRule struct {
+	name string
+	fn   func(models.Receipt) (int, string)
+}
+
+// NewRule adapts a plain function to the Rule interface.
+func NewRule(name string, fn func(models.Receipt) (int, string)) Rule {
+	return &funcRule{name: name, fn: fn}
+}
+
+func (r *funcRule) Name() string {
+	return r.name
+}
+
+func (r *funcRule) Evaluate(receipt models.Receipt) (int, string) {
+	return r.fn(receipt)
+}
+
+type retailerNameRule struct {
+	pointsPerCharacter int
+}
+
+func (retailerNameRule) Name() string { return RetailerNameRule }
+
+func (r retailerNameRule) Evaluate(rc models.Receipt) (int, string) {
+	return calculateRetailerPoints(rc.Retailer, r.pointsPerCharacter),
+		fmt.Sprintf("%d alphanumeric characters in retailer name", countAlphanumeric(rc.Retailer))
+}
+
+type roundDollarRule struct {
+	points int
+}
+
+func (roundDollarRule) Name() string { return RoundDollarRule }
+
+func (r roundDollarRule) Evaluate(rc models.Receipt) (int, string) {
+	if isRoundDollar(rc.Total) {
+		return r.points, fmt.Sprintf("total %s is a round dollar amount", rc.Total)
+	}
+	return 0, fmt.Sprintf("total %s is not a round dollar amount", rc.Total)
+}
+
+type quarterMultipleRule struct {
+	points int
+}
+
+func (quarterMultipleRule) Name() string { return QuarterMultipleRule }
+
+func (r quarterMultipleRule) Evaluate(rc models.Receipt) (int, string) {
+	if isQuarterMultiple(rc.Total) {
+		return r.points, fmt.Sprintf("total %s is a multiple of 0.25", rc.Total)
+	}
+	return 0, fmt.Sprintf("total %s is not a multiple of 0.25", rc.Total)
+}
+
+type itemPairsRule struct {
+	pointsPerPair int
+}
+
+func (itemPairsRule) Name() string { return ItemPairsRule }
+
+func (r itemPairsRule) Evaluate(rc models.Receipt) (int, string) {
+	return calculateItemCountBonus(len(rc.Items), r.pointsPerPair),
+		fmt.Sprintf("%d items make %d pairs", len(rc.Items), len(rc.Items)/2)
+}
+
+type itemDescriptionRule struct {
+	lengthMultiple int
+	pricePercent   int
+}
+
+func (itemDescriptionRule) Name() string { return ItemDescriptionRule }
+
+func (r itemDescriptionRule) Evaluate(rc models.Receipt) (int, string) {
+	matched := 0
+	for _, item := range rc.Items {
+		if descriptionMatches(item, r.lengthMultiple) {
+			matched++
+		}
+	}
+	return calculateItemDescriptionPoints(rc.Items, r.lengthMultiple, r.pricePercent),
+		fmt.Sprintf("%d item descriptions with a trimmed length that is a multiple of %d, each earning %d%% of its price",
+			matched, r.lengthMultiple, r.pricePercent)
+}
+
+type oddDayRule struct {
+	points int
+}
+
+func (oddDayRule) Name() string { return OddDayRule }
+
+func (r oddDayRule) Evaluate(rc models.Receipt) (int, string) {
+	d, err := time.Parse(time.DateOnly, rc.PurchaseDate)
+	if err != nil {
+		return 0, fmt.Sprintf("purchase date %q is not a valid date", rc.PurchaseDate)
+	}
+	if d.Day()%2 != 0 {
+		return r.points, fmt.Sprintf("purchase day %d is odd", d.Day())
+	}
+	return 0, fmt.Sprintf("purchase day %d is even", d.Day())
+}
+
+type purchaseTimeRule struct {
+	start  time.Time
+	end    time.Time
+	points int
+}
+
+func (purchaseTimeRule) Name() string { return PurchaseTimeRule }
+
+func (r purchaseTimeRule) Evaluate(rc models.Receipt) (int, string) {
+	points := calculatePurchaseTimeBonus(rc.PurchaseTime, r.start, r.end, r.points)
+	window := fmt.Sprintf("%s and %s", r.start.Format(config.RuleTimeLayout), r.end.Format(config.RuleTimeLayout))
+	if points > 0 {
+		return points, fmt.Sprintf("purchase time %s is between %s", rc.PurchaseTime, window)
+	}
+	return 0, fmt.Sprintf("purchase time %s is not between %s", rc.PurchaseTime, window)
+}
+
+// RulesFromConfig builds the enabled rules from cfg in evaluation order.
+func RulesFromConfig(cfg config.Rules) ([]Rule, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	var rules []Rule
+	if cfg.RetailerName.Enabled {
+		rules = append(rules, retailerNameRule{pointsPerCharacter: cfg.RetailerName.PointsPerCharacter})
+	}
+	if cfg.RoundDollar.Enabled {
+		rules = append(rules, roundDollarRule{points: cfg.RoundDollar.Points})
+	}
+	if cfg.QuarterMultiple.Enabled {
+		rules = append(rules, quarterMultipleRule{points: cfg.QuarterMultiple.Points})
+	}
+	if cfg.ItemPairs.Enabled {
+		rules = append(rules, itemPairsRule{pointsPerPair: cfg.ItemPairs.PointsPerPair})
+	}
+	if cfg.ItemDescription.Enabled {
+		rules = append(rules, itemDescriptionRule{
+			lengthMultiple: cfg.ItemDescription.LengthMultiple,
+			pricePercent:   cfg.ItemDescription.PricePercent,
+		})
+	}
+	if cfg.OddDay.Enabled {
+		rules = append(rules, oddDayRule{points: cfg.OddDay.Points})
+	}
+	if cfg.PurchaseTime.Enabled {
+		start, err := time.Parse(config.RuleTimeLayout, cfg.PurchaseTime.Start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid purchase_time start: %w", err)
+		}
+		end, err := time.Parse(config.RuleTimeLayout, cfg.PurchaseTime.End)
+		if err != nil {
+			return nil, fmt.Errorf("invalid purchase_time end: %w", err)
+		}
+		rules = append(rules, purchaseTimeRule{start: start, end: end, points: cfg.PurchaseTime.Points})
+	}
+
+	return rules, nil
+}
+
+// DefaultRules returns the standard rule set in evaluation order.
+func DefaultRules() []Rule {
+	rules, err := RulesFromConfig(config.DefaultRules())
+	if err != nil {
+		panic(fmt.Errorf("invalid default rules config: %w", err))
+	}
+	return rules
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"strings"
+	"testing"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+func newTestAPIKeyService(t *testing.T, adminKey string) (APIKeyService, storage.APIKeyStore) {
+	t.Helper()
+	store, ok := storage.NewInMemoryStore().(storage.APIKeyStore)
+	require.True(t, ok)
+	return NewAPIKeyService(zap.NewNop(), store, adminKey), store
+}
+
+func TestAPIKeyService_CreateAndAuthenticate(t *testing.T) {
+	svc, store := newTestAPIKeyService(t, "")
+	ctx := context.Background()
+
+	key, secret, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsWrite})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, apiKeyPrefix))
+	assert.NotContains(t, key.Hash, secret)
+
+	stored, err := store.APIKeyByHash(ctx, hashAPIKey(secret))
+	require.NoError(t, err, "only the hash of the secret is stored")
+	assert.Equal(t, key.ID, stored.ID)
+
+	got, err := svc.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.Equal(t, []string{models.ScopeReceiptsWrite}, got.Scopes)
+
+	_, err = svc.Authenticate(ctx, "tpk_unknown")
+	assert.ErrorIs(t, err, ierrors.ErrUnauthorized)
+}
+
+func TestAPIKeyService_Rotate(t *testing.T) {
+	svc, _ := newTestAPIKeyService(t, "")
+	ctx := context.Background()
+
+	key, oldSecret, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+
+	rotated, newSecret, err := svc.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldSecret, newSecret)
+	assert.NotNil(t, rotated.RotatedAt)
+
+	_, err = svc.Authenticate(ctx, oldSecret)
+	assert.ErrorIs(t, err, ierrors.ErrUnauthorized)
+	_, err = svc.Authenticate(ctx, newSecret)
+	assert.NoError(t, err)
+
+	_, _, err = svc.Rotate(ctx, "unknown")
+	assert.ErrorIs(t, err, ierrors.ErrAPIKeyNotFound)
+}
+
+func TestAPIKeyService_Revoke(t *testing.T) {
+	svc, _ := newTestAPIKeyService(t, "")
+	ctx := context.Background()
+
+	key, secret, err := svc.Create(ctx, "pos", []string{models.ScopeReceiptsRead})
+	require.NoError(t, err)
+
+	revoked, err := svc.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = svc.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ierrors.ErrUnauthorized)
+	_, _, err = svc.Rotate(ctx, key.ID)
+	assert.ErrorIs(t, err, ierrors.ErrAPIKeyRevoked)
+	_, err = svc.Revoke(ctx, "unknown")
+	assert.ErrorIs(t, err, ierrors.ErrAPIKeyNotFound)
+
+	keys, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+}
+
+func TestAPIKeyService_AdminKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		adminKey string
+		secret   string
+		wantErr  error
+	}{
+		{name: "configured key", adminKey: "configured-admin-secret", secret: "configured-admin-secret"},
+		{name: "wrong key", adminKey: "configured-admin-secret", secret: "configured-admin-secreT", wantErr: ierrors.ErrUnauthorized},
+		{name: "no admin key configured", secret: "", wantErr: ierrors.ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, _ := newTestAPIKeyService(t, tt.adminKey)
+
+			key, err := svc.Authenticate(context.Background(), tt.secret)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, AdminKeyID, key.ID)
+			assert.Equal(t, []string{models.ScopeAdmin}, key.Scopes)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+// CustomerService reads the points customers have collected. Customers are not
+// registered; one exists as soon as a receipt names it, and unknown customers
+// have a balance of zero.
+type CustomerService interface {
+	GetBalance(ctx context.Context, customerID string) (int, error)
+	GetLedger(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error)
+	// Redeem debits points from the customer. It returns ierrors.ErrInsufficientBalance
+	// if the balance does not cover them.
+	Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error)
+}
+
+type customerService struct {
+	storage storage.Storage
+	log     *zap.Logger
+}
+
+func NewCustomerService(l *zap.Logger, s storage.Storage) CustomerService {
+	return &customerService{
+		storage: s,
+		log:     l,
+	}
+}
+
+func (cs *customerService) GetBalance(ctx context.Context, customerID string) (int, error) {
+	balance, err := cs.storage.Balance(ctx, customerID)
+	if err != nil {
+		cs.log.Error("Error retrieving balance", zap.String("customerId", customerID), zap.Error(err))
+		return 0, fmt.Errorf("error retrieving balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+func (cs *customerService) GetLedger(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error) {
+	page, err := cs.storage.Ledger(ctx, customerID, q)
+	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return storage.LedgerPage{}, ierrors.ErrInvalidCursor
+		}
+		cs.log.Error("Error retrieving ledger", zap.String("customerId", customerID), zap.Error(err))
+		return storage.LedgerPage{}, fmt.Errorf("error retrieving ledger: %w", err)
+	}
+
+	return page, nil
+}
+
+func (cs *customerService) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	entry, err := cs.storage.Redeem(ctx, customerID, points, description)
+	if err != nil {
+		if errors.Is(err, storage.ErrInsufficientBalance) {
+			return models.LedgerEntry{}, ierrors.ErrInsufficientBalance
+		}
+		cs.log.Error("Error redeeming points", zap.String("customerId", customerID), zap.Error(err))
+		return models.LedgerEntry{}, fmt.Errorf("error redeeming points: %w", err)
+	}
+
+	cs.log.Info("Redeemed points", zap.String("customerId", customerID), zap.Int("points", points), zap.Int("balance", entry.Balance))
+	return entry, nil
+}
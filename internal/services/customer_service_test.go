@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+func TestGetBalance(t *testing.T) {
+	mockStorage := &mockStorage{
+		balanceFunc: func(ctx context.Context, customerID string) (int, error) {
+			if customerID == "broken" {
+				return 0, errors.New("storage error")
+			}
+			return 42, nil
+		},
+	}
+	cs := NewCustomerService(zap.NewNop(), mockStorage)
+
+	balance, err := cs.GetBalance(context.Background(), "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 42, balance)
+
+	_, err = cs.GetBalance(context.Background(), "broken")
+	assert.ErrorContains(t, err, "storage error")
+}
+
+func TestGetLedger(t *testing.T) {
+	page := storage.LedgerPage{Entries: []models.LedgerEntry{{ID: "entry-id", CustomerID: "alice"}}, NextCursor: "next"}
+
+	tests := []struct {
+		name      string
+		ledgerErr error
+		want      storage.LedgerPage
+		wantErr   error
+	}{
+		{name: "page", want: page},
+		{name: "invalid cursor", ledgerErr: storage.ErrInvalidCursor, wantErr: ierrors.ErrInvalidCursor},
+		{name: "storage error", ledgerErr: errors.New("storage error"), wantErr: errors.New("storage error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := storage.LedgerQuery{Limit: 5, Cursor: "cursor"}
+			mockStorage := &mockStorage{
+				ledgerFunc: func(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error) {
+					assert.Equal(t, "alice", customerID)
+					assert.Equal(t, query, q)
+					if tt.ledgerErr != nil {
+						return storage.LedgerPage{}, tt.ledgerErr
+					}
+					return page, nil
+				},
+			}
+			cs := NewCustomerService(zap.NewNop(), mockStorage)
+
+			got, err := cs.GetLedger(context.Background(), "alice", query)
+			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+				if errors.Is(tt.wantErr, ierrors.ErrInvalidCursor) {
+					assert.ErrorIs(t, err, ierrors.ErrInvalidCursor)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRedeem(t *testing.T) {
+	tests := []struct {
+		name      string
+		redeemErr error
+		wantErr   error
+	}{
+		{name: "redeemed"},
+		{name: "insufficient balance", redeemErr: storage.ErrInsufficientBalance, wantErr: ierrors.ErrInsufficientBalance},
+		{name: "storage error", redeemErr: errors.New("storage error"), wantErr: errors.New("storage error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := models.LedgerEntry{ID: "entry-id", CustomerID: "alice", Type: models.LedgerEntryRedemption, Points: -10, Balance: 5}
+			mockStorage := &mockStorage{
+				redeemFunc: func(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+					assert.Equal(t, "alice", customerID)
+					assert.Equal(t, 10, points)
+					assert.Equal(t, "coffee", description)
+					if tt.redeemErr != nil {
+						return models.LedgerEntry{}, tt.redeemErr
+					}
+					return entry, nil
+				},
+			}
+			cs := NewCustomerService(zap.NewNop(), mockStorage)
+
+			got, err := cs.Redeem(context.Background(), "alice", 10, "coffee")
+			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+				if errors.Is(tt.wantErr, ierrors.ErrInsufficientBalance) {
+					assert.ErrorIs(t, err, ierrors.ErrInsufficientBalance)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, entry, got)
+		})
+	}
+}
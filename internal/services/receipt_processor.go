@@ -2,8 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"net/http"
+	"ticket-processor/internal/config"
 	"ticket-processor/internal/ierrors"
 	"ticket-processor/internal/models"
 	"ticket-processor/internal/receipt"
@@ -11,58 +18,266 @@ import (
 	"time"
 )
 
+// ProcessOutcome is the result of submitting a receipt.
+type ProcessOutcome struct {
+	// ID is the receipt's ID, or the original's if it was a duplicate.
+	ID string
+	// Points are the points awarded to the receipt, zero for duplicates, which
+	// are not credited again.
+	Points int
+	// Duplicate is set if the receipt matched an earlier one under
+	// config.DuplicatePolicyReturnOriginal.
+	Duplicate bool
+}
+
 type ReceiptProcessor interface {
 	ProcessReceipt(ctx context.Context, receipt models.Receipt) (string, error)
+	// ProcessReceiptOutcome is ProcessReceipt, but also reports the points
+	// awarded and whether the receipt was a duplicate.
+	ProcessReceiptOutcome(ctx context.Context, receipt models.Receipt) (ProcessOutcome, error)
+	// ProcessReceiptWithID stores the receipt under id unless it is a duplicate,
+	// in which case the original ID is returned as with ProcessReceipt.
+	ProcessReceiptWithID(ctx context.Context, id string, receipt models.Receipt) (string, error)
+	// GetPoints returns the points awarded for the receipt, which are zero once
+	// it has been reversed.
 	GetPoints(ctx context.Context, id string) (int, error)
+	// GetPointsBreakdown returns the points awarded by each rule, which are
+	// likewise zero once the receipt has been reversed.
+	GetPointsBreakdown(ctx context.Context, id string) (models.PointsBreakdown, error)
+	GetReceipt(ctx context.Context, id string) (models.ReceiptRecord, error)
+	ListReceipts(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error)
+	// ReverseReceipt voids the receipt and takes its points back from the customer.
+	// The returned ledger entry is nil for receipts without a customer.
+	ReverseReceipt(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error)
+}
+
+// EventPublisher is told about every newly stored receipt. It must not block.
+type EventPublisher interface {
+	PublishReceiptProcessed(ctx context.Context, record models.ReceiptRecord)
+}
+
+// MultiPublisher returns an EventPublisher that publishes to every non-nil publisher in order.
+func MultiPublisher(publishers ...EventPublisher) EventPublisher {
+	var nonNil multiPublisher
+	for _, p := range publishers {
+		if p != nil {
+			nonNil = append(nonNil, p)
+		}
+	}
+	return nonNil
+}
+
+type multiPublisher []EventPublisher
+
+func (mp multiPublisher) PublishReceiptProcessed(ctx context.Context, record models.ReceiptRecord) {
+	for _, p := range mp {
+		p.PublishReceiptProcessed(ctx, record)
+	}
 }
 
 type receiptProcessor struct {
-	storage storage.Storage
-	cache   storage.Cache
-	log     *zap.Logger
+	storage         storage.Storage
+	cache           storage.Cache
+	rules           *receipt.Registry
+	duplicatePolicy string
+	events          EventPublisher
+	log             *zap.Logger
 }
 
-func NewReceiptProcessor(l *zap.Logger, s storage.Storage, c storage.Cache) ReceiptProcessor {
+// NewReceiptProcessor creates a processor. duplicatePolicy decides how resubmitted
+// receipts are handled: config.DuplicatePolicyReturnOriginal or config.DuplicatePolicyReject.
+// events may be nil; duplicates are not published.
+func NewReceiptProcessor(l *zap.Logger, s storage.Storage, c storage.Cache, rules *receipt.Registry, duplicatePolicy string, events EventPublisher) ReceiptProcessor {
 	return &receiptProcessor{
-		storage: s,
-		cache:   c,
-		log:     l,
+		storage:         s,
+		cache:           c,
+		rules:           rules,
+		duplicatePolicy: duplicatePolicy,
+		events:          events,
+		log:             l,
 	}
 }
 
 func (rp *receiptProcessor) ProcessReceipt(ctx context.Context, r models.Receipt) (string, error) {
-	points := receipt.CalculatePoints(r)
+	return rp.ProcessReceiptWithID(ctx, "", r)
+}
+
+func (rp *receiptProcessor) ProcessReceiptOutcome(ctx context.Context, r models.Receipt) (ProcessOutcome, error) {
+	return rp.process(ctx, "", r)
+}
+
+func (rp *receiptProcessor) ProcessReceiptWithID(ctx context.Context, id string, r models.Receipt) (string, error) {
+	outcome, err := rp.process(ctx, id, r)
+	return outcome.ID, err
+}
+
+func (rp *receiptProcessor) process(ctx context.Context, id string, r models.Receipt) (_ ProcessOutcome, err error) {
+	ctx, span := startSpan(ctx, "ReceiptProcessor.ProcessReceipt")
+	defer func() { endSpan(span, err) }()
+
+	record := models.ReceiptRecord{
+		ID:           id,
+		Receipt:      r,
+		Points:       rp.rules.CalculatePoints(r),
+		ProcessedAt:  time.Now().UTC(),
+		RulesVersion: rp.rules.Version(),
+		Fingerprint:  receipt.Fingerprint(r),
+	}
 
-	id, err := rp.storage.Store(ctx, points)
+	span.SetAttributes(attribute.Int("receipt.points", record.Points.Total))
+
+	id, err = rp.storage.Store(ctx, record)
+	if errors.Is(err, storage.ErrDuplicate) {
+		rp.log.Info("Duplicate receipt submitted", zap.String("originalId", id), zap.String("policy", rp.duplicatePolicy))
+		if rp.duplicatePolicy == config.DuplicatePolicyReject {
+			return ProcessOutcome{}, ierrors.ErrDuplicateReceipt
+		}
+		return ProcessOutcome{ID: id, Duplicate: true}, nil
+	}
 	if err != nil {
 		rp.log.Error("Error storing processed receipt", zap.Error(err))
-		return "", fmt.Errorf("error storing receipt: %w", err)
+		return ProcessOutcome{}, fmt.Errorf("error storing receipt: %w", err)
 	}
 
-	go rp.updateCache(id, points)
+	span.SetAttributes(attribute.String("receipt.id", id))
+	rp.cachePoints(ctx, id, record.Points.Total)
+
+	if rp.events != nil {
+		record.ID = id
+		rp.events.PublishReceiptProcessed(ctx, record)
+	}
 
-	return id, nil
+	return ProcessOutcome{ID: id, Points: record.Points.Total}, nil
 }
 
-func (rp *receiptProcessor) GetPoints(ctx context.Context, id string) (int, error) {
+func (rp *receiptProcessor) GetPoints(ctx context.Context, id string) (_ int, err error) {
+	ctx, span := startSpan(ctx, "ReceiptProcessor.GetPoints", attribute.String("receipt.id", id))
+	defer func() { endSpan(span, err) }()
+
 	points, ok := rp.cache.Load(ctx, id)
 	if ok {
 		return points, nil
 	}
 
-	points, ok = rp.storage.Retrieve(ctx, id)
-	if !ok {
-		return 0, ierrors.ErrNotFound
+	record, err := rp.GetReceipt(ctx, id)
+	if err != nil {
+		return 0, err
 	}
 
-	go rp.updateCache(id, points)
+	points = record.Points.Total
+	if record.VoidedAt != nil {
+		points = 0
+	}
+	// id may be the alias of a duplicate; only the original's ID is ever cached
+	// so that a reversal drops every cached copy.
+	rp.cachePoints(ctx, record.ID, points)
 	return points, nil
 }
 
-func (rp *receiptProcessor) updateCache(id string, points int) {
-	cacheCtx := context.Background()
-	err := rp.cache.Set(cacheCtx, id, points, time.Minute*5)
+func (rp *receiptProcessor) GetPointsBreakdown(ctx context.Context, id string) (models.PointsBreakdown, error) {
+	record, err := rp.GetReceipt(ctx, id)
+	if err != nil {
+		return models.PointsBreakdown{}, err
+	}
+
+	if record.VoidedAt == nil {
+		return record.Points, nil
+	}
+	voided := models.PointsBreakdown{Rules: make([]models.RuleAward, len(record.Points.Rules))}
+	for i, award := range record.Points.Rules {
+		award.Points = 0
+		voided.Rules[i] = award
+	}
+	return voided, nil
+}
+
+func (rp *receiptProcessor) GetReceipt(ctx context.Context, id string) (models.ReceiptRecord, error) {
+	record, err := rp.storage.Retrieve(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return models.ReceiptRecord{}, ierrors.ErrNotFound
+		}
+		rp.log.Error("Error retrieving receipt", zap.String("id", id), zap.Error(err))
+		return models.ReceiptRecord{}, fmt.Errorf("error retrieving receipt: %w", err)
+	}
+
+	return record, nil
+}
+
+func (rp *receiptProcessor) ListReceipts(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error) {
+	page, err := rp.storage.Query(ctx, q)
 	if err != nil {
+		if errors.Is(err, storage.ErrInvalidCursor) {
+			return storage.ReceiptPage{}, ierrors.ErrInvalidCursor
+		}
+		rp.log.Error("Error listing receipts", zap.Error(err))
+		return storage.ReceiptPage{}, fmt.Errorf("error listing receipts: %w", err)
+	}
+
+	return page, nil
+}
+
+func (rp *receiptProcessor) ReverseReceipt(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	record, entry, err := rp.storage.Void(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			return models.ReceiptRecord{}, nil, ierrors.ErrNotFound
+		case errors.Is(err, storage.ErrAlreadyVoided):
+			return models.ReceiptRecord{}, nil, ierrors.ErrReceiptVoided
+		}
+		rp.log.Error("Error reversing receipt", zap.String("id", id), zap.Error(err))
+		return models.ReceiptRecord{}, nil, fmt.Errorf("error reversing receipt: %w", err)
+	}
+
+	// The reversal is committed, so the cached points must go even if the client has gone away.
+	if err := rp.cache.Delete(context.WithoutCancel(ctx), record.ID); err != nil {
+		rp.log.Error("Error removing reversed receipt from cache", zap.String("id", id), zap.Error(err))
+	}
+
+	rp.log.Info("Reversed receipt", zap.String("id", id), zap.String("customerId", record.Receipt.CustomerID))
+	return record, entry, nil
+}
+
+// cachePoints caches the points of receipt id. A reversal may void the receipt
+// and drop its cache entry after the points were read but before they are
+// cached, so unless the points are already zero the receipt is read again
+// afterwards and the entry dropped if it has been voided in the meantime. The
+// cache is updated even if the client has gone away.
+func (rp *receiptProcessor) cachePoints(ctx context.Context, id string, points int) {
+	ctx = context.WithoutCancel(ctx)
+	if err := rp.cache.Set(ctx, id, points, time.Minute*5); err != nil {
 		rp.log.Error("Error setting cache", zap.Error(err))
+		return
+	}
+	if points == 0 {
+		return
+	}
+
+	record, err := rp.storage.Retrieve(ctx, id)
+	if err == nil && record.VoidedAt == nil {
+		return
+	}
+	if err != nil {
+		rp.log.Error("Error checking cached receipt", zap.String("id", id), zap.Error(err))
+	}
+	if err := rp.cache.Delete(ctx, id); err != nil {
+		rp.log.Error("Error removing reversed receipt from cache", zap.String("id", id), zap.Error(err))
+	}
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer("ticket-processor/internal/services").Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it failed unless err is nil or one of the errors
+// the API answers with a 4xx status.
+func endSpan(span trace.Span, err error) {
+	defer span.End()
+	var apiErr *ierrors.ErrResponse
+	if err == nil || (errors.As(err, &apiErr) && apiErr.HTTPCode < http.StatusInternalServerError) {
+		return
 	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
 }
@@ -4,28 +4,68 @@ import (
 	"context"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"strconv"
+	"sync"
 	"testing"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/ierrors"
 	"ticket-processor/internal/models"
+	"ticket-processor/internal/receipt"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/tracing/tracingtest"
 	"time"
 )
 
 type mockStorage struct {
-	storeFunc    func(ctx context.Context, points int) (string, error)
-	retrieveFunc func(ctx context.Context, key string) (int, bool)
+	storeFunc    func(ctx context.Context, record models.ReceiptRecord) (string, error)
+	retrieveFunc func(ctx context.Context, id string) (models.ReceiptRecord, error)
+	queryFunc    func(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error)
+	balanceFunc  func(ctx context.Context, customerID string) (int, error)
+	ledgerFunc   func(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error)
+	redeemFunc   func(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error)
+	voidFunc     func(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error)
 }
 
-func (m *mockStorage) Store(ctx context.Context, points int) (string, error) {
-	return m.storeFunc(ctx, points)
+func (m *mockStorage) Store(ctx context.Context, record models.ReceiptRecord) (string, error) {
+	return m.storeFunc(ctx, record)
 }
 
-func (m *mockStorage) Retrieve(ctx context.Context, key string) (int, bool) {
-	return m.retrieveFunc(ctx, key)
+func (m *mockStorage) Retrieve(ctx context.Context, id string) (models.ReceiptRecord, error) {
+	if m.retrieveFunc == nil {
+		return models.ReceiptRecord{}, storage.ErrNotFound
+	}
+	return m.retrieveFunc(ctx, id)
+}
+
+func (m *mockStorage) Query(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error) {
+	return m.queryFunc(ctx, q)
+}
+
+func (m *mockStorage) Balance(ctx context.Context, customerID string) (int, error) {
+	return m.balanceFunc(ctx, customerID)
+}
+
+func (m *mockStorage) Ledger(ctx context.Context, customerID string, q storage.LedgerQuery) (storage.LedgerPage, error) {
+	return m.ledgerFunc(ctx, customerID, q)
+}
+
+func (m *mockStorage) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	return m.redeemFunc(ctx, customerID, points, description)
+}
+
+func (m *mockStorage) Void(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	return m.voidFunc(ctx, id)
 }
 
 type mockCache struct {
-	getFunc func(ctx context.Context, id string) (int, bool)
-	setFunc func(ctx context.Context, id string, points int, ttl time.Duration) error
+	getFunc    func(ctx context.Context, id string) (int, bool)
+	setFunc    func(ctx context.Context, id string, points int, ttl time.Duration) error
+	deleteFunc func(ctx context.Context, id string) error
 }
 
 func (m *mockCache) Load(ctx context.Context, id string) (int, bool) {
@@ -36,9 +76,16 @@ func (m *mockCache) Set(ctx context.Context, id string, points int, ttl time.Dur
 	return m.setFunc(ctx, id, points, ttl)
 }
 
+func (m *mockCache) Delete(ctx context.Context, id string) error {
+	if m.deleteFunc == nil {
+		return nil
+	}
+	return m.deleteFunc(ctx, id)
+}
+
 func TestProcessReceipt_Success(t *testing.T) {
 	mockStorage := &mockStorage{
-		storeFunc: func(ctx context.Context, points int) (string, error) {
+		storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
 			return "receipt-id", nil
 		},
 	}
@@ -48,16 +95,16 @@ func TestProcessReceipt_Success(t *testing.T) {
 		},
 	}
 	logger := zap.NewNop()
-	rp := NewReceiptProcessor(logger, mockStorage, mockCache)
+	rp := NewReceiptProcessor(logger, mockStorage, mockCache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
 
 	receipt := models.Receipt{
 		Retailer:     "Target",
 		PurchaseDate: "2022-01-01",
 		PurchaseTime: "13:01",
 		Items: []models.Item{
-			{ShortDescription: "Mountain Dew 12PK", Price: 6.49},
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
 		},
-		Total: 6.49,
+		Total: 649,
 	}
 
 	id, err := rp.ProcessReceipt(context.Background(), receipt)
@@ -67,7 +114,7 @@ func TestProcessReceipt_Success(t *testing.T) {
 
 func TestProcessReceipt_StorageError(t *testing.T) {
 	mockStorage := &mockStorage{
-		storeFunc: func(ctx context.Context, points int) (string, error) {
+		storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
 			return "", errors.New("storage error")
 		},
 	}
@@ -77,19 +124,469 @@ func TestProcessReceipt_StorageError(t *testing.T) {
 		},
 	}
 	logger := zap.NewNop()
-	rp := NewReceiptProcessor(logger, mockStorage, mockCache)
+	rp := NewReceiptProcessor(logger, mockStorage, mockCache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
 
 	receipt := models.Receipt{
 		Retailer:     "Target",
 		PurchaseDate: "2022-01-01",
 		PurchaseTime: "13:01",
 		Items: []models.Item{
-			{ShortDescription: "Mountain Dew 12PK", Price: 6.49},
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
 		},
-		Total: 6.49,
+		Total: 649,
 	}
 
 	id, err := rp.ProcessReceipt(context.Background(), receipt)
 	assert.Error(t, err)
 	assert.Empty(t, id)
 }
+
+func TestProcessReceipt_UsesConfiguredRules(t *testing.T) {
+	var storedPoints int
+	mockStorage := &mockStorage{
+		storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
+			storedPoints = record.Points.Total
+			return "receipt-id", nil
+		},
+	}
+	mockCache := &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error {
+			return nil
+		},
+	}
+	rules, err := receipt.NewRegistry(receipt.NewRule("flat", func(models.Receipt) (int, string) { return 42, "flat bonus" }))
+	assert.NoError(t, err)
+	rp := NewReceiptProcessor(zap.NewNop(), mockStorage, mockCache, rules, config.DuplicatePolicyReturnOriginal, nil)
+
+	_, err = rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, storedPoints)
+}
+
+func TestProcessReceipt_StoresFullRecord(t *testing.T) {
+	var stored models.ReceiptRecord
+	mockStorage := &mockStorage{
+		storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
+			stored = record
+			return "receipt-id", nil
+		},
+	}
+	mockCache := &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error {
+			return nil
+		},
+	}
+	rules := receipt.DefaultRegistry()
+	rp := NewReceiptProcessor(zap.NewNop(), mockStorage, mockCache, rules, config.DuplicatePolicyReturnOriginal, nil)
+
+	r := models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []models.Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: 649},
+		},
+		Total: 649,
+	}
+
+	_, err := rp.ProcessReceipt(context.Background(), r)
+	assert.NoError(t, err)
+	assert.Equal(t, r, stored.Receipt)
+	assert.Equal(t, rules.CalculatePoints(r), stored.Points)
+	assert.Equal(t, rules.Version(), stored.RulesVersion)
+	assert.Equal(t, receipt.Fingerprint(r), stored.Fingerprint)
+	assert.WithinDuration(t, time.Now(), stored.ProcessedAt, time.Minute)
+}
+
+func TestProcessReceipt_Duplicate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantID  string
+		wantErr error
+	}{
+		{name: "return original", policy: config.DuplicatePolicyReturnOriginal, wantID: "original-id"},
+		{name: "reject", policy: config.DuplicatePolicyReject, wantErr: ierrors.ErrDuplicateReceipt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{
+				storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
+					return "original-id", storage.ErrDuplicate
+				},
+			}
+			mockCache := &mockCache{
+				setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error {
+					t.Error("duplicates must not update the cache")
+					return nil
+				},
+			}
+			rp := NewReceiptProcessor(zap.NewNop(), mockStorage, mockCache, receipt.DefaultRegistry(), tt.policy, nil)
+
+			id, err := rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target"})
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantID, id)
+		})
+	}
+}
+
+func TestProcessReceiptOutcome(t *testing.T) {
+	rp := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error { return nil },
+	}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	r := models.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: 100}
+
+	first, err := rp.ProcessReceiptOutcome(context.Background(), r)
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.ID)
+	assert.Equal(t, receipt.DefaultRegistry().CalculatePoints(r).Total, first.Points)
+	assert.False(t, first.Duplicate)
+
+	second, err := rp.ProcessReceiptOutcome(context.Background(), r)
+	require.NoError(t, err)
+	assert.Equal(t, ProcessOutcome{ID: first.ID, Duplicate: true}, second)
+}
+
+func TestProcessReceipt_DuplicateDetectedByStorage(t *testing.T) {
+	rp := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error { return nil },
+	}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+	first, err := rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target", Total: 649})
+	assert.NoError(t, err)
+	second, err := rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: " TARGET ", Total: 649})
+	assert.NoError(t, err)
+	third, err := rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target", Total: 650})
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.NotEqual(t, first, third)
+}
+
+func TestProcessReceiptWithID(t *testing.T) {
+	rp := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error { return nil },
+	}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+	id, err := rp.ProcessReceiptWithID(context.Background(), "chosen-id", models.Receipt{Retailer: "Target", Total: 649})
+	assert.NoError(t, err)
+	assert.Equal(t, "chosen-id", id)
+
+	record, err := rp.GetReceipt(context.Background(), "chosen-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "Target", record.Receipt.Retailer)
+
+	id, err = rp.ProcessReceiptWithID(context.Background(), "other-id", models.Receipt{Retailer: "Target", Total: 649})
+	assert.NoError(t, err)
+	assert.Equal(t, "chosen-id", id, "duplicates resolve to the original ID")
+}
+
+func TestGetReceipt(t *testing.T) {
+	record := models.ReceiptRecord{
+		ID:           "receipt-id",
+		Receipt:      models.Receipt{Retailer: "Target"},
+		Points:       models.PointsBreakdown{Total: 42, Rules: []models.RuleAward{{Rule: "flat", Points: 42, Reason: "flat bonus"}}},
+		RulesVersion: "v1",
+	}
+	mockStorage := &mockStorage{
+		retrieveFunc: func(ctx context.Context, id string) (models.ReceiptRecord, error) {
+			switch id {
+			case "receipt-id":
+				return record, nil
+			case "broken":
+				return models.ReceiptRecord{}, errors.New("disk on fire")
+			default:
+				return models.ReceiptRecord{}, storage.ErrNotFound
+			}
+		},
+	}
+	rp := NewReceiptProcessor(zap.NewNop(), mockStorage, &mockCache{}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+	got, err := rp.GetReceipt(context.Background(), "receipt-id")
+	assert.NoError(t, err)
+	assert.Equal(t, record, got)
+
+	breakdown, err := rp.GetPointsBreakdown(context.Background(), "receipt-id")
+	assert.NoError(t, err)
+	assert.Equal(t, record.Points, breakdown)
+
+	_, err = rp.GetReceipt(context.Background(), "missing")
+	assert.ErrorIs(t, err, ierrors.ErrNotFound)
+
+	_, err = rp.GetPointsBreakdown(context.Background(), "missing")
+	assert.ErrorIs(t, err, ierrors.ErrNotFound)
+
+	_, err = rp.GetReceipt(context.Background(), "broken")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ierrors.ErrNotFound)
+}
+
+func TestListReceipts(t *testing.T) {
+	page := storage.ReceiptPage{Records: []models.ReceiptRecord{{ID: "receipt-id"}}, NextCursor: "next"}
+
+	tests := []struct {
+		name     string
+		queryErr error
+		want     storage.ReceiptPage
+		wantErr  error
+	}{
+		{name: "page", want: page},
+		{name: "invalid cursor", queryErr: storage.ErrInvalidCursor, wantErr: ierrors.ErrInvalidCursor},
+		{name: "storage error", queryErr: errors.New("storage error"), wantErr: errors.New("storage error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := storage.ReceiptQuery{Retailer: "Target", Limit: 5}
+			mockStorage := &mockStorage{
+				queryFunc: func(ctx context.Context, q storage.ReceiptQuery) (storage.ReceiptPage, error) {
+					assert.Equal(t, query, q)
+					if tt.queryErr != nil {
+						return storage.ReceiptPage{}, tt.queryErr
+					}
+					return page, nil
+				},
+			}
+			rp := NewReceiptProcessor(zap.NewNop(), mockStorage, &mockCache{}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+			got, err := rp.ListReceipts(context.Background(), query)
+			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+				if errors.Is(tt.wantErr, ierrors.ErrInvalidCursor) {
+					assert.ErrorIs(t, err, ierrors.ErrInvalidCursor)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReverseReceipt(t *testing.T) {
+	entry := &models.LedgerEntry{ID: "entry-id", Type: models.LedgerEntryReversal, Points: -24}
+
+	tests := []struct {
+		name      string
+		voidErr   error
+		wantEntry *models.LedgerEntry
+		wantErr   error
+	}{
+		{name: "reversed", wantEntry: entry},
+		{name: "not found", voidErr: storage.ErrNotFound, wantErr: ierrors.ErrNotFound},
+		{name: "already voided", voidErr: storage.ErrAlreadyVoided, wantErr: ierrors.ErrReceiptVoided},
+		{name: "storage error", voidErr: errors.New("storage error"), wantErr: errors.New("storage error")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := &mockStorage{
+				voidFunc: func(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+					assert.Equal(t, "receipt-id", id)
+					if tt.voidErr != nil {
+						return models.ReceiptRecord{}, nil, tt.voidErr
+					}
+					return models.ReceiptRecord{ID: id}, entry, nil
+				},
+			}
+			var deleted []string
+			mockCache := &mockCache{
+				deleteFunc: func(ctx context.Context, id string) error {
+					deleted = append(deleted, id)
+					return nil
+				},
+			}
+			rp := NewReceiptProcessor(zap.NewNop(), mockStorage, mockCache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+			record, got, err := rp.ReverseReceipt(context.Background(), "receipt-id")
+			if tt.wantErr != nil {
+				assert.ErrorContains(t, err, tt.wantErr.Error())
+				var want *ierrors.ErrResponse
+				if errors.As(tt.wantErr, &want) {
+					assert.ErrorIs(t, err, want)
+				}
+				assert.Empty(t, deleted)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "receipt-id", record.ID)
+			assert.Equal(t, tt.wantEntry, got)
+			assert.Equal(t, []string{"receipt-id"}, deleted, "the cached points are dropped")
+		})
+	}
+}
+
+func TestGetPoints_Reversed(t *testing.T) {
+	cache := storage.NewInMemoryCache(zap.NewNop(), 10, time.Minute)
+	t.Cleanup(cache.Close)
+	rp := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), cache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	ctx := context.Background()
+
+	id, err := rp.ProcessReceipt(ctx, models.Receipt{Retailer: "Target", Total: 100})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, id, 106, time.Minute))
+
+	_, _, err = rp.ReverseReceipt(ctx, id)
+	require.NoError(t, err)
+	_, ok := cache.Load(ctx, id)
+	assert.False(t, ok, "reversing drops the cached points")
+
+	points, err := rp.GetPoints(ctx, id)
+	require.NoError(t, err)
+	assert.Zero(t, points)
+}
+
+// interleavingCache calls beforeSet once, before the first Set.
+type interleavingCache struct {
+	storage.Cache
+	once      sync.Once
+	beforeSet func()
+}
+
+func (c *interleavingCache) Set(ctx context.Context, key string, value int, expiration time.Duration) error {
+	c.once.Do(c.beforeSet)
+	return c.Cache.Set(ctx, key, value, expiration)
+}
+
+func TestGetPoints_ReversedWhileCaching(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	inner := storage.NewInMemoryCache(zap.NewNop(), 10, time.Minute)
+	t.Cleanup(inner.Close)
+	record := models.ReceiptRecord{ID: "receipt-id", Receipt: models.Receipt{Retailer: "Target", Total: 100}, Points: models.PointsBreakdown{Total: 106}}
+	_, err := store.Store(context.Background(), record)
+	require.NoError(t, err)
+
+	cache := &interleavingCache{Cache: inner}
+	rp := NewReceiptProcessor(zap.NewNop(), store, cache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	// The reversal completes after GetPoints has read the record but before it caches the points.
+	cache.beforeSet = func() {
+		_, _, err := rp.ReverseReceipt(context.Background(), "receipt-id")
+		require.NoError(t, err)
+	}
+
+	points, err := rp.GetPoints(context.Background(), "receipt-id")
+	require.NoError(t, err)
+	assert.Equal(t, 106, points, "the points were read before the reversal")
+
+	points, err = rp.GetPoints(context.Background(), "receipt-id")
+	require.NoError(t, err)
+	assert.Zero(t, points, "the stale points are not left in the cache")
+}
+
+func TestGetPoints_ConcurrentReverse(t *testing.T) {
+	store := storage.NewInMemoryStore()
+	cache := storage.NewInMemoryCache(zap.NewNop(), 100, time.Minute)
+	t.Cleanup(cache.Close)
+	rp := NewReceiptProcessor(zap.NewNop(), store, cache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	ctx := context.Background()
+
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i] = "receipt-" + strconv.Itoa(i)
+		_, err := store.Store(ctx, models.ReceiptRecord{ID: ids[i], Points: models.PointsBreakdown{Total: 10 + i}})
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := rp.GetPoints(ctx, id)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := rp.ReverseReceipt(ctx, id)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		points, err := rp.GetPoints(ctx, id)
+		require.NoError(t, err)
+		assert.Zero(t, points, id)
+	}
+}
+
+type recordingPublisher struct {
+	records []models.ReceiptRecord
+}
+
+func (p *recordingPublisher) PublishReceiptProcessed(_ context.Context, record models.ReceiptRecord) {
+	p.records = append(p.records, record)
+}
+
+func TestProcessReceipt_PublishesEvents(t *testing.T) {
+	events := &recordingPublisher{}
+	rp := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), &mockCache{
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error { return nil },
+	}, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, events)
+
+	id, err := rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target", Total: 649})
+	assert.NoError(t, err)
+	_, err = rp.ProcessReceipt(context.Background(), models.Receipt{Retailer: "Target", Total: 649})
+	assert.NoError(t, err)
+
+	if assert.Len(t, events.records, 1, "duplicates are not published") {
+		assert.Equal(t, id, events.records[0].ID)
+		assert.Equal(t, "Target", events.records[0].Receipt.Retailer)
+	}
+}
+
+func TestMultiPublisher(t *testing.T) {
+	first, second := &recordingPublisher{}, &recordingPublisher{}
+	publisher := MultiPublisher(first, nil, second)
+
+	record := models.ReceiptRecord{ID: "receipt-id"}
+	publisher.PublishReceiptProcessed(context.Background(), record)
+
+	assert.Equal(t, []models.ReceiptRecord{record}, first.records)
+	assert.Equal(t, []models.ReceiptRecord{record}, second.records)
+}
+
+func TestReceiptProcessor_Spans(t *testing.T) {
+	recorder := tracingtest.Record(t)
+	var storeSpan trace.SpanContext
+	cacheSet := make(chan trace.SpanContext, 1)
+	mockStorage := &mockStorage{
+		storeFunc: func(ctx context.Context, record models.ReceiptRecord) (string, error) {
+			storeSpan = trace.SpanContextFromContext(ctx)
+			return "receipt-id", nil
+		},
+		retrieveFunc: func(ctx context.Context, id string) (models.ReceiptRecord, error) {
+			if id == "broken" {
+				return models.ReceiptRecord{}, errors.New("disk on fire")
+			}
+			return models.ReceiptRecord{}, storage.ErrNotFound
+		},
+	}
+	mockCache := &mockCache{
+		getFunc: func(ctx context.Context, id string) (int, bool) { return 0, false },
+		setFunc: func(ctx context.Context, id string, points int, ttl time.Duration) error {
+			cacheSet <- trace.SpanContextFromContext(ctx)
+			return nil
+		},
+	}
+	rp := NewReceiptProcessor(zap.NewNop(), mockStorage, mockCache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := rp.ProcessReceipt(ctx, models.Receipt{Retailer: "Target", Total: 649})
+	require.NoError(t, err)
+	cancel()
+	assert.Equal(t, storeSpan, <-cacheSet, "the cache is updated in the trace of the request, after it is done")
+
+	_, err = rp.GetPoints(context.Background(), "missing")
+	require.ErrorIs(t, err, ierrors.ErrNotFound)
+	_, err = rp.GetPoints(context.Background(), "broken")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"ReceiptProcessor.ProcessReceipt", "ReceiptProcessor.GetPoints", "ReceiptProcessor.GetPoints"}, tracingtest.Names(recorder))
+	assert.Equal(t, spans[0].SpanContext(), storeSpan)
+	assert.Contains(t, spans[0].Attributes(), attribute.String("receipt.id", "receipt-id"))
+	assert.Equal(t, codes.Unset, spans[1].Status().Code, "unknown receipts are not failures")
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
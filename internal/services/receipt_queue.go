@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"sync"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+var (
+	ErrQueueFull   = errors.New("receipt queue is full")
+	ErrQueueClosed = errors.New("receipt queue is shut down")
+)
+
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"
+	JobProcessed JobStatus = "processed"
+	JobFailed    JobStatus = "failed"
+)
+
+// ReceiptJob is the state of a receipt submitted to a ReceiptQueue. ReceiptID is
+// set once processed; it differs from ID when the receipt was a duplicate.
+// CustomerID is the customer of the submitted receipt, if any.
+type ReceiptJob struct {
+	ID         string
+	Status     JobStatus
+	ReceiptID  string
+	Error      string
+	CustomerID string
+}
+
+type ReceiptQueue interface {
+	// Enqueue schedules the receipt for processing and returns the ID it will be
+	// stored under. It returns ErrQueueFull when the queue is at capacity and
+	// ErrQueueClosed after Shutdown.
+	Enqueue(ctx context.Context, receipt models.Receipt) (string, error)
+	// Status reports jobs that are pending, failed or stored under another ID.
+	// Jobs stored under their own ID are only known to storage, which also
+	// resolves the ID of a duplicate to the original once its status expires.
+	Status(id string) (ReceiptJob, bool)
+	// Shutdown stops accepting receipts and waits for the queued ones to be
+	// processed or for ctx to be done.
+	Shutdown(ctx context.Context) error
+}
+
+type queuedReceipt struct {
+	id      string
+	receipt models.Receipt
+	// trace is the span that enqueued the receipt, the parent of its processing span.
+	trace trace.SpanContext
+}
+
+type trackedJob struct {
+	job       ReceiptJob
+	expiresAt time.Time
+}
+
+type receiptQueue struct {
+	processor ReceiptProcessor
+	log       *zap.Logger
+	statusTTL time.Duration
+	now       func() time.Time
+
+	mu        sync.RWMutex
+	closed    bool
+	queue     chan queuedReceipt
+	jobs      map[string]*trackedJob
+	lastSweep time.Time
+	wg        sync.WaitGroup
+}
+
+// NewReceiptQueue starts workers goroutines that process up to depth queued
+// receipts with processor. Failed and duplicate jobs are reported by Status for
+// statusTTL after they finish.
+func NewReceiptQueue(log *zap.Logger, processor ReceiptProcessor, workers, depth int, statusTTL time.Duration) ReceiptQueue {
+	return newReceiptQueue(log, processor, workers, depth, statusTTL, time.Now)
+}
+
+func newReceiptQueue(log *zap.Logger, processor ReceiptProcessor, workers, depth int, statusTTL time.Duration, now func() time.Time) *receiptQueue {
+	q := &receiptQueue{
+		processor: processor,
+		log:       log,
+		statusTTL: statusTTL,
+		now:       now,
+		queue:     make(chan queuedReceipt, depth),
+		jobs:      make(map[string]*trackedJob),
+		lastSweep: now(),
+	}
+
+	q.wg.Add(workers)
+	for range workers {
+		go q.work()
+	}
+	return q
+}
+
+func (q *receiptQueue) Enqueue(ctx context.Context, r models.Receipt) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return "", ErrQueueClosed
+	}
+	q.sweepLocked(q.now())
+
+	id := uuid.New().String()
+	select {
+	case q.queue <- queuedReceipt{id: id, receipt: r, trace: trace.SpanContextFromContext(ctx)}:
+	default:
+		return "", ErrQueueFull
+	}
+	q.jobs[id] = &trackedJob{job: ReceiptJob{ID: id, Status: JobPending, CustomerID: r.CustomerID}}
+	return id, nil
+}
+
+func (q *receiptQueue) Status(id string) (ReceiptJob, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	tracked, ok := q.jobs[id]
+	if !ok || (tracked.job.Status != JobPending && !q.now().Before(tracked.expiresAt)) {
+		return ReceiptJob{}, false
+	}
+	return tracked.job, true
+}
+
+func (q *receiptQueue) Shutdown(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.queue)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("receipt queue not drained, %d receipts left: %w", len(q.queue), ctx.Err())
+	}
+}
+
+// CheckHealth fails while the queue cannot accept receipts: it is full or shut down.
+func (q *receiptQueue) CheckHealth(ctx context.Context) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	switch {
+	case q.closed:
+		return ErrQueueClosed
+	case len(q.queue) == cap(q.queue):
+		return ErrQueueFull
+	}
+	return nil
+}
+
+func (q *receiptQueue) work() {
+	defer q.wg.Done()
+	for item := range q.queue {
+		q.process(item)
+	}
+}
+
+func (q *receiptQueue) process(item queuedReceipt) {
+	// The request that submitted the receipt is long gone; the receipt must still
+	// be stored, in the same trace.
+	ctx := trace.ContextWithSpanContext(context.Background(), item.trace)
+	receiptID, err := q.processor.ProcessReceiptWithID(ctx, item.id, item.receipt)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	customer := item.receipt.CustomerID
+	switch {
+	case errors.Is(err, ierrors.ErrDuplicateReceipt):
+		q.finishLocked(ReceiptJob{ID: item.id, Status: JobFailed, Error: "This receipt has already been submitted", CustomerID: customer})
+	case err != nil:
+		q.log.Error("Error processing queued receipt", zap.String("id", item.id), zap.Error(err))
+		q.finishLocked(ReceiptJob{ID: item.id, Status: JobFailed, Error: err.Error(), CustomerID: customer})
+	case receiptID != item.id:
+		q.finishLocked(ReceiptJob{ID: item.id, Status: JobProcessed, ReceiptID: receiptID, CustomerID: customer})
+	default:
+		delete(q.jobs, item.id)
+	}
+}
+
+func (q *receiptQueue) finishLocked(job ReceiptJob) {
+	q.jobs[job.ID] = &trackedJob{job: job, expiresAt: q.now().Add(q.statusTTL)}
+}
+
+// sweepLocked drops expired jobs at most once per statusTTL.
+func (q *receiptQueue) sweepLocked(now time.Time) {
+	if now.Sub(q.lastSweep) < q.statusTTL {
+		return
+	}
+	for id, tracked := range q.jobs {
+		if tracked.job.Status != JobPending && !now.Before(tracked.expiresAt) {
+			delete(q.jobs, id)
+		}
+	}
+	q.lastSweep = now
+}
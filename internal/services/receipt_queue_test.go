@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"sync"
+	"testing"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/health"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/receipt"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/tracing/tracingtest"
+	"time"
+)
+
+// fakeProcessor runs process for ProcessReceiptWithID; other methods are not used by the queue.
+type fakeProcessor struct {
+	ReceiptProcessor
+	process func(id string, r models.Receipt) (string, error)
+}
+
+func (f *fakeProcessor) ProcessReceiptWithID(_ context.Context, id string, r models.Receipt) (string, error) {
+	return f.process(id, r)
+}
+
+func TestReceiptQueue_ProcessesAndDrains(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	processed := map[string]string{}
+	processor := &fakeProcessor{process: func(id string, r models.Receipt) (string, error) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		processed[id] = r.Retailer
+		return id, nil
+	}}
+	q := NewReceiptQueue(zap.NewNop(), processor, 2, 10, time.Hour)
+
+	ids := make([]string, 0, 5)
+	for _, retailer := range []string{"A", "B", "C", "D", "E"} {
+		id, err := q.Enqueue(context.Background(), models.Receipt{Retailer: retailer, CustomerID: "alice"})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	job, ok := q.Status(ids[4])
+	require.True(t, ok)
+	assert.Equal(t, ReceiptJob{ID: ids[4], Status: JobPending, CustomerID: "alice"}, job)
+
+	close(release)
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	assert.Len(t, processed, 5)
+	for _, id := range ids {
+		_, ok := q.Status(id)
+		assert.False(t, ok, "receipts stored under their own ID are left to storage")
+	}
+
+	_, err := q.Enqueue(context.Background(), models.Receipt{})
+	assert.ErrorIs(t, err, ErrQueueClosed)
+}
+
+func TestReceiptQueue_Full(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	processor := &fakeProcessor{process: func(id string, r models.Receipt) (string, error) {
+		started <- struct{}{}
+		<-release
+		return id, nil
+	}}
+	q := NewReceiptQueue(zap.NewNop(), processor, 1, 1, time.Hour)
+	hc := q.(health.HealthChecker)
+
+	_, err := q.Enqueue(context.Background(), models.Receipt{})
+	require.NoError(t, err)
+	<-started
+	assert.NoError(t, hc.CheckHealth(context.Background()))
+
+	_, err = q.Enqueue(context.Background(), models.Receipt{})
+	require.NoError(t, err, "one receipt may wait while the worker is busy")
+
+	_, err = q.Enqueue(context.Background(), models.Receipt{})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.ErrorIs(t, hc.CheckHealth(context.Background()), ErrQueueFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, q.Shutdown(ctx), context.DeadlineExceeded, "Shutdown gives up when ctx is done")
+
+	close(release)
+	<-started
+	require.NoError(t, q.Shutdown(context.Background()))
+	assert.ErrorIs(t, hc.CheckHealth(context.Background()), ErrQueueClosed)
+}
+
+func TestReceiptQueue_FinishedJobs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	processor := &fakeProcessor{process: func(id string, r models.Receipt) (string, error) {
+		switch r.Retailer {
+		case "duplicate":
+			return "original", nil
+		case "rejected":
+			return "", ierrors.ErrDuplicateReceipt
+		default:
+			return "", errors.New("storage unavailable")
+		}
+	}}
+	q := newReceiptQueue(zap.NewNop(), processor, 1, 10, time.Hour, clock)
+
+	ids := map[string]string{}
+	for _, retailer := range []string{"duplicate", "rejected", "broken"} {
+		id, err := q.Enqueue(context.Background(), models.Receipt{Retailer: retailer, CustomerID: "alice"})
+		require.NoError(t, err)
+		ids[retailer] = id
+	}
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	tests := []struct {
+		retailer string
+		want     ReceiptJob
+	}{
+		{"duplicate", ReceiptJob{Status: JobProcessed, ReceiptID: "original"}},
+		{"rejected", ReceiptJob{Status: JobFailed, Error: "This receipt has already been submitted"}},
+		{"broken", ReceiptJob{Status: JobFailed, Error: "storage unavailable"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.retailer, func(t *testing.T) {
+			tt.want.ID = ids[tt.retailer]
+			tt.want.CustomerID = "alice"
+			job, ok := q.Status(ids[tt.retailer])
+			require.True(t, ok)
+			assert.Equal(t, tt.want, job)
+		})
+	}
+
+	now = now.Add(time.Hour)
+	for retailer, id := range ids {
+		_, ok := q.Status(id)
+		assert.False(t, ok, "%s job must expire after the status TTL", retailer)
+	}
+
+	q.mu.Lock()
+	q.sweepLocked(now)
+	assert.Empty(t, q.jobs)
+	q.mu.Unlock()
+}
+
+func TestReceiptQueue_DuplicateIDOutlivesStatus(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	cache := storage.NewInMemoryCache(zap.NewNop(), 10, time.Minute)
+	t.Cleanup(cache.Close)
+	processor := NewReceiptProcessor(zap.NewNop(), storage.NewInMemoryStore(), cache, receipt.DefaultRegistry(), config.DuplicatePolicyReturnOriginal, nil)
+	q := newReceiptQueue(zap.NewNop(), processor, 1, 10, time.Hour, clock)
+
+	r := models.Receipt{Retailer: "Target", PurchaseDate: "2022-01-01", PurchaseTime: "13:01", Total: 100}
+	original, err := q.Enqueue(context.Background(), r)
+	require.NoError(t, err)
+	duplicate, err := q.Enqueue(context.Background(), r)
+	require.NoError(t, err)
+	require.NoError(t, q.Shutdown(context.Background()))
+
+	job, ok := q.Status(duplicate)
+	require.True(t, ok)
+	require.Equal(t, original, job.ReceiptID)
+
+	now = now.Add(time.Hour)
+	_, ok = q.Status(duplicate)
+	require.False(t, ok)
+
+	want, err := processor.GetPoints(context.Background(), original)
+	require.NoError(t, err)
+	got, err := processor.GetPoints(context.Background(), duplicate)
+	require.NoError(t, err, "the ID returned for the duplicate is still found once its status has expired")
+	assert.Equal(t, want, got)
+	record, err := processor.GetReceipt(context.Background(), duplicate)
+	require.NoError(t, err)
+	assert.Equal(t, original, record.ID)
+}
+
+// contextProcessor reports the context each receipt is processed with.
+type contextProcessor struct {
+	ReceiptProcessor
+	contexts chan context.Context
+}
+
+func (p *contextProcessor) ProcessReceiptWithID(ctx context.Context, id string, _ models.Receipt) (string, error) {
+	p.contexts <- ctx
+	return id, nil
+}
+
+func TestReceiptQueue_ContinuesTrace(t *testing.T) {
+	tracingtest.Record(t)
+	processor := &contextProcessor{contexts: make(chan context.Context, 1)}
+	q := NewReceiptQueue(zap.NewNop(), processor, 1, 1, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, span := otel.Tracer("test").Start(ctx, "request")
+	_, err := q.Enqueue(ctx, models.Receipt{})
+	require.NoError(t, err)
+	span.End()
+	cancel()
+
+	processed := <-processor.contexts
+	assert.NoError(t, processed.Err(), "processing outlives the request")
+	assert.Equal(t, span.SpanContext(), trace.SpanContextFromContext(processed))
+	require.NoError(t, q.Shutdown(context.Background()))
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key is revoked")
+)
+
+// APIKeyStore keeps API keys. Keys are never deleted, so revoked keys remain
+// listed. Every Storage implementation in this package is also an APIKeyStore.
+type APIKeyStore interface {
+	// CreateAPIKey stores key under a new ID and returns it with the ID and CreatedAt set.
+	CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error)
+	// APIKeyByHash returns the key, revoked or not, whose secret has the given
+	// hash. It returns ErrAPIKeyNotFound if there is none.
+	APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// ListAPIKeys returns every key, oldest first.
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	// RotateAPIKey replaces the hash of the key, so its old secret stops working.
+	// It returns ErrAPIKeyNotFound for unknown IDs and ErrAPIKeyRevoked for revoked keys.
+	RotateAPIKey(ctx context.Context, id, hash string) (models.APIKey, error)
+	// RevokeAPIKey marks the key revoked. Revoking it again changes nothing. It
+	// returns ErrAPIKeyNotFound for unknown IDs.
+	RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error)
+}
+
+func newAPIKey(key models.APIKey, now time.Time) models.APIKey {
+	key.ID = uuid.New().String()
+	key.CreatedAt = now
+	key.RotatedAt = nil
+	key.RevokedAt = nil
+	return key
+}
+
+func rotateAPIKey(key *models.APIKey, hash string, now time.Time) error {
+	if key.RevokedAt != nil {
+		return ErrAPIKeyRevoked
+	}
+	key.Hash = hash
+	key.RotatedAt = &now
+	return nil
+}
+
+func revokeAPIKey(key *models.APIKey, now time.Time) {
+	if key.RevokedAt == nil {
+		key.RevokedAt = &now
+	}
+}
+
+func cloneAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	if key.RotatedAt != nil {
+		t := *key.RotatedAt
+		key.RotatedAt = &t
+	}
+	if key.RevokedAt != nil {
+		t := *key.RevokedAt
+		key.RevokedAt = &t
+	}
+	return key
+}
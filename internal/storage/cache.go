@@ -1,32 +1,79 @@
 package storage
 
 import (
+	"container/list"
 	"context"
 	"go.uber.org/zap"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Cache interface {
 	Load(ctx context.Context, key string) (int, bool)
 	Set(ctx context.Context, key string, value int, expiration time.Duration) error
+	// Delete removes key, if cached.
+	Delete(ctx context.Context, key string) error
 }
 
+// CacheStats is a point-in-time view of the cache counters.
+type CacheStats struct {
+	Entries     int
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64
+	Expirations uint64
+}
+
+// InMemoryCache is a capacity bounded LRU cache. Expired entries are dropped
+// lazily on Load and periodically by a single background sweeper.
 type InMemoryCache struct {
-	data sync.Map
-	log  *zap.Logger
+	mu       sync.Mutex
+	items    map[string]*list.Element
+	lru      *list.List
+	capacity int
+	log      *zap.Logger
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   atomic.Uint64
+	expirations atomic.Uint64
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type cacheEntry struct {
+	key        string
 	value      int
 	expiration time.Time
 }
 
-func NewInMemoryCache(log *zap.Logger) *InMemoryCache {
-	return &InMemoryCache{
-		data: sync.Map{},
-		log:  log,
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expiration.IsZero() && !now.Before(e.expiration)
+}
+
+// NewInMemoryCache creates a cache holding at most capacity entries (unbounded
+// when capacity <= 0) and starts a sweeper that runs every sweepInterval
+// (disabled when sweepInterval <= 0). Call Close to stop the sweeper.
+func NewInMemoryCache(log *zap.Logger, capacity int, sweepInterval time.Duration) *InMemoryCache {
+	c := &InMemoryCache{
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		capacity: capacity,
+		log:      log,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
+
+	if sweepInterval > 0 {
+		go c.runSweeper(sweepInterval)
+	} else {
+		close(c.done)
+	}
+
+	return c
 }
 
 func (c *InMemoryCache) Load(ctx context.Context, key string) (int, bool) {
@@ -34,20 +81,26 @@ func (c *InMemoryCache) Load(ctx context.Context, key string) (int, bool) {
 	case <-ctx.Done():
 		return 0, false
 	default:
-		if entry, ok := c.data.Load(key); ok {
-			cacheEntry, ok := entry.(cacheEntry)
-			if !ok {
-				return 0, false
-			}
+		c.mu.Lock()
+		defer c.mu.Unlock()
 
-			if !cacheEntry.expiration.IsZero() && time.Now().After(cacheEntry.expiration) {
-				c.data.Delete(key)
-				return 0, false
-			}
+		elem, ok := c.items[key]
+		if !ok {
+			c.misses.Add(1)
+			return 0, false
+		}
 
-			return cacheEntry.value, true
+		entry := elem.Value.(*cacheEntry)
+		if entry.expired(time.Now()) {
+			c.removeElement(elem)
+			c.expirations.Add(1)
+			c.misses.Add(1)
+			return 0, false
 		}
-		return 0, false
+
+		c.lru.MoveToFront(elem)
+		c.hits.Add(1)
+		return entry.value, true
 	}
 }
 
@@ -61,18 +114,107 @@ func (c *InMemoryCache) Set(ctx context.Context, key string, value int, expirati
 			expTime = time.Now().Add(expiration)
 		}
 
-		c.data.Store(key, cacheEntry{value: value, expiration: expTime})
+		c.mu.Lock()
+		defer c.mu.Unlock()
 
-		if expiration > 0 {
-			keyCopy := key
-			time.AfterFunc(expiration, func() {
-				if _, ok := c.data.Load(keyCopy); ok {
-					c.data.Delete(keyCopy)
-					c.log.Info("Cache entry expired", zap.String("key", keyCopy))
-				}
-			})
+		if elem, ok := c.items[key]; ok {
+			entry := elem.Value.(*cacheEntry)
+			entry.value = value
+			entry.expiration = expTime
+			c.lru.MoveToFront(elem)
+		} else {
+			c.items[key] = c.lru.PushFront(&cacheEntry{key: key, value: value, expiration: expTime})
 		}
-		c.log.Info("Cache set", zap.String("key", key), zap.Int("value", value))
+
+		for c.capacity > 0 && c.lru.Len() > c.capacity {
+			c.removeElement(c.lru.Back())
+			c.evictions.Add(1)
+		}
+
+		c.log.Debug("Cache set", zap.String("key", key), zap.Int("value", value))
 		return nil
 	}
 }
+
+func (c *InMemoryCache) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+func (c *InMemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+
+	return CacheStats{
+		Entries:     entries,
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+	}
+}
+
+// CheckHealth always succeeds; the cache lives in process memory.
+func (c *InMemoryCache) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// Close stops the background sweeper. It is safe to call more than once.
+func (c *InMemoryCache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *InMemoryCache) runSweeper(interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if removed := c.sweep(); removed > 0 {
+				c.log.Debug("Cache sweep removed expired entries", zap.Int("removed", removed))
+			}
+		}
+	}
+}
+
+func (c *InMemoryCache) sweep() int {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := 0
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).expired(now) {
+			c.removeElement(elem)
+			removed++
+		}
+		elem = next
+	}
+	c.expirations.Add(uint64(removed))
+	return removed
+}
+
+func (c *InMemoryCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
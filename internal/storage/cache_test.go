@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	tests := []struct {
+		name    string
+		touch   []string
+		evicted string
+		kept    []string
+	}{
+		{
+			name:    "oldest entry is evicted",
+			evicted: "a",
+			kept:    []string{"b", "c", "d"},
+		},
+		{
+			name:    "load refreshes recency",
+			touch:   []string{"a"},
+			evicted: "b",
+			kept:    []string{"a", "c", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			c := NewInMemoryCache(zap.NewNop(), 3, 0)
+			defer c.Close()
+
+			for i, key := range []string{"a", "b", "c"} {
+				assert.NoError(t, c.Set(ctx, key, i, 0))
+			}
+			for _, key := range tt.touch {
+				_, ok := c.Load(ctx, key)
+				assert.True(t, ok)
+			}
+			assert.NoError(t, c.Set(ctx, "d", 3, 0))
+
+			_, ok := c.Load(ctx, tt.evicted)
+			assert.False(t, ok)
+			for _, key := range tt.kept {
+				_, ok := c.Load(ctx, key)
+				assert.True(t, ok, key)
+			}
+			assert.Equal(t, uint64(1), c.Stats().Evictions)
+			assert.Equal(t, 3, c.Stats().Entries)
+		})
+	}
+}
+
+func TestInMemoryCache_OverwriteDoesNotEvict(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache(zap.NewNop(), 2, 0)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "a", 1, 0))
+	assert.NoError(t, c.Set(ctx, "b", 2, 0))
+	assert.NoError(t, c.Set(ctx, "a", 3, 0))
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(0), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestInMemoryCache_Stats(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache(zap.NewNop(), 10, 0)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "live", 1, 0))
+	assert.NoError(t, c.Set(ctx, "expired", 2, time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	c.Load(ctx, "live")
+	c.Load(ctx, "live")
+	c.Load(ctx, "expired")
+	c.Load(ctx, "missing")
+
+	assert.Equal(t, CacheStats{Entries: 1, Hits: 2, Misses: 2, Expirations: 1}, c.Stats())
+}
+
+func TestInMemoryCache_SweeperRemovesExpiredEntries(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache(zap.NewNop(), 10, 5*time.Millisecond)
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "short", 1, 10*time.Millisecond))
+	assert.NoError(t, c.Set(ctx, "long", 2, time.Hour))
+
+	assert.Eventually(t, func() bool {
+		return c.Stats().Entries == 1
+	}, time.Second, 5*time.Millisecond)
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(1), stats.Expirations)
+	assert.Equal(t, uint64(0), stats.Misses)
+}
+
+func TestInMemoryCache_CloseIsIdempotent(t *testing.T) {
+	c := NewInMemoryCache(zap.NewNop(), 10, time.Millisecond)
+	c.Close()
+	c.Close()
+}
@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"path/filepath"
+	"testing"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/storage/storagetest"
+	"time"
+)
+
+func TestInMemoryStore_Conformance(t *testing.T) {
+	storagetest.RunStorageTests(t, func(t *testing.T) storage.Storage {
+		return storage.NewInMemoryStore()
+	})
+}
+
+func TestSQLiteStore_Conformance(t *testing.T) {
+	storagetest.RunStorageTests(t, func(t *testing.T) storage.Storage {
+		s, err := storage.NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "receipts.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestFileStore_Conformance(t *testing.T) {
+	storagetest.RunStorageTests(t, func(t *testing.T) storage.Storage {
+		s, err := storage.NewFileStore(zap.NewNop(), t.TempDir(), 7)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s
+	})
+}
+
+func TestInMemoryWebhookStore_Conformance(t *testing.T) {
+	storagetest.RunWebhookStoreTests(t, func(t *testing.T, maxDeadLetters int) storage.WebhookStore {
+		return storage.NewInMemoryWebhookStore(maxDeadLetters)
+	})
+}
+
+func TestSQLiteWebhookStore_Conformance(t *testing.T) {
+	storagetest.RunWebhookStoreTests(t, func(t *testing.T, maxDeadLetters int) storage.WebhookStore {
+		s, err := storage.NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "receipts.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s.Webhooks(maxDeadLetters)
+	})
+}
+
+func TestFileWebhookStore_Conformance(t *testing.T) {
+	storagetest.RunWebhookStoreTests(t, func(t *testing.T, maxDeadLetters int) storage.WebhookStore {
+		s, err := storage.NewFileStore(zap.NewNop(), t.TempDir(), 2)
+		require.NoError(t, err)
+		t.Cleanup(func() { s.Close() })
+		return s.Webhooks(maxDeadLetters)
+	})
+}
+
+func TestInMemoryCache_Conformance(t *testing.T) {
+	storagetest.RunCacheTests(t, func(t *testing.T) storagetest.CacheFixture {
+		c := storage.NewInMemoryCache(zap.NewNop(), 1000, 10*time.Millisecond)
+		t.Cleanup(c.Close)
+		return storagetest.CacheFixture{Cache: c}
+	})
+}
+
+func TestRedisCache_Conformance(t *testing.T) {
+	storagetest.RunCacheTests(t, func(t *testing.T) storagetest.CacheFixture {
+		mr := miniredis.RunT(t)
+		c, err := storage.NewRedisCache(context.Background(), zap.NewNop(), &redis.Options{Addr: mr.Addr()}, "test:")
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		return storagetest.CacheFixture{Cache: c, Advance: mr.FastForward}
+	})
+}
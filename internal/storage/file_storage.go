@@ -0,0 +1,651 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+const (
+	walFileName      = "wal.log"
+	snapshotFileName = "snapshot.dat"
+
+	// Every frame is a little-endian uint32 payload length and a CRC-32C of the
+	// payload, followed by the payload itself.
+	frameHeaderSize = 8
+	maxFramePayload = 64 << 20
+)
+
+var (
+	ErrStoreClosed = errors.New("store is closed")
+
+	errTornFrame    = errors.New("incomplete frame")
+	errCorruptFrame = errors.New("frame checksum mismatch")
+
+	frameChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type walOp string
+
+const (
+	walOpStoreReceipt walOp = "store_receipt"
+	walOpRedeemPoints walOp = "redeem_points"
+	walOpVoidReceipt  walOp = "void_receipt"
+	// walOpAliasReceipt records the ID a duplicate was submitted under.
+	walOpAliasReceipt walOp = "alias_receipt"
+	// walOpPutAPIKey records the state of an API key after it was created, rotated or revoked.
+	walOpPutAPIKey     walOp = "put_api_key"
+	walOpCreateWebhook walOp = "create_webhook"
+	walOpDeleteWebhook walOp = "delete_webhook"
+	walOpAddDeadLetter walOp = "add_dead_letter"
+)
+
+type walEntry struct {
+	Seq     uint64                `json:"seq"`
+	Op      walOp                 `json:"op"`
+	Receipt *models.ReceiptRecord `json:"receipt,omitempty"`
+	Ledger  *models.LedgerEntry   `json:"ledger,omitempty"`
+	APIKey  *models.APIKey        `json:"apiKey,omitempty"`
+	// Webhook is the created subscription, or only the ID of the deleted one.
+	Webhook    *models.WebhookSubscription `json:"webhook,omitempty"`
+	DeadLetter *models.WebhookDeadLetter   `json:"deadLetter,omitempty"`
+	Alias      *receiptAlias               `json:"alias,omitempty"`
+}
+
+// receiptAlias is an ID a duplicate was submitted under and the ID of the original.
+type receiptAlias struct {
+	ID        string `json:"id"`
+	ReceiptID string `json:"receiptId"`
+}
+
+type fileSnapshot struct {
+	Seq         uint64                       `json:"seq"`
+	Receipts    []models.ReceiptRecord       `json:"receipts"`
+	Ledger      []models.LedgerEntry         `json:"ledger,omitempty"`
+	APIKeys     []models.APIKey              `json:"apiKeys,omitempty"`
+	Webhooks    []models.WebhookSubscription `json:"webhooks,omitempty"`
+	DeadLetters []models.WebhookDeadLetter   `json:"deadLetters,omitempty"`
+	Aliases     map[string]string            `json:"aliases,omitempty"`
+}
+
+// FileStore is a durable Storage without external dependencies. Every write is
+// appended to a checksummed write-ahead log and applied to an in-memory copy.
+// After snapshotEvery writes the state is compacted into a snapshot file and
+// the log is truncated. On startup the snapshot is loaded and the log replayed.
+type FileStore struct {
+	mem           *inMemoryStore
+	webhooks      *inMemoryWebhookStore
+	log           *zap.Logger
+	dir           string
+	snapshotEvery int
+
+	mu      sync.Mutex
+	wal     *os.File
+	offset  int64
+	seq     uint64
+	pending int
+}
+
+func NewFileStore(log *zap.Logger, dir string, snapshotEvery int) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating storage directory: %w", err)
+	}
+
+	s := &FileStore{
+		mem:           newInMemoryStore(),
+		webhooks:      &inMemoryWebhookStore{},
+		log:           log,
+		dir:           dir,
+		snapshotEvery: snapshotEvery,
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Store(ctx context.Context, record models.ReceiptRecord) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id, ok := s.mem.findFingerprint(record.Fingerprint); ok {
+		if record.ID != "" {
+			if err := s.appendLocked(walEntry{Op: walOpAliasReceipt, Alias: &receiptAlias{ID: record.ID, ReceiptID: id}}); err != nil {
+				return "", err
+			}
+		}
+		return id, ErrDuplicate
+	}
+
+	if record.ID == "" {
+		record.ID = uuid.New().String()
+	}
+	entry := walEntry{
+		Op:      walOpStoreReceipt,
+		Receipt: &record,
+		Ledger:  creditEntry(record, s.mem.balance(record.Receipt.CustomerID)),
+	}
+	if err := s.appendLocked(entry); err != nil {
+		return "", err
+	}
+	return record.ID, nil
+}
+
+func (s *FileStore) Retrieve(ctx context.Context, id string) (models.ReceiptRecord, error) {
+	return s.mem.Retrieve(ctx, id)
+}
+
+func (s *FileStore) Query(ctx context.Context, q ReceiptQuery) (ReceiptPage, error) {
+	return s.mem.Query(ctx, q)
+}
+
+func (s *FileStore) Balance(ctx context.Context, customerID string) (int, error) {
+	return s.mem.Balance(ctx, customerID)
+}
+
+func (s *FileStore) Ledger(ctx context.Context, customerID string, q LedgerQuery) (LedgerPage, error) {
+	return s.mem.Ledger(ctx, customerID, q)
+}
+
+func (s *FileStore) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return models.LedgerEntry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, err := redemptionEntry(customerID, points, description, s.mem.balance(customerID))
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	if err := s.appendLocked(walEntry{Op: walOpRedeemPoints, Ledger: &entry}); err != nil {
+		return models.LedgerEntry{}, err
+	}
+	return entry, nil
+}
+
+func (s *FileStore) Void(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem.mu.RLock()
+	record, entry, err := s.mem.voidLocked(id)
+	s.mem.mu.RUnlock()
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	if err := s.appendLocked(walEntry{Op: walOpVoidReceipt, Receipt: &record, Ledger: entry}); err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	return cloneRecord(record), entry, nil
+}
+
+func (s *FileStore) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = newAPIKey(key, time.Now().UTC())
+	if err := s.appendLocked(walEntry{Op: walOpPutAPIKey, APIKey: &key}); err != nil {
+		return models.APIKey{}, err
+	}
+	return cloneAPIKey(key), nil
+}
+
+func (s *FileStore) APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return s.mem.APIKeyByHash(ctx, hash)
+}
+
+func (s *FileStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.mem.ListAPIKeys(ctx)
+}
+
+func (s *FileStore) RotateAPIKey(ctx context.Context, id, hash string) (models.APIKey, error) {
+	return s.updateAPIKey(ctx, id, func(key *models.APIKey, now time.Time) error {
+		return rotateAPIKey(key, hash, now)
+	})
+}
+
+func (s *FileStore) RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	return s.updateAPIKey(ctx, id, func(key *models.APIKey, now time.Time) error {
+		revokeAPIKey(key, now)
+		return nil
+	})
+}
+
+// updateAPIKey applies update to a copy of the key and logs the result.
+func (s *FileStore) updateAPIKey(ctx context.Context, id string, update func(key *models.APIKey, now time.Time) error) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mem.mu.RLock()
+	key, err := s.mem.apiKeyLocked(id)
+	s.mem.mu.RUnlock()
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if err := update(&key, time.Now().UTC()); err != nil {
+		return models.APIKey{}, err
+	}
+	if err := s.appendLocked(walEntry{Op: walOpPutAPIKey, APIKey: &key}); err != nil {
+		return models.APIKey{}, err
+	}
+	return cloneAPIKey(key), nil
+}
+
+// Webhooks returns a WebhookStore that logs subscriptions and dead letters
+// like receipts. Dead letters beyond maxDeadLetters are dropped from the log
+// at the next snapshot.
+func (s *FileStore) Webhooks(maxDeadLetters int) WebhookStore {
+	return &fileWebhookStore{s: s, maxDeadLetters: maxDeadLetters}
+}
+
+type fileWebhookStore struct {
+	s              *FileStore
+	maxDeadLetters int
+}
+
+func (w *fileWebhookStore) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	sub = newSubscription(sub, time.Now().UTC())
+	if err := w.s.appendLocked(walEntry{Op: walOpCreateWebhook, Webhook: &sub}); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	return sub, nil
+}
+
+func (w *fileWebhookStore) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return w.s.webhooks.ListSubscriptions(ctx)
+}
+
+func (w *fileWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	if !w.s.webhooks.hasSubscription(id) {
+		return ErrNotFound
+	}
+	return w.s.appendLocked(walEntry{Op: walOpDeleteWebhook, Webhook: &models.WebhookSubscription{ID: id}})
+}
+
+func (w *fileWebhookStore) AddDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	w.s.mu.Lock()
+	defer w.s.mu.Unlock()
+
+	if err := w.s.appendLocked(walEntry{Op: walOpAddDeadLetter, DeadLetter: &letter}); err != nil {
+		return err
+	}
+	w.s.webhooks.trimDeadLetters(w.maxDeadLetters)
+	return nil
+}
+
+func (w *fileWebhookStore) ListDeadLetters(ctx context.Context) ([]models.WebhookDeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return w.s.webhooks.lastDeadLetters(w.maxDeadLetters), nil
+}
+
+// Snapshot compacts the current state into the snapshot file and truncates the log.
+func (s *FileStore) Snapshot() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+	return s.snapshotLocked()
+}
+
+// Close writes a final snapshot if there are unsnapshotted writes and closes the log.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+
+	var snapErr error
+	if s.pending > 0 {
+		snapErr = s.snapshotLocked()
+	}
+	err := s.wal.Close()
+	s.wal = nil
+	return errors.Join(snapErr, err)
+}
+
+// CheckHealth reports whether writes can still be appended to the log.
+func (s *FileStore) CheckHealth(ctx context.Context) error {
+	s.mu.Lock()
+	closed := s.wal == nil
+	s.mu.Unlock()
+
+	if closed {
+		return ErrStoreClosed
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, walFileName)); err != nil {
+		return fmt.Errorf("error checking write-ahead log: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) appendLocked(entry walEntry) error {
+	if s.wal == nil {
+		return ErrStoreClosed
+	}
+
+	entry.Seq = s.seq + 1
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding log entry: %w", err)
+	}
+
+	n, err := writeFrame(s.wal, payload)
+	if err == nil {
+		err = s.wal.Sync()
+	}
+	if err != nil {
+		// Drop whatever part of the frame made it to disk so the next append starts clean.
+		if truncErr := s.resetWAL(s.offset); truncErr != nil {
+			s.log.Error("Error discarding partial log entry", zap.Error(truncErr))
+		}
+		return fmt.Errorf("error appending to write-ahead log: %w", err)
+	}
+
+	s.offset += int64(n)
+	s.seq = entry.Seq
+	if err := s.apply(entry); err != nil {
+		return err
+	}
+
+	s.pending++
+	if s.snapshotEvery > 0 && s.pending >= s.snapshotEvery {
+		if err := s.snapshotLocked(); err != nil {
+			// The entry is already durable in the log, so the write itself succeeded.
+			s.log.Error("Error writing snapshot", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) apply(entry walEntry) error {
+	switch entry.Op {
+	case walOpStoreReceipt, walOpVoidReceipt:
+		if entry.Receipt == nil {
+			return fmt.Errorf("log entry %d has no receipt", entry.Seq)
+		}
+		s.mem.put(*entry.Receipt, entry.Ledger)
+	case walOpAliasReceipt:
+		if entry.Alias == nil {
+			return fmt.Errorf("log entry %d has no alias", entry.Seq)
+		}
+		s.mem.putAlias(entry.Alias.ID, entry.Alias.ReceiptID)
+	case walOpRedeemPoints:
+		if entry.Ledger == nil {
+			return fmt.Errorf("log entry %d has no ledger entry", entry.Seq)
+		}
+		s.mem.appendLedger(*entry.Ledger)
+	case walOpPutAPIKey:
+		if entry.APIKey == nil {
+			return fmt.Errorf("log entry %d has no api key", entry.Seq)
+		}
+		s.mem.putAPIKey(*entry.APIKey)
+	case walOpCreateWebhook, walOpDeleteWebhook:
+		if entry.Webhook == nil {
+			return fmt.Errorf("log entry %d has no webhook", entry.Seq)
+		}
+		if entry.Op == walOpCreateWebhook {
+			s.webhooks.putSubscription(*entry.Webhook)
+		} else {
+			s.webhooks.removeSubscription(entry.Webhook.ID)
+		}
+	case walOpAddDeadLetter:
+		if entry.DeadLetter == nil {
+			return fmt.Errorf("log entry %d has no dead letter", entry.Seq)
+		}
+		s.webhooks.appendDeadLetter(*entry.DeadLetter)
+	default:
+		return fmt.Errorf("log entry %d has unknown operation %q", entry.Seq, entry.Op)
+	}
+	return nil
+}
+
+func (s *FileStore) snapshotLocked() error {
+	payload, err := json.Marshal(fileSnapshot{
+		Seq:         s.seq,
+		Receipts:    s.mem.records(),
+		Ledger:      s.mem.ledgerEntries(),
+		APIKeys:     s.mem.apiKeyList(),
+		Webhooks:    s.webhooks.subscriptionList(),
+		DeadLetters: s.webhooks.lastDeadLetters(math.MaxInt),
+		Aliases:     s.mem.aliasList(),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+
+	path := filepath.Join(s.dir, snapshotFileName)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot: %w", err)
+	}
+	if _, err := writeFrame(f, payload); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("error syncing snapshot: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error closing snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error replacing snapshot: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	// Entries up to s.seq are now covered by the snapshot. Should the process die
+	// before the truncation below, replay skips them by sequence number.
+	if err := s.resetWAL(0); err != nil {
+		return err
+	}
+	s.offset = 0
+	s.pending = 0
+	return nil
+}
+
+func (s *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	payload, err := readFrame(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return fmt.Errorf("snapshot is damaged: %w", err)
+	}
+
+	var snap fileSnapshot
+	if err := json.Unmarshal(payload, &snap); err != nil {
+		return fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	for _, record := range snap.Receipts {
+		s.mem.put(record, nil)
+	}
+	for _, entry := range snap.Ledger {
+		s.mem.appendLedger(entry)
+	}
+	for _, key := range snap.APIKeys {
+		s.mem.putAPIKey(key)
+	}
+	for alias, id := range snap.Aliases {
+		s.mem.putAlias(alias, id)
+	}
+	s.webhooks.subscriptions = snap.Webhooks
+	s.webhooks.deadLetters = snap.DeadLetters
+	s.seq = snap.Seq
+	return nil
+}
+
+// replay applies every log entry newer than the snapshot. A damaged tail, left
+// behind by a crash in the middle of an append, is truncated.
+func (s *FileStore) replay() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening write-ahead log: %w", err)
+	}
+	s.wal = f
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		payload, err := readFrame(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var entry walEntry
+		if err == nil {
+			err = json.Unmarshal(payload, &entry)
+		}
+		if err != nil {
+			s.log.Warn("Discarding damaged write-ahead log tail", zap.Int64("offset", offset), zap.Error(err))
+			break
+		}
+		offset += frameHeaderSize + int64(len(payload))
+
+		if entry.Seq <= s.seq {
+			continue
+		}
+		if err := s.apply(entry); err != nil {
+			f.Close()
+			return err
+		}
+		s.seq = entry.Seq
+		s.pending++
+	}
+
+	if err := s.resetWAL(offset); err != nil {
+		f.Close()
+		return err
+	}
+	s.offset = offset
+	return nil
+}
+
+// resetWAL truncates the log to size and positions the file for the next append.
+func (s *FileStore) resetWAL(size int64) error {
+	if err := s.wal.Truncate(size); err != nil {
+		return fmt.Errorf("error truncating write-ahead log: %w", err)
+	}
+	if _, err := s.wal.Seek(size, io.SeekStart); err != nil {
+		return fmt.Errorf("error seeking write-ahead log: %w", err)
+	}
+	return s.wal.Sync()
+}
+
+func writeFrame(w io.Writer, payload []byte) (int, error) {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(frame[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(frame[4:8], crc32.Checksum(payload, frameChecksumTable))
+	copy(frame[frameHeaderSize:], payload)
+	return w.Write(frame)
+}
+
+// readFrame returns io.EOF only when r is exhausted exactly at a frame boundary.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornFrame
+		}
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxFramePayload {
+		return nil, errCorruptFrame
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornFrame
+		}
+		return nil, err
+	}
+
+	if crc32.Checksum(payload, frameChecksumTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, errCorruptFrame
+	}
+	return payload, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("error opening storage directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("error syncing storage directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"testing"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+func testRecord() models.ReceiptRecord {
+	return models.ReceiptRecord{
+		Receipt: models.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Items:        []models.Item{{ShortDescription: "Mountain Dew 12PK", Price: 649}},
+			Total:        649,
+		},
+		Points:       models.PointsBreakdown{Total: 24},
+		ProcessedAt:  time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		RulesVersion: "abc123",
+	}
+}
+
+func openFileStore(t *testing.T, dir string, snapshotEvery int) *FileStore {
+	s, err := NewFileStore(zap.NewNop(), dir, snapshotEvery)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func storeRecords(t *testing.T, s Storage, n int) []string {
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		id, err := s.Store(context.Background(), testRecord())
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func assertRetrievable(t *testing.T, s Storage, ids []string) {
+	for _, id := range ids {
+		got, err := s.Retrieve(context.Background(), id)
+		if assert.NoError(t, err, id) {
+			assert.Equal(t, id, got.ID)
+		}
+	}
+}
+
+func TestFileStore_ReplaysLogAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir, 1000)
+	ids := storeRecords(t, s, 5)
+
+	// Simulate a crash: reopen without Close, so nothing but the log is on disk.
+	_, err := os.Stat(filepath.Join(dir, snapshotFileName))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	recovered := openFileStore(t, dir, 1000)
+	assertRetrievable(t, recovered, ids)
+}
+
+func TestFileStore_SnapshotCompactsLog(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir, 3)
+	ids := storeRecords(t, s, 7)
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Greater(t, info.Size(), int64(0), "the seventh write should still be in the log")
+	_, err = os.Stat(filepath.Join(dir, snapshotFileName))
+	require.NoError(t, err)
+
+	recovered := openFileStore(t, dir, 3)
+	assertRetrievable(t, recovered, ids)
+}
+
+func TestFileStore_CloseWritesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir, 1000)
+	ids := storeRecords(t, s, 3)
+	require.NoError(t, s.Close())
+
+	info, err := os.Stat(filepath.Join(dir, walFileName))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	_, err = s.Store(context.Background(), testRecord())
+	assert.ErrorIs(t, err, ErrStoreClosed)
+	assert.ErrorIs(t, s.CheckHealth(context.Background()), ErrStoreClosed)
+
+	recovered := openFileStore(t, dir, 1000)
+	assertRetrievable(t, recovered, ids)
+}
+
+func TestFileStore_TruncatesDamagedTail(t *testing.T) {
+	tests := []struct {
+		name      string
+		damage    func(t *testing.T, path string)
+		survivors int
+	}{
+		{
+			name:      "TornWrite",
+			survivors: 3,
+			damage: func(t *testing.T, path string) {
+				f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+				require.NoError(t, err)
+				defer f.Close()
+				_, err = f.Write([]byte{200, 0, 0, 0, 1, 2, 3, 4, '{', '"'})
+				require.NoError(t, err)
+			},
+		},
+		{
+			// The damaged last entry is dropped, everything before it survives.
+			name:      "ChecksumMismatch",
+			survivors: 2,
+			damage: func(t *testing.T, path string) {
+				data, err := os.ReadFile(path)
+				require.NoError(t, err)
+				data[len(data)-2] ^= 0xff
+				require.NoError(t, os.WriteFile(path, data, 0o644))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := openFileStore(t, dir, 1000)
+			ids := storeRecords(t, s, 3)
+
+			tt.damage(t, filepath.Join(dir, walFileName))
+
+			recovered := openFileStore(t, dir, 1000)
+			assertRetrievable(t, recovered, ids[:tt.survivors])
+			for _, id := range ids[tt.survivors:] {
+				_, err := recovered.Retrieve(context.Background(), id)
+				assert.ErrorIs(t, err, ErrNotFound)
+			}
+
+			// The log must accept new writes after the damaged tail is cut off.
+			more := storeRecords(t, recovered, 2)
+			again := openFileStore(t, dir, 1000)
+			assertRetrievable(t, again, more)
+		})
+	}
+}
+
+func TestFileStore_SkipsEntriesCoveredBySnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := openFileStore(t, dir, 1000)
+	ids := storeRecords(t, s, 3)
+
+	// Keep the log as it was before compaction to simulate a crash between
+	// writing the snapshot and truncating the log.
+	logPath := filepath.Join(dir, walFileName)
+	stale, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	require.NoError(t, s.Snapshot())
+	require.NoError(t, os.WriteFile(logPath, stale, 0o644))
+
+	recovered := openFileStore(t, dir, 1000)
+	assertRetrievable(t, recovered, ids)
+	assert.Equal(t, uint64(3), recovered.seq)
+	assert.Zero(t, recovered.pending)
+}
+
+func TestFileStore_FingerprintsSurviveRestart(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "from log", snapshotEvery: 1000},
+		{name: "from snapshot", snapshotEvery: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			record := testRecord()
+			record.Fingerprint = "fingerprint"
+
+			s := openFileStore(t, dir, tt.snapshotEvery)
+			id, err := s.Store(ctx, record)
+			require.NoError(t, err)
+			alias := record
+			alias.ID = "duplicate-id"
+			_, err = s.Store(ctx, alias)
+			require.ErrorIs(t, err, ErrDuplicate)
+
+			recovered := openFileStore(t, dir, tt.snapshotEvery)
+			duplicate, err := recovered.Store(ctx, record)
+			assert.ErrorIs(t, err, ErrDuplicate)
+			assert.Equal(t, id, duplicate)
+			got, err := recovered.Retrieve(ctx, "duplicate-id")
+			require.NoError(t, err)
+			assert.Equal(t, id, got.ID)
+		})
+	}
+}
+
+func TestFileStore_LedgerSurvivesRestart(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "from log", snapshotEvery: 1000},
+		{name: "from snapshot", snapshotEvery: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			s := openFileStore(t, dir, tt.snapshotEvery)
+			var ids []string
+			for i, points := range []int{24, 10} {
+				record := testRecord()
+				record.Receipt.CustomerID = "alice"
+				record.Points.Total = points
+				record.Fingerprint = fmt.Sprintf("fingerprint-%d", i)
+				id, err := s.Store(ctx, record)
+				require.NoError(t, err)
+				ids = append(ids, id)
+			}
+			_, err := s.Redeem(ctx, "alice", 5, "coffee")
+			require.NoError(t, err)
+			voided, _, err := s.Void(ctx, ids[1])
+			require.NoError(t, err)
+			want, err := s.Ledger(ctx, "alice", LedgerQuery{})
+			require.NoError(t, err)
+
+			recovered := openFileStore(t, dir, tt.snapshotEvery)
+			balance, err := recovered.Balance(ctx, "alice")
+			require.NoError(t, err)
+			assert.Equal(t, 19, balance)
+			got, err := recovered.Ledger(ctx, "alice", LedgerQuery{})
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+			record, err := recovered.Retrieve(ctx, ids[1])
+			require.NoError(t, err)
+			assert.Equal(t, voided, record)
+		})
+	}
+}
+
+func TestFileStore_APIKeysSurviveRestart(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "from log", snapshotEvery: 1000},
+		{name: "from snapshot", snapshotEvery: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			s := openFileStore(t, dir, tt.snapshotEvery)
+			rotated, err := s.CreateAPIKey(ctx, models.APIKey{Name: "pos", Scopes: []string{models.ScopeReceiptsWrite}, Hash: "old-hash"})
+			require.NoError(t, err)
+			rotated, err = s.RotateAPIKey(ctx, rotated.ID, "new-hash")
+			require.NoError(t, err)
+			revoked, err := s.CreateAPIKey(ctx, models.APIKey{Name: "old", Scopes: []string{models.ScopeAdmin}, Hash: "revoked-hash"})
+			require.NoError(t, err)
+			revoked, err = s.RevokeAPIKey(ctx, revoked.ID)
+			require.NoError(t, err)
+
+			recovered := openFileStore(t, dir, tt.snapshotEvery)
+			keys, err := recovered.ListAPIKeys(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []models.APIKey{rotated, revoked}, keys)
+			_, err = recovered.APIKeyByHash(ctx, "old-hash")
+			assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+			got, err := recovered.APIKeyByHash(ctx, "new-hash")
+			require.NoError(t, err)
+			assert.Equal(t, rotated.ID, got.ID)
+		})
+	}
+}
+
+func TestFileStore_WebhooksSurviveRestart(t *testing.T) {
+	tests := []struct {
+		name          string
+		snapshotEvery int
+	}{
+		{name: "from log", snapshotEvery: 1000},
+		{name: "from snapshot", snapshotEvery: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+
+			s := openFileStore(t, dir, tt.snapshotEvery).Webhooks(2)
+			deleted, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://a.example", Secret: "secret-a"})
+			require.NoError(t, err)
+			kept, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://b.example", Secret: "secret-b"})
+			require.NoError(t, err)
+			require.NoError(t, s.DeleteSubscription(ctx, deleted.ID))
+			for _, id := range []string{"e1", "e2", "e3"} {
+				require.NoError(t, s.AddDeadLetter(ctx, models.WebhookDeadLetter{Event: models.WebhookEvent{ID: id}}))
+			}
+
+			recovered := openFileStore(t, dir, tt.snapshotEvery).Webhooks(2)
+			subs, err := recovered.ListSubscriptions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []models.WebhookSubscription{kept}, subs)
+			letters, err := recovered.ListDeadLetters(ctx)
+			require.NoError(t, err)
+			require.Len(t, letters, 2)
+			assert.Equal(t, "e2", letters[0].Event.ID)
+			assert.Equal(t, "e3", letters[1].Event.ID)
+		})
+	}
+}
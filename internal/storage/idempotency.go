@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request body")
+)
+
+// IdempotentResponse is the response recorded for an idempotency key.
+type IdempotentResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+type IdempotencyStore interface {
+	// Begin claims key for a request whose body hashes to fingerprint. It returns
+	// the recorded response if the key already completed with the same fingerprint,
+	// ErrIdempotencyKeyInProgress if another request holds the key and
+	// ErrIdempotencyKeyMismatch if the key was used with a different fingerprint.
+	// A nil response and nil error means the caller now owns the key and must
+	// call Complete or Release.
+	Begin(ctx context.Context, key, fingerprint string) (*IdempotentResponse, error)
+	Complete(ctx context.Context, key string, response IdempotentResponse) error
+	// Release gives up a claimed key without recording a response so it can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *IdempotentResponse
+	expiresAt   time.Time
+}
+
+type inMemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]*idempotencyEntry
+	ttl       time.Duration
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewInMemoryIdempotencyStore keeps keys for ttl after they are claimed.
+func NewInMemoryIdempotencyStore(ttl time.Duration) IdempotencyStore {
+	return newInMemoryIdempotencyStore(ttl, time.Now)
+}
+
+func newInMemoryIdempotencyStore(ttl time.Duration, now func() time.Time) *inMemoryIdempotencyStore {
+	return &inMemoryIdempotencyStore{
+		entries:   make(map[string]*idempotencyEntry),
+		ttl:       ttl,
+		now:       now,
+		lastSweep: now(),
+	}
+}
+
+func (s *inMemoryIdempotencyStore) Begin(ctx context.Context, key, fingerprint string) (*IdempotentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweepLocked(now)
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		s.entries[key] = &idempotencyEntry{fingerprint: fingerprint, expiresAt: now.Add(s.ttl)}
+		return nil, nil
+	}
+	if entry.fingerprint != fingerprint {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+	if entry.response == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	response := *entry.response
+	response.Body = append([]byte(nil), entry.response.Body...)
+	return &response, nil
+}
+
+func (s *inMemoryIdempotencyStore) Complete(ctx context.Context, key string, response IdempotentResponse) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return ErrNotFound
+	}
+	response.Body = append([]byte(nil), response.Body...)
+	entry.response = &response
+	return nil
+}
+
+func (s *inMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweepLocked drops expired keys at most once per ttl so abandoned keys do not accumulate.
+func (s *inMemoryIdempotencyStore) sweepLocked(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for key, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
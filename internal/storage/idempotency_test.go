@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestInMemoryIdempotencyStore_Begin(t *testing.T) {
+	ctx := context.Background()
+	response := IdempotentResponse{StatusCode: 200, Body: []byte(`{"id":"123"}`)}
+
+	tests := []struct {
+		name        string
+		setup       func(s IdempotencyStore)
+		fingerprint string
+		want        *IdempotentResponse
+		wantErr     error
+	}{
+		{
+			name:        "new key is claimed",
+			setup:       func(s IdempotencyStore) {},
+			fingerprint: "a",
+		},
+		{
+			name: "completed key replays response",
+			setup: func(s IdempotencyStore) {
+				s.Begin(ctx, "key", "a")
+				s.Complete(ctx, "key", response)
+			},
+			fingerprint: "a",
+			want:        &response,
+		},
+		{
+			name: "completed key with different body",
+			setup: func(s IdempotencyStore) {
+				s.Begin(ctx, "key", "a")
+				s.Complete(ctx, "key", response)
+			},
+			fingerprint: "b",
+			wantErr:     ErrIdempotencyKeyMismatch,
+		},
+		{
+			name: "key still in progress",
+			setup: func(s IdempotencyStore) {
+				s.Begin(ctx, "key", "a")
+			},
+			fingerprint: "a",
+			wantErr:     ErrIdempotencyKeyInProgress,
+		},
+		{
+			name: "released key can be claimed again",
+			setup: func(s IdempotencyStore) {
+				s.Begin(ctx, "key", "a")
+				s.Release(ctx, "key")
+			},
+			fingerprint: "b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewInMemoryIdempotencyStore(time.Hour)
+			tt.setup(s)
+
+			got, err := s.Begin(ctx, "key", tt.fingerprint)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInMemoryIdempotencyStore_KeysExpire(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newInMemoryIdempotencyStore(time.Hour, clock.Now)
+
+	_, err := s.Begin(ctx, "key", "a")
+	require.NoError(t, err)
+	require.NoError(t, s.Complete(ctx, "key", IdempotentResponse{StatusCode: 200}))
+	_, err = s.Begin(ctx, "abandoned", "a")
+	require.NoError(t, err)
+
+	clock.Advance(59 * time.Minute)
+	_, err = s.Begin(ctx, "key", "b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+
+	clock.Advance(time.Minute)
+	got, err := s.Begin(ctx, "key", "b")
+	assert.NoError(t, err)
+	assert.Nil(t, got, "expired key must be claimable with a new body")
+	assert.NotContains(t, s.entries, "abandoned", "expired keys must be swept")
+}
+
+func TestInMemoryIdempotencyStore_CompleteUnknownKey(t *testing.T) {
+	s := NewInMemoryIdempotencyStore(time.Hour)
+
+	err := s.Complete(context.Background(), "missing", IdempotentResponse{StatusCode: 200})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
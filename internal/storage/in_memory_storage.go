@@ -1,48 +1,462 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"github.com/google/uuid"
-	"golang.org/x/net/context"
+	"maps"
+	"slices"
 	"sync"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("receipt not found")
+	ErrDuplicate = errors.New("receipt with the same fingerprint already exists")
 )
 
 type Storage interface {
-	Store(ctx context.Context, key int) (string, error)
-	Retrieve(ctx context.Context, key string) (int, bool)
+	// Store persists the record and returns its ID: record.ID if set, otherwise a
+	// newly generated one. Callers that set the ID must make it unique.
+	// Fingerprints are unique: if a record with the same non-empty Fingerprint
+	// exists, nothing is stored and the existing ID is returned with ErrDuplicate;
+	// a record.ID set by the caller is then kept as an alias of the existing ID,
+	// which Retrieve and Void accept in its place.
+	// If the receipt has a CustomerID, its points are credited to the customer's
+	// ledger in the same atomic write.
+	Store(ctx context.Context, record models.ReceiptRecord) (string, error)
+	// Retrieve returns ErrNotFound when no record exists for id or its alias.
+	Retrieve(ctx context.Context, id string) (models.ReceiptRecord, error)
+	// Query returns one page of the records matching q. It returns
+	// ErrInvalidCursor if q.Cursor was not issued for the same sort order.
+	Query(ctx context.Context, q ReceiptQuery) (ReceiptPage, error)
+	// Balance returns the customer's points balance, zero if it has no ledger entries.
+	Balance(ctx context.Context, customerID string) (int, error)
+	// Ledger returns one page of the customer's ledger entries. It returns
+	// ErrInvalidCursor if q.Cursor was not issued for the same customer.
+	Ledger(ctx context.Context, customerID string, q LedgerQuery) (LedgerPage, error)
+	// Redeem debits points from the customer's balance and returns the ledger
+	// entry. It returns ErrInsufficientBalance if the balance is lower than points.
+	Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error)
+	// Void marks the record as voided and, if it has a customer, writes a reversal
+	// entry for its points in the same atomic write. It returns the voided record
+	// and the entry, ErrNotFound for unknown IDs and ErrAlreadyVoided on repeats.
+	Void(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error)
 }
 
 type inMemoryStore struct {
-	data map[string]int
-	mu   sync.RWMutex
+	data         map[string]models.ReceiptRecord
+	fingerprints map[string]string
+	// aliases maps the IDs duplicates were submitted under to the original's.
+	aliases map[string]string
+	// ledger holds every customer's entries in the order they were written.
+	ledger map[string][]models.LedgerEntry
+	// apiKeys holds every API key in the order they were created, indexed by
+	// ID and hash in apiKeyIDs and apiKeyHashes.
+	apiKeys      []models.APIKey
+	apiKeyIDs    map[string]int
+	apiKeyHashes map[string]int
+	mu           sync.RWMutex
 }
 
 func NewInMemoryStore() Storage {
+	return newInMemoryStore()
+}
+
+func newInMemoryStore() *inMemoryStore {
 	return &inMemoryStore{
-		data: make(map[string]int),
+		data:         make(map[string]models.ReceiptRecord),
+		fingerprints: make(map[string]string),
+		aliases:      make(map[string]string),
+		ledger:       make(map[string][]models.LedgerEntry),
+		apiKeyIDs:    make(map[string]int),
+		apiKeyHashes: make(map[string]int),
 	}
 }
 
-func (s *inMemoryStore) Store(ctx context.Context, points int) (string, error) {
+func (s *inMemoryStore) Store(ctx context.Context, record models.ReceiptRecord) (string, error) {
 	select {
 	case <-ctx.Done():
 		return "", ctx.Err()
 	default:
 		s.mu.Lock()
 		defer s.mu.Unlock()
-		id := uuid.New().String()
-		s.data[id] = points
-		return id, nil
+		if id, ok := s.fingerprints[record.Fingerprint]; ok {
+			if record.ID != "" {
+				s.aliases[record.ID] = id
+			}
+			return id, ErrDuplicate
+		}
+		if record.ID == "" {
+			record.ID = uuid.New().String()
+		}
+		s.putLocked(record, creditEntry(record, s.balanceLocked(record.Receipt.CustomerID)))
+		return record.ID, nil
+	}
+}
+
+// CheckHealth always succeeds; the store lives in process memory.
+func (s *inMemoryStore) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// put stores record under its own ID, replacing any existing record, and
+// appends entry to the ledger if it is not nil.
+func (s *inMemoryStore) put(record models.ReceiptRecord, entry *models.LedgerEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putLocked(record, entry)
+}
+
+func (s *inMemoryStore) putLocked(record models.ReceiptRecord, entry *models.LedgerEntry) {
+	s.data[record.ID] = cloneRecord(record)
+	if record.Fingerprint != "" {
+		s.fingerprints[record.Fingerprint] = record.ID
+	}
+	if entry != nil {
+		s.appendLedgerLocked(*entry)
+	}
+}
+
+func (s *inMemoryStore) appendLedger(entry models.LedgerEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.appendLedgerLocked(entry)
+}
+
+func (s *inMemoryStore) appendLedgerLocked(entry models.LedgerEntry) {
+	s.ledger[entry.CustomerID] = append(s.ledger[entry.CustomerID], entry)
+}
+
+func (s *inMemoryStore) balance(customerID string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.balanceLocked(customerID)
+}
+
+func (s *inMemoryStore) balanceLocked(customerID string) int {
+	entries := s.ledger[customerID]
+	if len(entries) == 0 {
+		return 0
+	}
+	return entries[len(entries)-1].Balance
+}
+
+// ledgerEntries returns a copy of every ledger entry, each customer's in order.
+func (s *inMemoryStore) ledgerEntries() []models.LedgerEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []models.LedgerEntry
+	for _, customerEntries := range s.ledger {
+		entries = append(entries, customerEntries...)
+	}
+	return entries
+}
+
+func (s *inMemoryStore) Balance(ctx context.Context, customerID string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return s.balance(customerID), nil
+}
+
+// Ledger positions cursors by the number of the customer's entries already returned.
+func (s *inMemoryStore) Ledger(ctx context.Context, customerID string, q LedgerQuery) (LedgerPage, error) {
+	if err := ctx.Err(); err != nil {
+		return LedgerPage{}, err
+	}
+
+	q = q.normalized()
+	after, err := decodeLedgerCursor(customerID, q.Cursor)
+	if err != nil {
+		return LedgerPage{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := s.ledger[customerID]
+	start := min(int(after), len(entries))
+	end := min(start+q.Limit, len(entries))
+
+	page := LedgerPage{Entries: slices.Clone(entries[start:end])}
+	if end < len(entries) {
+		page.NextCursor = encodeLedgerCursor(customerID, int64(end))
+	}
+	return page, nil
+}
+
+func (s *inMemoryStore) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return models.LedgerEntry{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, err := redemptionEntry(customerID, points, description, s.balanceLocked(customerID))
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	s.appendLedgerLocked(entry)
+	return entry, nil
+}
+
+func (s *inMemoryStore) Void(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, entry, err := s.voidLocked(id)
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	s.putLocked(record, entry)
+	return cloneRecord(record), entry, nil
+}
+
+// voidLocked returns the voided copy of the record with the given ID and its
+// reversal entry without storing either.
+func (s *inMemoryStore) voidLocked(id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	record, ok := s.lookupLocked(id)
+	if !ok {
+		return models.ReceiptRecord{}, nil, ErrNotFound
+	}
+	record = cloneRecord(record)
+	entry, err := voidRecord(&record, s.balanceLocked(record.Receipt.CustomerID), time.Now().UTC())
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	return record, entry, nil
+}
+
+func (s *inMemoryStore) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key = newAPIKey(key, time.Now().UTC())
+	s.putAPIKeyLocked(key)
+	return cloneAPIKey(key), nil
+}
+
+func (s *inMemoryStore) APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
 	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.apiKeyHashes[hash]
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(s.apiKeys[i]), nil
+}
+
+func (s *inMemoryStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.apiKeyList(), nil
+}
+
+func (s *inMemoryStore) RotateAPIKey(ctx context.Context, id, hash string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.apiKeyLocked(id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if err := rotateAPIKey(&key, hash, time.Now().UTC()); err != nil {
+		return models.APIKey{}, err
+	}
+	s.putAPIKeyLocked(key)
+	return cloneAPIKey(key), nil
+}
+
+func (s *inMemoryStore) RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return models.APIKey{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := s.apiKeyLocked(id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	revokeAPIKey(&key, time.Now().UTC())
+	s.putAPIKeyLocked(key)
+	return cloneAPIKey(key), nil
+}
+
+// apiKeyLocked returns a copy of the API key with the given ID.
+func (s *inMemoryStore) apiKeyLocked(id string) (models.APIKey, error) {
+	i, ok := s.apiKeyIDs[id]
+	if !ok {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	return cloneAPIKey(s.apiKeys[i]), nil
+}
+
+// putAPIKey stores key under its own ID, replacing any existing key and its hash.
+func (s *inMemoryStore) putAPIKey(key models.APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.putAPIKeyLocked(key)
+}
+
+func (s *inMemoryStore) putAPIKeyLocked(key models.APIKey) {
+	i, ok := s.apiKeyIDs[key.ID]
+	if ok {
+		delete(s.apiKeyHashes, s.apiKeys[i].Hash)
+		s.apiKeys[i] = cloneAPIKey(key)
+	} else {
+		i = len(s.apiKeys)
+		s.apiKeys = append(s.apiKeys, cloneAPIKey(key))
+		s.apiKeyIDs[key.ID] = i
+	}
+	s.apiKeyHashes[key.Hash] = i
+}
+
+// apiKeyList returns a copy of every API key, oldest first.
+func (s *inMemoryStore) apiKeyList() []models.APIKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]models.APIKey, len(s.apiKeys))
+	for i, key := range s.apiKeys {
+		keys[i] = cloneAPIKey(key)
+	}
+	return keys
+}
+
+// findFingerprint returns the ID of the record with the given fingerprint.
+func (s *inMemoryStore) findFingerprint(fingerprint string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.fingerprints[fingerprint]
+	return id, ok
 }
 
-func (s *inMemoryStore) Retrieve(ctx context.Context, id string) (int, bool) {
+// records returns a copy of every stored record.
+func (s *inMemoryStore) records() []models.ReceiptRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	records := make([]models.ReceiptRecord, 0, len(s.data))
+	for _, record := range s.data {
+		records = append(records, cloneRecord(record))
+	}
+	return records
+}
+
+func (s *inMemoryStore) Retrieve(ctx context.Context, id string) (models.ReceiptRecord, error) {
 	select {
 	case <-ctx.Done():
-		return 0, false
+		return models.ReceiptRecord{}, ctx.Err()
 	default:
 		s.mu.RLock()
 		defer s.mu.RUnlock()
-		points, exists := s.data[id]
-		return points, exists
+		record, exists := s.lookupLocked(id)
+		if !exists {
+			return models.ReceiptRecord{}, ErrNotFound
+		}
+		return cloneRecord(record), nil
+	}
+}
+
+// lookupLocked returns the record stored under id or under the ID it is an alias of.
+func (s *inMemoryStore) lookupLocked(id string) (models.ReceiptRecord, bool) {
+	if record, ok := s.data[id]; ok {
+		return record, true
+	}
+	record, ok := s.data[s.aliases[id]]
+	return record, ok
+}
+
+func (s *inMemoryStore) putAlias(alias, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliases[alias] = id
+}
+
+// aliasList returns a copy of every alias.
+func (s *inMemoryStore) aliasList() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return maps.Clone(s.aliases)
+}
+
+// cloneRecord copies the slices of a record so stored data cannot be modified through the caller's copy.
+func cloneRecord(r models.ReceiptRecord) models.ReceiptRecord {
+	r.Receipt.Items = append([]models.Item(nil), r.Receipt.Items...)
+	r.Points.Rules = append([]models.RuleAward(nil), r.Points.Rules...)
+	if r.VoidedAt != nil {
+		voidedAt := *r.VoidedAt
+		r.VoidedAt = &voidedAt
+	}
+	return r
+}
+
+func (s *inMemoryStore) Query(ctx context.Context, q ReceiptQuery) (ReceiptPage, error) {
+	if err := ctx.Err(); err != nil {
+		return ReceiptPage{}, err
+	}
+
+	q = q.normalized()
+	after, err := decodeCursor(q)
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+
+	type keyedRecord struct {
+		key    sortKey
+		record models.ReceiptRecord
+	}
+
+	s.mu.RLock()
+	matched := make([]keyedRecord, 0)
+	for _, record := range s.data {
+		if !q.matches(record) {
+			continue
+		}
+		key := recordSortKey(q.SortBy, record)
+		if after != nil && !isAfter(key, *after, q.Descending) {
+			continue
+		}
+		matched = append(matched, keyedRecord{key: key, record: record})
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(matched, func(a, b keyedRecord) int {
+		if q.Descending {
+			return b.key.compare(a.key)
+		}
+		return a.key.compare(b.key)
+	})
+
+	var page ReceiptPage
+	for i, kr := range matched {
+		if i == q.Limit {
+			page.NextCursor = encodeCursor(q, page.Records[len(page.Records)-1])
+			break
+		}
+		page.Records = append(page.Records, cloneRecord(kr.record))
+	}
+	return page, nil
+}
+
+func isAfter(key, cursor sortKey, descending bool) bool {
+	if descending {
+		return key.compare(cursor) < 0
 	}
+	return key.compare(cursor) > 0
 }
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+var (
+	ErrInsufficientBalance = errors.New("insufficient points balance")
+	ErrAlreadyVoided       = errors.New("receipt is already voided")
+)
+
+// LedgerQuery selects one page of a customer's ledger, oldest entry first.
+type LedgerQuery struct {
+	// Limit is clamped to [1, MaxPageSize]; zero means DefaultPageSize.
+	Limit int
+	// Cursor is LedgerPage.NextCursor from the previous page, or empty for the first page.
+	Cursor string
+}
+
+type LedgerPage struct {
+	Entries []models.LedgerEntry
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+func (q LedgerQuery) normalized() LedgerQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return q
+}
+
+// ledgerCursor is the content of an opaque ledger cursor. After is a position in
+// the customer's ledger whose meaning depends on the backend.
+type ledgerCursor struct {
+	CustomerID string `json:"customer"`
+	After      int64  `json:"after"`
+}
+
+func encodeLedgerCursor(customerID string, after int64) string {
+	payload, _ := json.Marshal(ledgerCursor{CustomerID: customerID, After: after})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeLedgerCursor returns the position after which the page starts, zero for the first page.
+func decodeLedgerCursor(customerID, cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	var c ledgerCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return 0, ErrInvalidCursor
+	}
+	if c.CustomerID != customerID || c.After < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return c.After, nil
+}
+
+// creditEntry is the ledger entry crediting record's points to its customer,
+// given the customer's current balance. It is nil for receipts without a customer.
+func creditEntry(record models.ReceiptRecord, balance int) *models.LedgerEntry {
+	if record.Receipt.CustomerID == "" {
+		return nil
+	}
+	return &models.LedgerEntry{
+		ID:         uuid.New().String(),
+		CustomerID: record.Receipt.CustomerID,
+		Type:       models.LedgerEntryCredit,
+		ReceiptID:  record.ID,
+		Points:     record.Points.Total,
+		Balance:    balance + record.Points.Total,
+		CreatedAt:  record.ProcessedAt,
+	}
+}
+
+// redemptionEntry is the ledger entry debiting points from a customer with the
+// given balance. It returns ErrInsufficientBalance if the balance does not cover them.
+func redemptionEntry(customerID string, points int, description string, balance int) (models.LedgerEntry, error) {
+	if points <= 0 {
+		return models.LedgerEntry{}, fmt.Errorf("points to redeem must be positive, got %d", points)
+	}
+	if points > balance {
+		return models.LedgerEntry{}, ErrInsufficientBalance
+	}
+	return models.LedgerEntry{
+		ID:          uuid.New().String(),
+		CustomerID:  customerID,
+		Type:        models.LedgerEntryRedemption,
+		Points:      -points,
+		Balance:     balance - points,
+		Description: description,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// voidRecord marks record as voided at now and returns the ledger entry taking
+// its points back from the customer, nil for receipts without a customer. The
+// balance may become negative if the points were already redeemed.
+func voidRecord(record *models.ReceiptRecord, balance int, now time.Time) (*models.LedgerEntry, error) {
+	if record.VoidedAt != nil {
+		return nil, ErrAlreadyVoided
+	}
+	record.VoidedAt = &now
+	if record.Receipt.CustomerID == "" {
+		return nil, nil
+	}
+	return &models.LedgerEntry{
+		ID:         uuid.New().String(),
+		CustomerID: record.Receipt.CustomerID,
+		Type:       models.LedgerEntryReversal,
+		ReceiptID:  record.ID,
+		Points:     -record.Points.Total,
+		Balance:    balance - record.Points.Total,
+		CreatedAt:  now,
+	}, nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE receipts (
+    id            TEXT PRIMARY KEY,
+    retailer      TEXT    NOT NULL,
+    purchase_date TEXT    NOT NULL,
+    purchase_time TEXT    NOT NULL,
+    total_cents   INTEGER NOT NULL,
+    items         TEXT    NOT NULL,
+    points        INTEGER NOT NULL,
+    breakdown     TEXT    NOT NULL,
+    processed_at  INTEGER NOT NULL,
+    rules_version TEXT    NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE receipts ADD COLUMN fingerprint TEXT;
+
+CREATE UNIQUE INDEX receipts_fingerprint ON receipts (fingerprint) WHERE fingerprint IS NOT NULL;
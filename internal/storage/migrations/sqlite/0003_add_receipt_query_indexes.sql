@@ -0,0 +1,5 @@
+CREATE INDEX receipts_processed_at ON receipts (processed_at, id);
+
+CREATE INDEX receipts_purchase_date ON receipts (purchase_date, id);
+
+CREATE INDEX receipts_points ON receipts (points, id);
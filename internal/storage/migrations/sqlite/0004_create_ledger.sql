@@ -0,0 +1,14 @@
+ALTER TABLE receipts ADD COLUMN customer_id TEXT;
+
+CREATE TABLE ledger_entries (
+    seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+    id          TEXT    NOT NULL UNIQUE,
+    customer_id TEXT    NOT NULL,
+    type        TEXT    NOT NULL,
+    receipt_id  TEXT REFERENCES receipts (id),
+    points      INTEGER NOT NULL,
+    balance     INTEGER NOT NULL,
+    created_at  INTEGER NOT NULL
+);
+
+CREATE INDEX ledger_entries_customer ON ledger_entries (customer_id, seq);
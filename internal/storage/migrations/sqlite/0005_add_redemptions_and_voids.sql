@@ -0,0 +1,13 @@
+ALTER TABLE receipts ADD COLUMN voided_at INTEGER;
+
+ALTER TABLE ledger_entries ADD COLUMN description TEXT;
+
+CREATE TRIGGER ledger_entries_no_update BEFORE UPDATE ON ledger_entries
+BEGIN
+    SELECT RAISE(ABORT, 'ledger entries are immutable');
+END;
+
+CREATE TRIGGER ledger_entries_no_delete BEFORE DELETE ON ledger_entries
+BEGIN
+    SELECT RAISE(ABORT, 'ledger entries are immutable');
+END;
@@ -0,0 +1,17 @@
+CREATE TABLE webhook_subscriptions (
+    seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+    id         TEXT    NOT NULL UNIQUE,
+    url        TEXT    NOT NULL,
+    secret     TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE webhook_dead_letters (
+    seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id TEXT    NOT NULL,
+    url             TEXT    NOT NULL,
+    event           TEXT    NOT NULL,
+    attempts        INTEGER NOT NULL,
+    last_error      TEXT    NOT NULL,
+    failed_at       INTEGER NOT NULL
+);
@@ -0,0 +1,4 @@
+CREATE TABLE receipt_aliases (
+    id         TEXT PRIMARY KEY,
+    receipt_id TEXT NOT NULL
+);
@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type SortField string
+
+const (
+	SortByProcessedAt  SortField = "processedAt"
+	SortByPurchaseDate SortField = "purchaseDate"
+	SortByPoints       SortField = "points"
+	SortByRetailer     SortField = "retailer"
+)
+
+// Valid reports whether f is one of the supported sort fields.
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByProcessedAt, SortByPurchaseDate, SortByPoints, SortByRetailer:
+		return true
+	}
+	return false
+}
+
+// ReceiptQuery selects stored receipts. Zero-valued filters match everything.
+// Ranges are inclusive except ProcessedBefore, which is exclusive.
+type ReceiptQuery struct {
+	// Retailer matches retailer names containing it, ignoring case.
+	Retailer         string
+	PurchaseDateFrom string
+	PurchaseDateTo   string
+	MinPoints        *int
+	MaxPoints        *int
+	ProcessedAfter   time.Time
+	ProcessedBefore  time.Time
+
+	// SortBy defaults to SortByProcessedAt. Ties are broken by ID.
+	SortBy     SortField
+	Descending bool
+	// Limit is clamped to [1, MaxPageSize]; zero means DefaultPageSize.
+	Limit int
+	// Cursor is ReceiptPage.NextCursor from the previous page, or empty for the first page.
+	Cursor string
+}
+
+type ReceiptPage struct {
+	Records []models.ReceiptRecord
+	// NextCursor is empty on the last page.
+	NextCursor string
+}
+
+// normalized applies the query defaults.
+func (q ReceiptQuery) normalized() ReceiptQuery {
+	if q.SortBy == "" {
+		q.SortBy = SortByProcessedAt
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	return q
+}
+
+// sortKey is the position of a record in a sort order. Only one of Int and Str
+// is used, depending on the sort field.
+type sortKey struct {
+	Int int64  `json:"i,omitempty"`
+	Str string `json:"s,omitempty"`
+	ID  string `json:"id"`
+}
+
+func recordSortKey(field SortField, r models.ReceiptRecord) sortKey {
+	switch field {
+	case SortByPurchaseDate:
+		return sortKey{Str: r.Receipt.PurchaseDate, ID: r.ID}
+	case SortByPoints:
+		return sortKey{Int: int64(r.Points.Total), ID: r.ID}
+	case SortByRetailer:
+		return sortKey{Str: r.Receipt.Retailer, ID: r.ID}
+	default:
+		return sortKey{Int: r.ProcessedAt.UnixNano(), ID: r.ID}
+	}
+}
+
+func (k sortKey) compare(other sortKey) int {
+	if c := cmp.Compare(k.Int, other.Int); c != 0 {
+		return c
+	}
+	if c := strings.Compare(k.Str, other.Str); c != 0 {
+		return c
+	}
+	return strings.Compare(k.ID, other.ID)
+}
+
+// pageCursor is the content of an opaque cursor. It records the sort order it
+// was issued for so it cannot be replayed against a different one.
+type pageCursor struct {
+	SortBy     SortField `json:"sort"`
+	Descending bool      `json:"desc,omitempty"`
+	After      sortKey   `json:"after"`
+}
+
+func encodeCursor(q ReceiptQuery, last models.ReceiptRecord) string {
+	payload, _ := json.Marshal(pageCursor{
+		SortBy:     q.SortBy,
+		Descending: q.Descending,
+		After:      recordSortKey(q.SortBy, last),
+	})
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+// decodeCursor returns the key after which the page starts, or nil for the first page.
+func decodeCursor(q ReceiptQuery) (*sortKey, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.SortBy != q.SortBy || c.Descending != q.Descending {
+		return nil, ErrInvalidCursor
+	}
+	return &c.After, nil
+}
+
+func (q ReceiptQuery) matches(r models.ReceiptRecord) bool {
+	if q.Retailer != "" && !strings.Contains(strings.ToLower(r.Receipt.Retailer), strings.ToLower(q.Retailer)) {
+		return false
+	}
+	if q.PurchaseDateFrom != "" && r.Receipt.PurchaseDate < q.PurchaseDateFrom {
+		return false
+	}
+	if q.PurchaseDateTo != "" && r.Receipt.PurchaseDate > q.PurchaseDateTo {
+		return false
+	}
+	if q.MinPoints != nil && r.Points.Total < *q.MinPoints {
+		return false
+	}
+	if q.MaxPoints != nil && r.Points.Total > *q.MaxPoints {
+		return false
+	}
+	if !q.ProcessedAfter.IsZero() && r.ProcessedAt.Before(q.ProcessedAfter) {
+		return false
+	}
+	if !q.ProcessedBefore.IsZero() && !r.ProcessedAt.Before(q.ProcessedBefore) {
+		return false
+	}
+	return true
+}
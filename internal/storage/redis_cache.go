@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RedisCache is a Cache shared between replicas, backed by any server that
+// speaks the Redis protocol. Values are written with SET ... PX and read with GET.
+type RedisCache struct {
+	client    *redis.Client
+	keyPrefix string
+	log       *zap.Logger
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewRedisCache connects to the server described by opts and verifies it is
+// reachable. Every key is stored under keyPrefix so the database can be shared.
+func NewRedisCache(ctx context.Context, log *zap.Logger, opts *redis.Options, keyPrefix string) (*RedisCache, error) {
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error connecting to redis at %s: %w", opts.Addr, err)
+	}
+
+	return &RedisCache{
+		client:    client,
+		keyPrefix: keyPrefix,
+		log:       log,
+	}, nil
+}
+
+// Load treats every server error as a miss so callers fall back to storage.
+func (c *RedisCache) Load(ctx context.Context, key string) (int, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+
+	raw, err := c.client.Get(ctx, c.keyPrefix+key).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			c.log.Warn("Error reading from redis cache", zap.String("key", key), zap.Error(err))
+		}
+		c.misses.Add(1)
+		return 0, false
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		c.log.Warn("Discarding malformed redis cache value", zap.String("key", key), zap.Error(err))
+		c.misses.Add(1)
+		return 0, false
+	}
+	c.hits.Add(1)
+	return value, true
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value int, expiration time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	args := []any{"SET", c.keyPrefix + key, strconv.Itoa(value)}
+	if expiration > 0 {
+		// PX takes whole milliseconds; round up so short expirations are not rejected as zero.
+		ms := (expiration + time.Millisecond - 1) / time.Millisecond
+		args = append(args, "PX", int64(ms))
+	}
+
+	if err := c.client.Do(ctx, args...).Err(); err != nil {
+		return fmt.Errorf("error writing to redis cache: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	if err := c.client.Del(ctx, c.keyPrefix+key).Err(); err != nil {
+		return fmt.Errorf("error deleting from redis cache: %w", err)
+	}
+	return nil
+}
+
+// Stats reports the lookups made by this replica. Entries, evictions and
+// expirations are managed by the server and reported as zero.
+func (c *RedisCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+}
+
+// CheckHealth pings the server.
+func (c *RedisCache) CheckHealth(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
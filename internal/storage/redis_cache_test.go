@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"testing"
+	"time"
+)
+
+func newTestRedisCache(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	c, err := NewRedisCache(context.Background(), zap.NewNop(), &redis.Options{Addr: mr.Addr()}, "points:")
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c, mr
+}
+
+func TestRedisCache_SetUsesPrefixAndMillisecondExpiry(t *testing.T) {
+	tests := []struct {
+		name       string
+		expiration time.Duration
+		wantTTL    time.Duration
+	}{
+		{name: "millisecond precision", expiration: 1500 * time.Millisecond, wantTTL: 1500 * time.Millisecond},
+		{name: "sub-millisecond rounds up", expiration: time.Microsecond, wantTTL: time.Millisecond},
+		{name: "no expiration", expiration: 0, wantTTL: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, mr := newTestRedisCache(t)
+
+			require.NoError(t, c.Set(context.Background(), "abc", 42, tt.expiration))
+
+			value, err := mr.Get("points:abc")
+			require.NoError(t, err)
+			assert.Equal(t, "42", value)
+			assert.Equal(t, tt.wantTTL, mr.TTL("points:abc"))
+		})
+	}
+}
+
+func TestRedisCache_LoadMalformedValueIsMiss(t *testing.T) {
+	c, mr := newTestRedisCache(t)
+	require.NoError(t, mr.Set("points:abc", "not-a-number"))
+
+	_, ok := c.Load(context.Background(), "abc")
+	assert.False(t, ok)
+}
+
+func TestRedisCache_ServerUnavailable(t *testing.T) {
+	c, mr := newTestRedisCache(t)
+	mr.Close()
+
+	_, ok := c.Load(context.Background(), "abc")
+	assert.False(t, ok)
+	assert.Error(t, c.Set(context.Background(), "abc", 1, time.Minute))
+	assert.Error(t, c.CheckHealth(context.Background()))
+}
+
+func TestNewRedisCache_Unreachable(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+
+	_, err := NewRedisCache(context.Background(), zap.NewNop(), &redis.Options{Addr: addr, MaxRetries: -1}, "")
+	assert.Error(t, err)
+}
+
+func TestRedisCache_Stats(t *testing.T) {
+	c, mr := newTestRedisCache(t)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "abc", 42, time.Minute))
+	require.NoError(t, mr.Set("points:bad", "not-a-number"))
+
+	c.Load(ctx, "abc")
+	c.Load(ctx, "abc")
+	c.Load(ctx, "missing")
+	c.Load(ctx, "bad")
+
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 2}, c.Stats())
+}
@@ -0,0 +1,776 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io/fs"
+	_ "modernc.org/sqlite"
+	"sort"
+	"strconv"
+	"strings"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// SQLiteStore is a durable Storage backed by an embedded SQLite database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and applies pending migrations.
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	// Transactions take the write lock up front so balance reads and ledger writes cannot interleave.
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening sqlite database: %w", err)
+	}
+
+	if err := migrateSQLite(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// CheckHealth verifies the database can still be reached.
+func (s *SQLiteStore) CheckHealth(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLiteStore) Store(ctx context.Context, record models.ReceiptRecord) (string, error) {
+	items, err := json.Marshal(record.Receipt.Items)
+	if err != nil {
+		return "", fmt.Errorf("error encoding items: %w", err)
+	}
+	breakdown, err := json.Marshal(record.Points.Rules)
+	if err != nil {
+		return "", fmt.Errorf("error encoding points breakdown: %w", err)
+	}
+
+	alias := record.ID
+	if record.ID == "" {
+		record.ID = uuid.New().String()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO receipts (id, retailer, purchase_date, purchase_time, total_cents, items, points, breakdown, processed_at, rules_version, fingerprint, customer_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (fingerprint) WHERE fingerprint IS NOT NULL DO NOTHING`,
+		record.ID,
+		record.Receipt.Retailer,
+		record.Receipt.PurchaseDate,
+		record.Receipt.PurchaseTime,
+		record.Receipt.Total.Cents(),
+		string(items),
+		record.Points.Total,
+		string(breakdown),
+		record.ProcessedAt.UnixNano(),
+		record.RulesVersion,
+		sql.NullString{String: record.Fingerprint, Valid: record.Fingerprint != ""},
+		sql.NullString{String: record.Receipt.CustomerID, Valid: record.Receipt.CustomerID != ""},
+	)
+	if err != nil {
+		return "", fmt.Errorf("error inserting receipt: %w", err)
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return "", fmt.Errorf("error inserting receipt: %w", err)
+	}
+	if inserted == 0 {
+		var existing string
+		err := tx.QueryRowContext(ctx, `SELECT id FROM receipts WHERE fingerprint = ?`, record.Fingerprint).Scan(&existing)
+		if err != nil {
+			return "", fmt.Errorf("error reading duplicate receipt: %w", err)
+		}
+		if alias != "" {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO receipt_aliases (id, receipt_id) VALUES (?, ?) ON CONFLICT (id) DO NOTHING`, alias, existing); err != nil {
+				return "", fmt.Errorf("error inserting receipt alias: %w", err)
+			}
+			if err := tx.Commit(); err != nil {
+				return "", fmt.Errorf("error committing receipt alias: %w", err)
+			}
+		}
+		return existing, ErrDuplicate
+	}
+
+	if record.Receipt.CustomerID != "" {
+		balance, err := sqliteBalance(ctx, tx, record.Receipt.CustomerID)
+		if err != nil {
+			return "", err
+		}
+		if err := insertLedgerEntry(ctx, tx, *creditEntry(record, balance)); err != nil {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("error committing receipt: %w", err)
+	}
+	return record.ID, nil
+}
+
+func (s *SQLiteStore) Retrieve(ctx context.Context, id string) (models.ReceiptRecord, error) {
+	return sqliteRetrieve(ctx, s.db, id)
+}
+
+func sqliteRetrieve(ctx context.Context, db sqlExecutor, id string) (models.ReceiptRecord, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT id, retailer, purchase_date, purchase_time, total_cents, items, points, breakdown, processed_at, rules_version, COALESCE(fingerprint, ''), COALESCE(customer_id, ''), voided_at
+		FROM receipts WHERE id = COALESCE((SELECT receipt_id FROM receipt_aliases WHERE id = ?), ?)`, id, id)
+
+	record, err := scanReceiptRecord(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ReceiptRecord{}, ErrNotFound
+	}
+	if err != nil {
+		return models.ReceiptRecord{}, fmt.Errorf("error reading receipt: %w", err)
+	}
+	return record, nil
+}
+
+var sqliteSortColumns = map[SortField]string{
+	SortByProcessedAt:  "processed_at",
+	SortByPurchaseDate: "purchase_date",
+	SortByPoints:       "points",
+	SortByRetailer:     "retailer",
+}
+
+func (s *SQLiteStore) Query(ctx context.Context, q ReceiptQuery) (ReceiptPage, error) {
+	q = q.normalized()
+	after, err := decodeCursor(q)
+	if err != nil {
+		return ReceiptPage{}, err
+	}
+	column, ok := sqliteSortColumns[q.SortBy]
+	if !ok {
+		return ReceiptPage{}, fmt.Errorf("unsupported sort field %q", q.SortBy)
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if q.Retailer != "" {
+		where = append(where, `retailer LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.Retailer)+"%")
+	}
+	if q.PurchaseDateFrom != "" {
+		where = append(where, "purchase_date >= ?")
+		args = append(args, q.PurchaseDateFrom)
+	}
+	if q.PurchaseDateTo != "" {
+		where = append(where, "purchase_date <= ?")
+		args = append(args, q.PurchaseDateTo)
+	}
+	if q.MinPoints != nil {
+		where = append(where, "points >= ?")
+		args = append(args, *q.MinPoints)
+	}
+	if q.MaxPoints != nil {
+		where = append(where, "points <= ?")
+		args = append(args, *q.MaxPoints)
+	}
+	if !q.ProcessedAfter.IsZero() {
+		where = append(where, "processed_at >= ?")
+		args = append(args, q.ProcessedAfter.UnixNano())
+	}
+	if !q.ProcessedBefore.IsZero() {
+		where = append(where, "processed_at < ?")
+		args = append(args, q.ProcessedBefore.UnixNano())
+	}
+
+	direction, op := "ASC", ">"
+	if q.Descending {
+		direction, op = "DESC", "<"
+	}
+	if after != nil {
+		var value any = after.Int
+		if q.SortBy == SortByPurchaseDate || q.SortBy == SortByRetailer {
+			value = after.Str
+		}
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op))
+		args = append(args, value, value, after.ID)
+	}
+
+	query := `
+		SELECT id, retailer, purchase_date, purchase_time, total_cents, items, points, breakdown, processed_at, rules_version, COALESCE(fingerprint, ''), COALESCE(customer_id, ''), voided_at
+		FROM receipts`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// One extra row tells whether there is another page.
+	query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?", column, direction)
+	args = append(args, q.Limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ReceiptPage{}, fmt.Errorf("error querying receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var page ReceiptPage
+	for rows.Next() {
+		record, err := scanReceiptRecord(rows)
+		if err != nil {
+			return ReceiptPage{}, fmt.Errorf("error reading receipt: %w", err)
+		}
+		if len(page.Records) == q.Limit {
+			page.NextCursor = encodeCursor(q, page.Records[len(page.Records)-1])
+			break
+		}
+		page.Records = append(page.Records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return ReceiptPage{}, fmt.Errorf("error querying receipts: %w", err)
+	}
+	return page, nil
+}
+
+// sqlExecutor is satisfied by *sql.DB and *sql.Tx.
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func sqliteBalance(ctx context.Context, db sqlExecutor, customerID string) (int, error) {
+	var balance int
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE((SELECT balance FROM ledger_entries WHERE customer_id = ? ORDER BY seq DESC LIMIT 1), 0)`,
+		customerID,
+	).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("error reading balance: %w", err)
+	}
+	return balance, nil
+}
+
+func insertLedgerEntry(ctx context.Context, db sqlExecutor, entry models.LedgerEntry) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ledger_entries (id, customer_id, type, receipt_id, points, balance, description, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID,
+		entry.CustomerID,
+		entry.Type,
+		sql.NullString{String: entry.ReceiptID, Valid: entry.ReceiptID != ""},
+		entry.Points,
+		entry.Balance,
+		sql.NullString{String: entry.Description, Valid: entry.Description != ""},
+		entry.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting ledger entry: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Balance(ctx context.Context, customerID string) (int, error) {
+	return sqliteBalance(ctx, s.db, customerID)
+}
+
+func (s *SQLiteStore) Redeem(ctx context.Context, customerID string, points int, description string) (models.LedgerEntry, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.LedgerEntry{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	balance, err := sqliteBalance(ctx, tx, customerID)
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	entry, err := redemptionEntry(customerID, points, description, balance)
+	if err != nil {
+		return models.LedgerEntry{}, err
+	}
+	if err := insertLedgerEntry(ctx, tx, entry); err != nil {
+		return models.LedgerEntry{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.LedgerEntry{}, fmt.Errorf("error committing redemption: %w", err)
+	}
+	return entry, nil
+}
+
+func (s *SQLiteStore) Void(ctx context.Context, id string) (models.ReceiptRecord, *models.LedgerEntry, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.ReceiptRecord{}, nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	record, err := sqliteRetrieve(ctx, tx, id)
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	balance, err := sqliteBalance(ctx, tx, record.Receipt.CustomerID)
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+	entry, err := voidRecord(&record, balance, time.Now().UTC())
+	if err != nil {
+		return models.ReceiptRecord{}, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE receipts SET voided_at = ? WHERE id = ?`, record.VoidedAt.UnixNano(), record.ID); err != nil {
+		return models.ReceiptRecord{}, nil, fmt.Errorf("error voiding receipt: %w", err)
+	}
+	if entry != nil {
+		if err := insertLedgerEntry(ctx, tx, *entry); err != nil {
+			return models.ReceiptRecord{}, nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.ReceiptRecord{}, nil, fmt.Errorf("error committing void: %w", err)
+	}
+	return record, entry, nil
+}
+
+// Ledger positions cursors by the sequence number of the last entry returned.
+func (s *SQLiteStore) Ledger(ctx context.Context, customerID string, q LedgerQuery) (LedgerPage, error) {
+	q = q.normalized()
+	after, err := decodeLedgerCursor(customerID, q.Cursor)
+	if err != nil {
+		return LedgerPage{}, err
+	}
+
+	// One extra row tells whether there is another page.
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT seq, id, customer_id, type, COALESCE(receipt_id, ''), points, balance, COALESCE(description, ''), created_at
+		FROM ledger_entries WHERE customer_id = ? AND seq > ?
+		ORDER BY seq LIMIT ?`,
+		customerID, after, q.Limit+1,
+	)
+	if err != nil {
+		return LedgerPage{}, fmt.Errorf("error querying ledger: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		page    LedgerPage
+		lastSeq int64
+	)
+	for rows.Next() {
+		if len(page.Entries) == q.Limit {
+			page.NextCursor = encodeLedgerCursor(customerID, lastSeq)
+			break
+		}
+
+		var (
+			entry     models.LedgerEntry
+			createdAt int64
+		)
+		err := rows.Scan(&lastSeq, &entry.ID, &entry.CustomerID, &entry.Type, &entry.ReceiptID, &entry.Points, &entry.Balance, &entry.Description, &createdAt)
+		if err != nil {
+			return LedgerPage{}, fmt.Errorf("error reading ledger entry: %w", err)
+		}
+		entry.CreatedAt = time.Unix(0, createdAt).UTC()
+		page.Entries = append(page.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return LedgerPage{}, fmt.Errorf("error querying ledger: %w", err)
+	}
+	return page, nil
+}
+
+func (s *SQLiteStore) CreateAPIKey(ctx context.Context, key models.APIKey) (models.APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error encoding api key scopes: %w", err)
+	}
+
+	key = newAPIKey(key, time.Now().UTC())
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO api_keys (id, name, scopes, hash, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		key.ID, key.Name, string(scopes), key.Hash, key.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error inserting api key: %w", err)
+	}
+	return key, nil
+}
+
+const sqliteAPIKeyColumns = `id, name, scopes, hash, created_at, rotated_at, revoked_at`
+
+func (s *SQLiteStore) APIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys WHERE hash = ?`, hash)
+	return scanAPIKeyRow(row)
+}
+
+func (s *SQLiteStore) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying api keys: %w", err)
+	}
+	return keys, nil
+}
+
+func (s *SQLiteStore) RotateAPIKey(ctx context.Context, id, hash string) (models.APIKey, error) {
+	return s.updateAPIKey(ctx, id, func(key *models.APIKey, now time.Time) error {
+		return rotateAPIKey(key, hash, now)
+	})
+}
+
+func (s *SQLiteStore) RevokeAPIKey(ctx context.Context, id string) (models.APIKey, error) {
+	return s.updateAPIKey(ctx, id, func(key *models.APIKey, now time.Time) error {
+		revokeAPIKey(key, now)
+		return nil
+	})
+}
+
+// updateAPIKey applies update to the key and writes back its hash and timestamps.
+func (s *SQLiteStore) updateAPIKey(ctx context.Context, id string, update func(key *models.APIKey, now time.Time) error) (models.APIKey, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	key, err := scanAPIKeyRow(tx.QueryRowContext(ctx, `SELECT `+sqliteAPIKeyColumns+` FROM api_keys WHERE id = ?`, id))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if err := update(&key, time.Now().UTC()); err != nil {
+		return models.APIKey{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE api_keys SET hash = ?, rotated_at = ?, revoked_at = ? WHERE id = ?`,
+		key.Hash, nullUnixNano(key.RotatedAt), nullUnixNano(key.RevokedAt), id)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error updating api key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.APIKey{}, fmt.Errorf("error committing api key: %w", err)
+	}
+	return key, nil
+}
+
+func scanAPIKeyRow(row *sql.Row) (models.APIKey, error) {
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("error reading api key: %w", err)
+	}
+	return key, nil
+}
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var (
+		key                  models.APIKey
+		scopes               string
+		createdAt            int64
+		rotatedAt, revokedAt sql.NullInt64
+	)
+	if err := row.Scan(&key.ID, &key.Name, &scopes, &key.Hash, &createdAt, &rotatedAt, &revokedAt); err != nil {
+		return models.APIKey{}, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return models.APIKey{}, fmt.Errorf("error decoding api key scopes: %w", err)
+	}
+	key.CreatedAt = time.Unix(0, createdAt).UTC()
+	key.RotatedAt = timeFromNullUnixNano(rotatedAt)
+	key.RevokedAt = timeFromNullUnixNano(revokedAt)
+	return key, nil
+}
+
+// Webhooks returns a WebhookStore backed by the database.
+func (s *SQLiteStore) Webhooks(maxDeadLetters int) WebhookStore {
+	return &sqliteWebhookStore{db: s.db, maxDeadLetters: maxDeadLetters}
+}
+
+type sqliteWebhookStore struct {
+	db             *sql.DB
+	maxDeadLetters int
+}
+
+func (w *sqliteWebhookStore) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	sub = newSubscription(sub, time.Now().UTC())
+	_, err := w.db.ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (id, url, secret, created_at)
+		VALUES (?, ?, ?, ?)`,
+		sub.ID, sub.URL, sub.Secret, sub.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("error inserting webhook: %w", err)
+	}
+	return sub, nil
+}
+
+func (w *sqliteWebhookStore) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := w.db.QueryContext(ctx, `SELECT id, url, secret, created_at FROM webhook_subscriptions ORDER BY seq`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		var (
+			sub       models.WebhookSubscription
+			createdAt int64
+		)
+		if err := rows.Scan(&sub.ID, &sub.URL, &sub.Secret, &createdAt); err != nil {
+			return nil, fmt.Errorf("error reading webhook: %w", err)
+		}
+		sub.CreatedAt = time.Unix(0, createdAt).UTC()
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying webhooks: %w", err)
+	}
+	return subs, nil
+}
+
+func (w *sqliteWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	res, err := w.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddDeadLetter inserts letter and deletes all but the most recent
+// maxDeadLetters dead letters in the same transaction.
+func (w *sqliteWebhookStore) AddDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error {
+	event, err := json.Marshal(letter.Event)
+	if err != nil {
+		return fmt.Errorf("error encoding dead letter event: %w", err)
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_dead_letters (subscription_id, url, event, attempts, last_error, failed_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		letter.SubscriptionID, letter.URL, string(event), letter.Attempts, letter.LastError, letter.FailedAt.UnixNano(),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting dead letter: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM webhook_dead_letters
+		WHERE seq <= (SELECT seq FROM webhook_dead_letters ORDER BY seq DESC LIMIT 1 OFFSET ?)`,
+		w.maxDeadLetters,
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting old dead letters: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing dead letter: %w", err)
+	}
+	return nil
+}
+
+func (w *sqliteWebhookStore) ListDeadLetters(ctx context.Context) ([]models.WebhookDeadLetter, error) {
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT subscription_id, url, event, attempts, last_error, failed_at FROM (
+			SELECT * FROM webhook_dead_letters ORDER BY seq DESC LIMIT ?
+		) ORDER BY seq`,
+		w.maxDeadLetters,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []models.WebhookDeadLetter
+	for rows.Next() {
+		var (
+			letter   models.WebhookDeadLetter
+			event    string
+			failedAt int64
+		)
+		if err := rows.Scan(&letter.SubscriptionID, &letter.URL, &event, &letter.Attempts, &letter.LastError, &failedAt); err != nil {
+			return nil, fmt.Errorf("error reading dead letter: %w", err)
+		}
+		if err := json.Unmarshal([]byte(event), &letter.Event); err != nil {
+			return nil, fmt.Errorf("error decoding dead letter event: %w", err)
+		}
+		letter.FailedAt = time.Unix(0, failedAt).UTC()
+		letters = append(letters, letter)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error querying dead letters: %w", err)
+	}
+	return letters, nil
+}
+
+func nullUnixNano(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+func timeFromNullUnixNano(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64).UTC()
+	return &t
+}
+
+// escapeLike escapes the LIKE wildcards in s, using backslash as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanReceiptRecord(row rowScanner) (models.ReceiptRecord, error) {
+	var (
+		record      models.ReceiptRecord
+		totalCents  int64
+		items       string
+		breakdown   string
+		processedAt int64
+		voidedAt    sql.NullInt64
+	)
+	err := row.Scan(
+		&record.ID,
+		&record.Receipt.Retailer,
+		&record.Receipt.PurchaseDate,
+		&record.Receipt.PurchaseTime,
+		&totalCents,
+		&items,
+		&record.Points.Total,
+		&breakdown,
+		&processedAt,
+		&record.RulesVersion,
+		&record.Fingerprint,
+		&record.Receipt.CustomerID,
+		&voidedAt,
+	)
+	if err != nil {
+		return models.ReceiptRecord{}, err
+	}
+
+	if err := json.Unmarshal([]byte(items), &record.Receipt.Items); err != nil {
+		return models.ReceiptRecord{}, fmt.Errorf("error decoding items: %w", err)
+	}
+	if err := json.Unmarshal([]byte(breakdown), &record.Points.Rules); err != nil {
+		return models.ReceiptRecord{}, fmt.Errorf("error decoding points breakdown: %w", err)
+	}
+	record.Receipt.Total = models.Money(totalCents)
+	record.ProcessedAt = time.Unix(0, processedAt).UTC()
+	if voidedAt.Valid {
+		t := time.Unix(0, voidedAt.Int64).UTC()
+		record.VoidedAt = &t
+	}
+
+	return record, nil
+}
+
+// migrateSQLite applies every embedded migration that has not been recorded in
+// schema_migrations yet. Files are named NNNN_description.sql and run in order,
+// each in its own transaction.
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			applied_at INTEGER NOT NULL
+		)`)
+	if err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+
+	files, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return fmt.Errorf("error listing migrations: %w", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := file[strings.LastIndex(file, "/")+1:]
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration file name %q: %w", name, err)
+		}
+
+		var applied bool
+		err = db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`, version).Scan(&applied)
+		if err != nil {
+			return fmt.Errorf("error checking migration %s: %w", name, err)
+		}
+		if applied {
+			continue
+		}
+
+		script, err := sqliteMigrations.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error reading migration %s: %w", name, err)
+		}
+		if err := applySQLiteMigration(ctx, db, version, string(script)); err != nil {
+			return fmt.Errorf("error applying migration %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func applySQLiteMigration(ctx context.Context, db *sql.DB, version int, script string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
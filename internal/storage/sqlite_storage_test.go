@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"ticket-processor/internal/models"
+)
+
+func TestSQLiteStore_SurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	s, err := NewSQLiteStore(ctx, path)
+	require.NoError(t, err)
+	record := testRecord()
+	record.Fingerprint = "fingerprint"
+	record.Receipt.CustomerID = "alice"
+	id, err := s.Store(ctx, record)
+	require.NoError(t, err)
+	key, err := s.CreateAPIKey(ctx, models.APIKey{Name: "pos", Scopes: []string{models.ScopeReceiptsWrite}, Hash: "hash"})
+	require.NoError(t, err)
+	sub, err := s.Webhooks(10).CreateSubscription(ctx, models.WebhookSubscription{URL: "https://a.example", Secret: "secret-a"})
+	require.NoError(t, err)
+	require.NoError(t, s.Webhooks(10).AddDeadLetter(ctx, models.WebhookDeadLetter{SubscriptionID: sub.ID, Event: models.WebhookEvent{ID: "e1"}}))
+	require.NoError(t, s.Close())
+	assert.Error(t, s.CheckHealth(ctx))
+
+	reopened, err := NewSQLiteStore(ctx, path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	got, err := reopened.Retrieve(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, 24, got.Points.Total)
+	assert.Equal(t, "alice", got.Receipt.CustomerID)
+
+	balance, err := reopened.Balance(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 24, balance)
+
+	duplicate, err := reopened.Store(ctx, record)
+	assert.ErrorIs(t, err, ErrDuplicate)
+	assert.Equal(t, id, duplicate)
+
+	gotKey, err := reopened.APIKeyByHash(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, gotKey.ID)
+	assert.Equal(t, []string{models.ScopeReceiptsWrite}, gotKey.Scopes)
+
+	subs, err := reopened.Webhooks(10).ListSubscriptions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.WebhookSubscription{sub}, subs)
+	letters, err := reopened.Webhooks(10).ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, "e1", letters[0].Event.ID)
+
+	files, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	require.NoError(t, err)
+	var applied int
+	require.NoError(t, reopened.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied))
+	assert.Equal(t, len(files), applied)
+}
+
+func TestSQLiteStore_LedgerIsImmutable(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "receipts.db"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	record := testRecord()
+	record.Receipt.CustomerID = "alice"
+	_, err = s.Store(ctx, record)
+	require.NoError(t, err)
+
+	_, err = s.db.ExecContext(ctx, `UPDATE ledger_entries SET points = 1000`)
+	assert.ErrorContains(t, err, "ledger entries are immutable")
+	_, err = s.db.ExecContext(ctx, `DELETE FROM ledger_entries`)
+	assert.ErrorContains(t, err, "ledger entries are immutable")
+
+	balance, err := s.Balance(ctx, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, 24, balance)
+}
@@ -0,0 +1,1017 @@
+// Package storagetest provides conformance tests that every storage.Storage,
+// storage.WebhookStore and storage.Cache implementation must pass. Run them
+// from the implementation's tests:
+//
+//	func TestMyStore(t *testing.T) {
+//		storagetest.RunStorageTests(t, func(t *testing.T) storage.Storage {
+//			return newMyStore(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"ticket-processor/internal/health"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+// Record returns a fully populated record suitable for storing.
+func Record() models.ReceiptRecord {
+	return models.ReceiptRecord{
+		Receipt: models.Receipt{
+			Retailer:     "M&M Corner Market",
+			PurchaseDate: "2022-03-20",
+			PurchaseTime: "14:33",
+			Items: []models.Item{
+				{ShortDescription: "Gatorade", Price: 225},
+				{ShortDescription: "Gatorade", Price: 225},
+			},
+			Total: 450,
+		},
+		Points: models.PointsBreakdown{
+			Total: 24,
+			Rules: []models.RuleAward{
+				{Rule: "retailer_name", Points: 14, Reason: "14 alphanumeric characters in retailer name"},
+				{Rule: "purchase_time", Points: 10, Reason: "purchase time 14:33 is between 14:00 and 16:00"},
+			},
+		},
+		ProcessedAt:  time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+		RulesVersion: "abc123",
+	}
+}
+
+// RunStorageTests runs the Storage conformance suite. newStore must return a
+// new, empty store for every call; cleanup belongs in t.Cleanup.
+func RunStorageTests(t *testing.T, newStore func(t *testing.T) storage.Storage) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := Record()
+		record.Fingerprint = "fingerprint"
+		id, err := s.Store(ctx, record)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+
+		got, err := s.Retrieve(ctx, id)
+		require.NoError(t, err)
+		record.ID = id
+		assert.Equal(t, record, got)
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		s := newStore(t)
+
+		_, err := s.Retrieve(context.Background(), "missing")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("UniqueIDs", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		first, err := s.Store(ctx, Record())
+		require.NoError(t, err)
+		second, err := s.Store(ctx, Record())
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("PresetID", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := Record()
+		record.ID = "preset-id"
+		id, err := s.Store(ctx, record)
+		require.NoError(t, err)
+		assert.Equal(t, "preset-id", id)
+
+		got, err := s.Retrieve(ctx, "preset-id")
+		require.NoError(t, err)
+		assert.Equal(t, record, got)
+	})
+
+	t.Run("DuplicateFingerprint", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := Record()
+		record.Fingerprint = "same"
+		original, err := s.Store(ctx, record)
+		require.NoError(t, err)
+
+		other := Record()
+		other.Fingerprint = "other"
+		otherID, err := s.Store(ctx, other)
+		require.NoError(t, err)
+		assert.NotEqual(t, original, otherID)
+
+		record.Points.Total = 99
+		id, err := s.Store(ctx, record)
+		assert.ErrorIs(t, err, storage.ErrDuplicate)
+		assert.Equal(t, original, id)
+
+		got, err := s.Retrieve(ctx, original)
+		require.NoError(t, err)
+		assert.Equal(t, 24, got.Points.Total, "duplicate must not overwrite the original")
+	})
+
+	t.Run("DuplicateAlias", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := Record()
+		record.Fingerprint = "same"
+		original, err := s.Store(ctx, record)
+		require.NoError(t, err)
+
+		record.ID = "duplicate-id"
+		id, err := s.Store(ctx, record)
+		require.ErrorIs(t, err, storage.ErrDuplicate)
+		assert.Equal(t, original, id)
+
+		got, err := s.Retrieve(ctx, "duplicate-id")
+		require.NoError(t, err)
+		assert.Equal(t, original, got.ID, "the ID the duplicate was submitted under resolves to the original")
+
+		voided, _, err := s.Void(ctx, "duplicate-id")
+		require.NoError(t, err)
+		assert.Equal(t, original, voided.ID)
+		got, err = s.Retrieve(ctx, original)
+		require.NoError(t, err)
+		assert.NotNil(t, got.VoidedAt)
+	})
+
+	t.Run("ConcurrentDuplicates", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		const writers = 8
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			ids     = make(map[string]bool)
+			created int
+		)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				record := Record()
+				record.Fingerprint = "contended"
+				id, err := s.Store(ctx, record)
+				if err != nil && !assert.ErrorIs(t, err, storage.ErrDuplicate) {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				ids[id] = true
+				if err == nil {
+					created++
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, created, "exactly one write must win")
+		assert.Len(t, ids, 1, "every writer must see the same ID")
+	})
+
+	t.Run("StoredRecordIsIsolated", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := Record()
+		id, err := s.Store(ctx, record)
+		require.NoError(t, err)
+		record.Receipt.Items[0].ShortDescription = "changed"
+
+		got, err := s.Retrieve(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Gatorade", got.Receipt.Items[0].ShortDescription)
+
+		got.Points.Rules[0].Points = 1000
+		again, err := s.Retrieve(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 14, again.Points.Rules[0].Points)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		s := newStore(t)
+		id, err := s.Store(context.Background(), Record())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = s.Store(ctx, Record())
+		assert.ErrorIs(t, err, context.Canceled)
+		_, err = s.Retrieve(ctx, id)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("ConcurrentAccess", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		const writers, perWriter = 8, 20
+		ids := make(chan string, writers*perWriter)
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					id, err := s.Store(ctx, Record())
+					if !assert.NoError(t, err) {
+						return
+					}
+					ids <- id
+					_, err = s.Retrieve(ctx, id)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := make(map[string]struct{})
+		for id := range ids {
+			seen[id] = struct{}{}
+			_, err := s.Retrieve(ctx, id)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, seen, writers*perWriter)
+	})
+
+	t.Run("Healthy", func(t *testing.T) {
+		hc, ok := newStore(t).(health.HealthChecker)
+		require.True(t, ok, "stores must report their health to the readiness probe")
+		assert.NoError(t, hc.CheckHealth(context.Background()))
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		runQueryTests(t, newStore)
+	})
+
+	t.Run("Ledger", func(t *testing.T) {
+		runLedgerTests(t, newStore)
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		runAPIKeyTests(t, func(t *testing.T) storage.APIKeyStore {
+			s, ok := newStore(t).(storage.APIKeyStore)
+			require.True(t, ok, "stores must also keep API keys")
+			return s
+		})
+	})
+}
+
+// CustomerRecord returns Record credited to customerID with the given points and a unique fingerprint.
+func CustomerRecord(customerID string, points int) models.ReceiptRecord {
+	record := Record()
+	record.Receipt.CustomerID = customerID
+	record.Points.Total = points
+	record.Fingerprint = fmt.Sprintf("%s-%d-%d", customerID, points, time.Now().UnixNano())
+	return record
+}
+
+func runLedgerTests(t *testing.T, newStore func(t *testing.T) storage.Storage) {
+	t.Run("Credit", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		first, err := s.Store(ctx, CustomerRecord("alice", 24))
+		require.NoError(t, err)
+		second, err := s.Store(ctx, CustomerRecord("alice", 10))
+		require.NoError(t, err)
+		_, err = s.Store(ctx, CustomerRecord("bob", 5))
+		require.NoError(t, err)
+		_, err = s.Store(ctx, Record())
+		require.NoError(t, err)
+
+		got, err := s.Retrieve(ctx, first)
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Receipt.CustomerID)
+
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 34, balance)
+
+		page, err := s.Ledger(ctx, "alice", storage.LedgerQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 2)
+		assert.Empty(t, page.NextCursor)
+		for i, want := range []struct {
+			receiptID       string
+			points, balance int
+		}{{first, 24, 24}, {second, 10, 34}} {
+			entry := page.Entries[i]
+			assert.NotEmpty(t, entry.ID)
+			assert.Equal(t, "alice", entry.CustomerID)
+			assert.Equal(t, models.LedgerEntryCredit, entry.Type)
+			assert.Equal(t, want.receiptID, entry.ReceiptID)
+			assert.Equal(t, want.points, entry.Points)
+			assert.Equal(t, want.balance, entry.Balance)
+			assert.Equal(t, Record().ProcessedAt, entry.CreatedAt)
+		}
+
+		balance, err = s.Balance(ctx, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, 5, balance)
+	})
+
+	t.Run("UnknownCustomer", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		balance, err := s.Balance(ctx, "nobody")
+		require.NoError(t, err)
+		assert.Zero(t, balance)
+
+		page, err := s.Ledger(ctx, "nobody", storage.LedgerQuery{})
+		require.NoError(t, err)
+		assert.Empty(t, page.Entries)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("DuplicateIsNotCredited", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		record := CustomerRecord("alice", 24)
+		_, err := s.Store(ctx, record)
+		require.NoError(t, err)
+		_, err = s.Store(ctx, record)
+		require.ErrorIs(t, err, storage.ErrDuplicate)
+
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 24, balance)
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		for i := 1; i <= 5; i++ {
+			_, err := s.Store(ctx, CustomerRecord("alice", i))
+			require.NoError(t, err)
+			_, err = s.Store(ctx, CustomerRecord("bob", i))
+			require.NoError(t, err)
+		}
+
+		var points []int
+		q := storage.LedgerQuery{Limit: 2}
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 5, "pagination does not terminate")
+			page, err := s.Ledger(ctx, "alice", q)
+			require.NoError(t, err)
+			for _, entry := range page.Entries {
+				points = append(points, entry.Points)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []int{1, 2, 3, 4, 5}, points)
+
+		_, err := s.Ledger(ctx, "bob", q)
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor, "cursors are bound to the customer")
+		_, err = s.Ledger(ctx, "alice", storage.LedgerQuery{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+	})
+
+	t.Run("ConcurrentCredits", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		const writers, perWriter = 8, 10
+		var wg sync.WaitGroup
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					record := CustomerRecord("alice", 1)
+					record.Fingerprint = fmt.Sprintf("writer-%d-%d", w, i)
+					_, err := s.Store(ctx, record)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, writers*perWriter, balance)
+
+		page, err := s.Ledger(ctx, "alice", storage.LedgerQuery{Limit: storage.MaxPageSize})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, writers*perWriter)
+		for i, entry := range page.Entries {
+			assert.Equal(t, i+1, entry.Balance, "running balances must not interleave")
+		}
+	})
+
+	t.Run("Redeem", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		_, err := s.Store(ctx, CustomerRecord("alice", 30))
+		require.NoError(t, err)
+
+		entry, err := s.Redeem(ctx, "alice", 20, "coffee")
+		require.NoError(t, err)
+		assert.NotEmpty(t, entry.ID)
+		assert.Equal(t, "alice", entry.CustomerID)
+		assert.Equal(t, models.LedgerEntryRedemption, entry.Type)
+		assert.Empty(t, entry.ReceiptID)
+		assert.Equal(t, -20, entry.Points)
+		assert.Equal(t, 10, entry.Balance)
+		assert.Equal(t, "coffee", entry.Description)
+		assert.False(t, entry.CreatedAt.IsZero())
+
+		_, err = s.Redeem(ctx, "alice", 11, "")
+		assert.ErrorIs(t, err, storage.ErrInsufficientBalance)
+		_, err = s.Redeem(ctx, "nobody", 1, "")
+		assert.ErrorIs(t, err, storage.ErrInsufficientBalance)
+
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 10, balance, "a rejected redemption must not change the balance")
+
+		page, err := s.Ledger(ctx, "alice", storage.LedgerQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 2)
+		assert.Equal(t, entry, page.Entries[1])
+	})
+
+	t.Run("ConcurrentRedemptions", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		_, err := s.Store(ctx, CustomerRecord("alice", 5))
+		require.NoError(t, err)
+
+		const redeemers = 8
+		var (
+			wg       sync.WaitGroup
+			mu       sync.Mutex
+			redeemed int
+		)
+		for r := 0; r < redeemers; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.Redeem(ctx, "alice", 1, "")
+				if err != nil && !assert.ErrorIs(t, err, storage.ErrInsufficientBalance) {
+					return
+				}
+				if err == nil {
+					mu.Lock()
+					redeemed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 5, redeemed, "redemptions must never overdraw the balance")
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Zero(t, balance)
+	})
+
+	t.Run("Void", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		id, err := s.Store(ctx, CustomerRecord("alice", 24))
+		require.NoError(t, err)
+		_, err = s.Redeem(ctx, "alice", 20, "")
+		require.NoError(t, err)
+
+		record, entry, err := s.Void(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, record.VoidedAt)
+		require.NotNil(t, entry)
+		assert.Equal(t, models.LedgerEntryReversal, entry.Type)
+		assert.Equal(t, id, entry.ReceiptID)
+		assert.Equal(t, -24, entry.Points)
+		assert.Equal(t, -20, entry.Balance, "reversing redeemed points leaves a negative balance")
+		assert.Equal(t, *record.VoidedAt, entry.CreatedAt)
+
+		got, err := s.Retrieve(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, record, got)
+
+		_, _, err = s.Void(ctx, id)
+		assert.ErrorIs(t, err, storage.ErrAlreadyVoided)
+		_, _, err = s.Void(ctx, "missing")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		page, err := s.Ledger(ctx, "alice", storage.LedgerQuery{})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 3)
+		sum := 0
+		for _, e := range page.Entries {
+			sum += e.Points
+		}
+		balance, err := s.Balance(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, sum, balance, "the balance must be derivable from the ledger")
+	})
+
+	t.Run("VoidWithoutCustomer", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		id, err := s.Store(ctx, Record())
+		require.NoError(t, err)
+
+		record, entry, err := s.Void(ctx, id)
+		require.NoError(t, err)
+		assert.NotNil(t, record.VoidedAt)
+		assert.Nil(t, entry)
+	})
+}
+
+func runQueryTests(t *testing.T, newStore func(t *testing.T) storage.Storage) {
+	base := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	fixtures := []struct {
+		retailer string
+		date     string
+		points   int
+	}{
+		{"Target", "2022-01-01", 10},
+		{"Walgreens", "2022-01-02", 20},
+		{"Target Express", "2022-02-01", 20},
+		{"M&M Corner Market", "2022-03-20", 109},
+		{"100%_Store", "2022-03-21", 5},
+	}
+
+	s := newStore(t)
+	ctx := context.Background()
+	for i, f := range fixtures {
+		record := Record()
+		record.Receipt.Retailer = f.retailer
+		record.Receipt.PurchaseDate = f.date
+		record.Points.Total = f.points
+		record.ProcessedAt = base.Add(time.Duration(i+1) * time.Hour)
+		_, err := s.Store(ctx, record)
+		require.NoError(t, err)
+	}
+
+	intPtr := func(v int) *int { return &v }
+
+	t.Run("Filters", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query storage.ReceiptQuery
+			want  []string
+		}{
+			{"no filters", storage.ReceiptQuery{}, []string{"Target", "Walgreens", "Target Express", "M&M Corner Market", "100%_Store"}},
+			{"retailer ignores case", storage.ReceiptQuery{Retailer: "target"}, []string{"Target", "Target Express"}},
+			{"retailer wildcards are literal", storage.ReceiptQuery{Retailer: "%_"}, []string{"100%_Store"}},
+			{"purchase date range", storage.ReceiptQuery{PurchaseDateFrom: "2022-01-02", PurchaseDateTo: "2022-03-20"}, []string{"Walgreens", "Target Express", "M&M Corner Market"}},
+			{"min points", storage.ReceiptQuery{MinPoints: intPtr(20)}, []string{"Walgreens", "Target Express", "M&M Corner Market"}},
+			{"max points", storage.ReceiptQuery{MaxPoints: intPtr(10)}, []string{"Target", "100%_Store"}},
+			{"points range", storage.ReceiptQuery{MinPoints: intPtr(20), MaxPoints: intPtr(20)}, []string{"Walgreens", "Target Express"}},
+			{"processed range", storage.ReceiptQuery{ProcessedAfter: base.Add(2 * time.Hour), ProcessedBefore: base.Add(4 * time.Hour)}, []string{"Walgreens", "Target Express"}},
+			{"combined", storage.ReceiptQuery{Retailer: "target", MinPoints: intPtr(15)}, []string{"Target Express"}},
+			{"no match", storage.ReceiptQuery{Retailer: "costco"}, nil},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				page, err := s.Query(ctx, tt.query)
+				require.NoError(t, err)
+				assert.Empty(t, page.NextCursor)
+
+				var got []string
+				for _, record := range page.Records {
+					got = append(got, record.Receipt.Retailer)
+				}
+				assert.ElementsMatch(t, tt.want, got)
+			})
+		}
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		sortValue := map[storage.SortField]func(r models.ReceiptRecord) string{
+			storage.SortByProcessedAt:  func(r models.ReceiptRecord) string { return r.ProcessedAt.Format(time.RFC3339Nano) },
+			storage.SortByPurchaseDate: func(r models.ReceiptRecord) string { return r.Receipt.PurchaseDate },
+			storage.SortByPoints:       func(r models.ReceiptRecord) string { return fmt.Sprintf("%05d", r.Points.Total) },
+			storage.SortByRetailer:     func(r models.ReceiptRecord) string { return r.Receipt.Retailer },
+		}
+
+		for field, value := range sortValue {
+			for _, descending := range []bool{false, true} {
+				t.Run(fmt.Sprintf("%s descending=%v", field, descending), func(t *testing.T) {
+					q := storage.ReceiptQuery{SortBy: field, Descending: descending, Limit: 2}
+
+					var (
+						records []models.ReceiptRecord
+						pages   int
+					)
+					for {
+						page, err := s.Query(ctx, q)
+						require.NoError(t, err)
+						require.LessOrEqual(t, len(page.Records), 2)
+						records = append(records, page.Records...)
+						pages++
+						if page.NextCursor == "" {
+							break
+						}
+						require.Less(t, pages, 10, "pagination does not terminate")
+						q.Cursor = page.NextCursor
+					}
+
+					assert.Equal(t, 3, pages)
+					require.Len(t, records, len(fixtures))
+					seen := make(map[string]bool)
+					var values []string
+					for _, record := range records {
+						assert.False(t, seen[record.ID], "record %s returned twice", record.ID)
+						seen[record.ID] = true
+						values = append(values, value(record))
+					}
+					if descending {
+						assert.IsNonIncreasing(t, values)
+					} else {
+						assert.IsNonDecreasing(t, values)
+					}
+				})
+			}
+		}
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		page, err := s.Query(ctx, storage.ReceiptQuery{SortBy: storage.SortByPoints, Limit: 1})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.NextCursor)
+
+		tests := []struct {
+			name  string
+			query storage.ReceiptQuery
+		}{
+			{"garbage", storage.ReceiptQuery{Cursor: "not a cursor"}},
+			{"different sort field", storage.ReceiptQuery{SortBy: storage.SortByRetailer, Cursor: page.NextCursor}},
+			{"different direction", storage.ReceiptQuery{SortBy: storage.SortByPoints, Descending: true, Cursor: page.NextCursor}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := s.Query(ctx, tt.query)
+				assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+			})
+		}
+	})
+}
+
+// APIKey returns an unsaved API key whose secret hashes to hash.
+func APIKey(name, hash string) models.APIKey {
+	return models.APIKey{Name: name, Scopes: []string{models.ScopeReceiptsRead, models.ScopeReceiptsWrite}, Hash: hash}
+}
+
+func runAPIKeyTests(t *testing.T, newStore func(t *testing.T) storage.APIKeyStore) {
+	t.Run("CreateAndLookUp", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		created, err := s.CreateAPIKey(ctx, APIKey("pos", "hash-1"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+		assert.False(t, created.CreatedAt.IsZero())
+		assert.Nil(t, created.RotatedAt)
+		assert.Nil(t, created.RevokedAt)
+
+		got, err := s.APIKeyByHash(ctx, "hash-1")
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, got.ID)
+		assert.Equal(t, "pos", got.Name)
+		assert.Equal(t, []string{models.ScopeReceiptsRead, models.ScopeReceiptsWrite}, got.Scopes)
+		assert.True(t, created.CreatedAt.Equal(got.CreatedAt))
+
+		_, err = s.APIKeyByHash(ctx, "unknown")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	})
+
+	t.Run("ListOldestFirst", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+
+		keys, err := s.ListAPIKeys(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+
+		var ids []string
+		for i := 0; i < 3; i++ {
+			key, err := s.CreateAPIKey(ctx, APIKey(fmt.Sprintf("key-%d", i), fmt.Sprintf("hash-%d", i)))
+			require.NoError(t, err)
+			ids = append(ids, key.ID)
+		}
+		_, err = s.RevokeAPIKey(ctx, ids[0])
+		require.NoError(t, err)
+
+		keys, err = s.ListAPIKeys(ctx)
+		require.NoError(t, err)
+		require.Len(t, keys, 3, "revoked keys are still listed")
+		for i, key := range keys {
+			assert.Equal(t, ids[i], key.ID)
+		}
+	})
+
+	t.Run("Rotate", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		created, err := s.CreateAPIKey(ctx, APIKey("pos", "old-hash"))
+		require.NoError(t, err)
+
+		rotated, err := s.RotateAPIKey(ctx, created.ID, "new-hash")
+		require.NoError(t, err)
+		assert.Equal(t, "new-hash", rotated.Hash)
+		require.NotNil(t, rotated.RotatedAt)
+
+		_, err = s.APIKeyByHash(ctx, "old-hash")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound, "the old secret stops working")
+		got, err := s.APIKeyByHash(ctx, "new-hash")
+		require.NoError(t, err)
+		assert.Equal(t, created.ID, got.ID)
+		require.NotNil(t, got.RotatedAt)
+		assert.True(t, rotated.RotatedAt.Equal(*got.RotatedAt))
+
+		_, err = s.RotateAPIKey(ctx, "unknown", "other-hash")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		created, err := s.CreateAPIKey(ctx, APIKey("pos", "hash"))
+		require.NoError(t, err)
+
+		revoked, err := s.RevokeAPIKey(ctx, created.ID)
+		require.NoError(t, err)
+		require.NotNil(t, revoked.RevokedAt)
+
+		again, err := s.RevokeAPIKey(ctx, created.ID)
+		require.NoError(t, err)
+		require.NotNil(t, again.RevokedAt)
+		assert.True(t, revoked.RevokedAt.Equal(*again.RevokedAt), "revoking again keeps the first time")
+
+		got, err := s.APIKeyByHash(ctx, "hash")
+		require.NoError(t, err)
+		assert.NotNil(t, got.RevokedAt, "revoked keys are found so callers can tell why they fail")
+
+		_, err = s.RotateAPIKey(ctx, created.ID, "new-hash")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyRevoked)
+		_, err = s.RevokeAPIKey(ctx, "unknown")
+		assert.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+	})
+
+	t.Run("StoredKeyIsIsolated", func(t *testing.T) {
+		s := newStore(t)
+		ctx := context.Background()
+		created, err := s.CreateAPIKey(ctx, APIKey("pos", "hash"))
+		require.NoError(t, err)
+
+		created.Scopes[0] = models.ScopeAdmin
+		got, err := s.APIKeyByHash(ctx, "hash")
+		require.NoError(t, err)
+		assert.Equal(t, models.ScopeReceiptsRead, got.Scopes[0])
+	})
+}
+
+// DeadLetter returns a fully populated dead letter for the event with ID eventID.
+func DeadLetter(eventID string) models.WebhookDeadLetter {
+	return models.WebhookDeadLetter{
+		SubscriptionID: "subscription",
+		URL:            "https://hooks.example.com",
+		Event: models.WebhookEvent{
+			ID:        eventID,
+			Type:      "receipt.processed",
+			CreatedAt: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+			Data:      []byte(`{"receiptId":"123"}`),
+		},
+		Attempts:  5,
+		LastError: "unexpected status 500 Internal Server Error",
+		FailedAt:  time.Date(2024, 5, 1, 12, 45, 0, 0, time.UTC),
+	}
+}
+
+// RunWebhookStoreTests runs the WebhookStore conformance suite. newStore must
+// return a new, empty store that keeps maxDeadLetters dead letters for every
+// call; cleanup belongs in t.Cleanup.
+func RunWebhookStoreTests(t *testing.T, newStore func(t *testing.T, maxDeadLetters int) storage.WebhookStore) {
+	t.Run("Subscriptions", func(t *testing.T) {
+		s := newStore(t, 10)
+		ctx := context.Background()
+
+		subs, err := s.ListSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+
+		first, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://a.example", Secret: "secret-a"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, first.ID)
+		assert.False(t, first.CreatedAt.IsZero())
+		second, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://b.example", Secret: "secret-b"})
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+
+		subs, err = s.ListSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.WebhookSubscription{first, second}, subs, "subscriptions are listed oldest first, secrets included")
+
+		require.NoError(t, s.DeleteSubscription(ctx, first.ID))
+		assert.ErrorIs(t, s.DeleteSubscription(ctx, first.ID), storage.ErrNotFound)
+
+		subs, err = s.ListSubscriptions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.WebhookSubscription{second}, subs)
+	})
+
+	t.Run("DeadLetterRoundTrip", func(t *testing.T) {
+		s := newStore(t, 10)
+		ctx := context.Background()
+
+		letters, err := s.ListDeadLetters(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, letters)
+
+		require.NoError(t, s.AddDeadLetter(ctx, DeadLetter("e1")))
+		letters, err = s.ListDeadLetters(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []models.WebhookDeadLetter{DeadLetter("e1")}, letters)
+	})
+
+	t.Run("DeadLettersAreBounded", func(t *testing.T) {
+		s := newStore(t, 2)
+		ctx := context.Background()
+
+		for _, id := range []string{"e1", "e2", "e3"} {
+			require.NoError(t, s.AddDeadLetter(ctx, DeadLetter(id)))
+		}
+
+		letters, err := s.ListDeadLetters(ctx)
+		require.NoError(t, err)
+		require.Len(t, letters, 2)
+		assert.Equal(t, "e2", letters[0].Event.ID)
+		assert.Equal(t, "e3", letters[1].Event.ID)
+	})
+}
+
+// CacheFixture is a cache under test.
+type CacheFixture struct {
+	Cache storage.Cache
+	// Advance moves the cache's clock forward. Leave nil for caches that
+	// follow the wall clock; the suite then sleeps instead.
+	Advance func(d time.Duration)
+}
+
+func (f CacheFixture) wait(d time.Duration) {
+	if f.Advance != nil {
+		f.Advance(d)
+		return
+	}
+	time.Sleep(d)
+}
+
+// RunCacheTests runs the Cache conformance suite. newCache must return a new,
+// empty cache for every call; cleanup belongs in t.Cleanup.
+func RunCacheTests(t *testing.T, newCache func(t *testing.T) CacheFixture) {
+	const ttl = 50 * time.Millisecond
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		c := newCache(t).Cache
+		ctx := context.Background()
+
+		require.NoError(t, c.Set(ctx, "key", 42, time.Minute))
+		value, ok := c.Load(ctx, "key")
+		assert.True(t, ok)
+		assert.Equal(t, 42, value)
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		c := newCache(t).Cache
+
+		value, ok := c.Load(context.Background(), "missing")
+		assert.False(t, ok)
+		assert.Zero(t, value)
+	})
+
+	t.Run("Healthy", func(t *testing.T) {
+		hc, ok := newCache(t).Cache.(health.HealthChecker)
+		require.True(t, ok, "caches must report their health to the readiness probe")
+		assert.NoError(t, hc.CheckHealth(context.Background()))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		c := newCache(t).Cache
+		ctx := context.Background()
+
+		require.NoError(t, c.Set(ctx, "key", 1, time.Minute))
+		require.NoError(t, c.Set(ctx, "key", 2, time.Minute))
+		value, ok := c.Load(ctx, "key")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c := newCache(t).Cache
+		ctx := context.Background()
+
+		require.NoError(t, c.Set(ctx, "key", 1, time.Minute))
+		require.NoError(t, c.Delete(ctx, "key"))
+		_, ok := c.Load(ctx, "key")
+		assert.False(t, ok)
+		assert.NoError(t, c.Delete(ctx, "missing"), "deleting a missing key is not an error")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		f := newCache(t)
+		ctx := context.Background()
+
+		require.NoError(t, f.Cache.Set(ctx, "short", 1, ttl))
+		require.NoError(t, f.Cache.Set(ctx, "long", 2, time.Hour))
+		require.NoError(t, f.Cache.Set(ctx, "forever", 3, 0))
+
+		_, ok := f.Cache.Load(ctx, "short")
+		assert.True(t, ok, "entry must be readable before it expires")
+
+		f.wait(2 * ttl)
+
+		_, ok = f.Cache.Load(ctx, "short")
+		assert.False(t, ok, "entry must not be readable after it expires")
+		value, ok := f.Cache.Load(ctx, "long")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+		value, ok = f.Cache.Load(ctx, "forever")
+		assert.True(t, ok, "zero expiration means the entry never expires")
+		assert.Equal(t, 3, value)
+	})
+
+	t.Run("OverwriteResetsExpiry", func(t *testing.T) {
+		f := newCache(t)
+		ctx := context.Background()
+
+		require.NoError(t, f.Cache.Set(ctx, "key", 1, ttl))
+		require.NoError(t, f.Cache.Set(ctx, "key", 2, time.Hour))
+
+		f.wait(2 * ttl)
+
+		value, ok := f.Cache.Load(ctx, "key")
+		assert.True(t, ok)
+		assert.Equal(t, 2, value)
+	})
+
+	t.Run("CancelledContext", func(t *testing.T) {
+		c := newCache(t).Cache
+		require.NoError(t, c.Set(context.Background(), "key", 1, time.Minute))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := c.Set(ctx, "other", 2, time.Minute)
+		assert.True(t, errors.Is(err, context.Canceled), "Set must fail with the context error, got %v", err)
+		_, ok := c.Load(ctx, "key")
+		assert.False(t, ok, "Load must report a miss for a cancelled context")
+	})
+
+	t.Run("ConcurrentAccess", func(t *testing.T) {
+		c := newCache(t).Cache
+		ctx := context.Background()
+
+		const workers, perWorker = 8, 50
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					key := fmt.Sprintf("key-%d", i%10)
+					assert.NoError(t, c.Set(ctx, key, w*perWorker+i, time.Minute))
+					_, ok := c.Load(ctx, key)
+					assert.True(t, ok)
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		for i := 0; i < 10; i++ {
+			_, ok := c.Load(ctx, fmt.Sprintf("key-%d", i))
+			assert.True(t, ok)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+	"ticket-processor/internal/models"
+	"time"
+)
+
+type WebhookStore interface {
+	// CreateSubscription stores sub under a new ID and returns it with the ID and CreatedAt set.
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	// ListSubscriptions returns all subscriptions, secrets included, oldest first.
+	ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	// DeleteSubscription returns ErrNotFound when no subscription exists for id.
+	DeleteSubscription(ctx context.Context, id string) error
+	AddDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error
+	// ListDeadLetters returns the retained dead letters, oldest first.
+	ListDeadLetters(ctx context.Context) ([]models.WebhookDeadLetter, error)
+}
+
+// WebhookStorage is implemented by the durable storages, which keep webhook
+// subscriptions and dead letters along with receipts.
+type WebhookStorage interface {
+	// Webhooks returns a WebhookStore backed by the storage that keeps the most
+	// recent maxDeadLetters dead letters.
+	Webhooks(maxDeadLetters int) WebhookStore
+}
+
+type inMemoryWebhookStore struct {
+	mu             sync.RWMutex
+	subscriptions  []models.WebhookSubscription
+	deadLetters    []models.WebhookDeadLetter
+	maxDeadLetters int
+}
+
+// NewInMemoryWebhookStore keeps the most recent maxDeadLetters dead letters.
+func NewInMemoryWebhookStore(maxDeadLetters int) WebhookStore {
+	return &inMemoryWebhookStore{maxDeadLetters: maxDeadLetters}
+}
+
+func (s *inMemoryWebhookStore) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+
+	sub = newSubscription(sub, time.Now().UTC())
+	s.putSubscription(sub)
+	return sub, nil
+}
+
+func (s *inMemoryWebhookStore) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.subscriptionList(), nil
+}
+
+func (s *inMemoryWebhookStore) DeleteSubscription(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !s.removeSubscription(id) {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *inMemoryWebhookStore) AddDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.appendDeadLetter(letter)
+	s.trimDeadLetters(s.maxDeadLetters)
+	return nil
+}
+
+func (s *inMemoryWebhookStore) ListDeadLetters(ctx context.Context) ([]models.WebhookDeadLetter, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.lastDeadLetters(s.maxDeadLetters), nil
+}
+
+func (s *inMemoryWebhookStore) putSubscription(sub models.WebhookSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscriptions = append(s.subscriptions, sub)
+}
+
+func (s *inMemoryWebhookStore) subscriptionList() []models.WebhookSubscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.subscriptions)
+}
+
+func (s *inMemoryWebhookStore) hasSubscription(id string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.ContainsFunc(s.subscriptions, func(sub models.WebhookSubscription) bool { return sub.ID == id })
+}
+
+// removeSubscription reports whether a subscription with id existed.
+func (s *inMemoryWebhookStore) removeSubscription(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.subscriptions, func(sub models.WebhookSubscription) bool { return sub.ID == id })
+	if i < 0 {
+		return false
+	}
+	s.subscriptions = slices.Delete(s.subscriptions, i, i+1)
+	return true
+}
+
+func (s *inMemoryWebhookStore) appendDeadLetter(letter models.WebhookDeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deadLetters = append(s.deadLetters, letter)
+}
+
+// trimDeadLetters drops all but the most recent limit dead letters.
+func (s *inMemoryWebhookStore) trimDeadLetters(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if excess := len(s.deadLetters) - limit; excess > 0 {
+		s.deadLetters = slices.Delete(s.deadLetters, 0, excess)
+	}
+}
+
+// lastDeadLetters returns up to limit of the most recent dead letters, oldest first.
+func (s *inMemoryWebhookStore) lastDeadLetters(limit int) []models.WebhookDeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.deadLetters[max(len(s.deadLetters)-limit, 0):])
+}
+
+// newSubscription returns sub with a new ID and CreatedAt set to now.
+func newSubscription(sub models.WebhookSubscription, now time.Time) models.WebhookSubscription {
+	sub.ID = uuid.New().String()
+	sub.CreatedAt = now
+	return sub
+}
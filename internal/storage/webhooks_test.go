@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"ticket-processor/internal/models"
+)
+
+func TestInMemoryWebhookStore_Subscriptions(t *testing.T) {
+	s := NewInMemoryWebhookStore(10)
+	ctx := context.Background()
+
+	first, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://a.example", Secret: "secret-a"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, first.ID)
+	assert.False(t, first.CreatedAt.IsZero())
+	second, err := s.CreateSubscription(ctx, models.WebhookSubscription{URL: "https://b.example", Secret: "secret-b"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+
+	subs, err := s.ListSubscriptions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.WebhookSubscription{first, second}, subs)
+
+	require.NoError(t, s.DeleteSubscription(ctx, first.ID))
+	assert.ErrorIs(t, s.DeleteSubscription(ctx, first.ID), ErrNotFound)
+
+	subs, err = s.ListSubscriptions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []models.WebhookSubscription{second}, subs)
+}
+
+func TestInMemoryWebhookStore_DeadLettersAreBounded(t *testing.T) {
+	s := NewInMemoryWebhookStore(2)
+	ctx := context.Background()
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		require.NoError(t, s.AddDeadLetter(ctx, models.WebhookDeadLetter{Event: models.WebhookEvent{ID: id}}))
+	}
+
+	letters, err := s.ListDeadLetters(ctx)
+	require.NoError(t, err)
+	require.Len(t, letters, 2)
+	assert.Equal(t, "e2", letters[0].Event.ID)
+	assert.Equal(t, "e3", letters[1].Event.ID)
+}
+
+func TestInMemoryWebhookStore_CancelledContext(t *testing.T) {
+	s := NewInMemoryWebhookStore(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.CreateSubscription(ctx, models.WebhookSubscription{})
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.ListSubscriptions(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.ErrorIs(t, s.DeleteSubscription(ctx, "id"), context.Canceled)
+	assert.ErrorIs(t, s.AddDeadLetter(ctx, models.WebhookDeadLetter{}), context.Canceled)
+	_, err = s.ListDeadLetters(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+type tracedStorage struct {
+	next   storage.Storage
+	driver attribute.KeyValue
+}
+
+// WrapStorage returns a Storage that records a span around every call to s.
+func WrapStorage(s storage.Storage, driver string) storage.Storage {
+	return &tracedStorage{next: s, driver: attribute.String("storage.driver", driver)}
+}
+
+func (s *tracedStorage) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer().Start(ctx, "Storage."+name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(append(attrs, s.driver)...),
+	)
+}
+
+func (s *tracedStorage) Store(ctx context.Context, record models.ReceiptRecord) (id string, err error) {
+	ctx, span := s.start(ctx, "Store")
+	defer func() {
+		span.SetAttributes(attribute.String("receipt.id", id))
+		End(span, err, storage.ErrDuplicate)
+	}()
+	return s.next.Store(ctx, record)
+}
+
+func (s *tracedStorage) Retrieve(ctx context.Context, id string) (record models.ReceiptRecord, err error) {
+	ctx, span := s.start(ctx, "Retrieve", attribute.String("receipt.id", id))
+	defer func() { End(span, err, storage.ErrNotFound) }()
+	return s.next.Retrieve(ctx, id)
+}
+
+func (s *tracedStorage) Query(ctx context.Context, q storage.ReceiptQuery) (page storage.ReceiptPage, err error) {
+	ctx, span := s.start(ctx, "Query")
+	defer func() { End(span, err, storage.ErrInvalidCursor) }()
+	return s.next.Query(ctx, q)
+}
+
+func (s *tracedStorage) Balance(ctx context.Context, customerID string) (balance int, err error) {
+	ctx, span := s.start(ctx, "Balance")
+	defer func() { End(span, err) }()
+	return s.next.Balance(ctx, customerID)
+}
+
+func (s *tracedStorage) Ledger(ctx context.Context, customerID string, q storage.LedgerQuery) (page storage.LedgerPage, err error) {
+	ctx, span := s.start(ctx, "Ledger")
+	defer func() { End(span, err, storage.ErrInvalidCursor) }()
+	return s.next.Ledger(ctx, customerID, q)
+}
+
+func (s *tracedStorage) Redeem(ctx context.Context, customerID string, points int, description string) (entry models.LedgerEntry, err error) {
+	ctx, span := s.start(ctx, "Redeem")
+	defer func() { End(span, err, storage.ErrInsufficientBalance) }()
+	return s.next.Redeem(ctx, customerID, points, description)
+}
+
+func (s *tracedStorage) Void(ctx context.Context, id string) (record models.ReceiptRecord, entry *models.LedgerEntry, err error) {
+	ctx, span := s.start(ctx, "Void", attribute.String("receipt.id", id))
+	defer func() { End(span, err, storage.ErrNotFound, storage.ErrAlreadyVoided) }()
+	return s.next.Void(ctx, id)
+}
+
+type tracedCache struct {
+	next   storage.Cache
+	driver attribute.KeyValue
+}
+
+// WrapCache returns a Cache that records a span around every call to c.
+func WrapCache(c storage.Cache, driver string) storage.Cache {
+	return &tracedCache{next: c, driver: attribute.String("cache.driver", driver)}
+}
+
+func (c *tracedCache) Load(ctx context.Context, key string) (int, bool) {
+	ctx, span := tracer().Start(ctx, "Cache.Load", trace.WithAttributes(c.driver, attribute.String("receipt.id", key)))
+	defer span.End()
+	value, ok := c.next.Load(ctx, key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok))
+	return value, ok
+}
+
+func (c *tracedCache) Set(ctx context.Context, key string, value int, expiration time.Duration) (err error) {
+	ctx, span := tracer().Start(ctx, "Cache.Set", trace.WithAttributes(c.driver, attribute.String("receipt.id", key)))
+	defer func() { End(span, err) }()
+	return c.next.Set(ctx, key, value, expiration)
+}
+
+func (c *tracedCache) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := tracer().Start(ctx, "Cache.Delete", trace.WithAttributes(c.driver, attribute.String("receipt.id", key)))
+	defer func() { End(span, err) }()
+	return c.next.Delete(ctx, key)
+}
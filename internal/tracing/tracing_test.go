@@ -0,0 +1,132 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"testing"
+	"ticket-processor/internal/config"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"ticket-processor/internal/tracing/tracingtest"
+	"time"
+)
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestWrapStorage(t *testing.T) {
+	recorder := tracingtest.Record(t)
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	s := WrapStorage(storage.NewInMemoryStore(), "memory")
+
+	id, err := s.Store(ctx, models.ReceiptRecord{Fingerprint: "fingerprint"})
+	require.NoError(t, err)
+	_, err = s.Store(ctx, models.ReceiptRecord{Fingerprint: "fingerprint"})
+	require.ErrorIs(t, err, storage.ErrDuplicate)
+	_, err = s.Retrieve(ctx, id)
+	require.NoError(t, err)
+	_, err = s.Retrieve(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Equal(t, []string{"Storage.Store", "Storage.Store", "Storage.Retrieve", "Storage.Retrieve", "parent"}, tracingtest.Names(recorder))
+	for _, span := range spans[:4] {
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID(), span.Name())
+		assert.Equal(t, "memory", attributes(span)["storage.driver"].AsString())
+		// Duplicates and unknown IDs are expected outcomes, not failures.
+		assert.Equal(t, codes.Unset, span.Status().Code, span.Name())
+	}
+	assert.Equal(t, id, attributes(spans[0])["receipt.id"].AsString())
+	assert.Equal(t, "missing", attributes(spans[3])["receipt.id"].AsString())
+}
+
+type failingCache struct{}
+
+func (failingCache) Load(context.Context, string) (int, bool) { return 0, false }
+
+func (failingCache) Set(context.Context, string, int, time.Duration) error {
+	return assert.AnError
+}
+
+func (failingCache) Delete(context.Context, string) error { return assert.AnError }
+
+func TestWrapCache(t *testing.T) {
+	recorder := tracingtest.Record(t)
+	ctx := context.Background()
+	inner := storage.NewInMemoryCache(zap.NewNop(), 10, time.Minute)
+	t.Cleanup(inner.Close)
+	c := WrapCache(inner, "memory")
+
+	_, ok := c.Load(ctx, "id")
+	assert.False(t, ok)
+	require.NoError(t, c.Set(ctx, "id", 42, time.Minute))
+	points, ok := c.Load(ctx, "id")
+	assert.True(t, ok)
+	assert.Equal(t, 42, points)
+	assert.Error(t, WrapCache(failingCache{}, "redis").Set(ctx, "id", 42, time.Minute))
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"Cache.Load", "Cache.Set", "Cache.Load", "Cache.Set"}, tracingtest.Names(recorder))
+	assert.False(t, attributes(spans[0])["cache.hit"].AsBool())
+	assert.True(t, attributes(spans[2])["cache.hit"].AsBool())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, codes.Error, spans[3].Status().Code)
+	assert.Equal(t, "redis", attributes(spans[3])["cache.driver"].AsString())
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name     string
+		exporter string
+		exported bool
+		wantErr  bool
+	}{
+		{name: "none", exporter: config.TracingExporterNone},
+		{name: "stdout", exporter: config.TracingExporterStdout, exported: true},
+		{name: "unknown", exporter: "zipkin", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Restore the globals Setup replaces.
+			tracingtest.Record(t)
+			var out bytes.Buffer
+
+			shutdown, err := setup(context.Background(), config.Tracing{Exporter: tt.exporter, ServiceName: "test-service", SampleRatio: 1}, &out)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			// The propagator is installed with every exporter.
+			ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.MapCarrier{
+				"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			})
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+
+			if tt.exported {
+				_, span := otel.Tracer("test").Start(ctx, "exported-span")
+				span.End()
+			}
+			require.NoError(t, shutdown(context.Background()))
+			assert.Equal(t, tt.exported, bytes.Contains(out.Bytes(), []byte("exported-span")))
+			assert.Equal(t, tt.exported, bytes.Contains(out.Bytes(), []byte("test-service")))
+		})
+	}
+}
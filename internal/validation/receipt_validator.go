@@ -1,10 +1,14 @@
 package validation
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"regexp"
 	"strings"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
 	"time"
 )
 
@@ -25,19 +29,24 @@ func retailerValidator(fl validator.FieldLevel) bool {
 	return re.MatchString(fl.Field().String())
 }
 
-func totalValidator(fl validator.FieldLevel) bool {
-	re := regexp.MustCompile(`^\d+\.\d{2}$`)
-	return re.MatchString(fl.Field().String())
-}
-
 func shortDescValidator(fl validator.FieldLevel) bool {
 	re := regexp.MustCompile(`^[\w\s\-]+$`)
 	return re.MatchString(fl.Field().String())
 }
 
-func priceValidator(fl validator.FieldLevel) bool {
-	re := regexp.MustCompile(`^\d+\.\d{2}$`)
-	return re.MatchString(fl.Field().String())
+// MaxCustomerIDLength is the longest customer ID accepted.
+const MaxCustomerIDLength = 64
+
+var customerIDPattern = regexp.MustCompile(`^[\w\-.@]+$`)
+
+func customerIDValidator(fl validator.FieldLevel) bool {
+	return customerIDPattern.MatchString(fl.Field().String())
+}
+
+// ValidCustomerID reports whether id may be used as a customer ID, such as the
+// customerId of a receipt.
+func ValidCustomerID(id string) bool {
+	return len(id) <= MaxCustomerIDLength && customerIDPattern.MatchString(id)
 }
 
 func notBlankValidator(fl validator.FieldLevel) bool {
@@ -54,21 +63,39 @@ func init() {
 		{"time", timeFormatValidator},
 		{"retailer", retailerValidator},
 		{"notblank", notBlankValidator},
-		{"total", totalValidator},
 		{"shortDesc", shortDescValidator},
-		{"price", priceValidator},
+		{"customerId", customerIDValidator},
 	}
 	for _, v := range validations {
 		if err := validate.RegisterValidation(v.tag, v.fn); err != nil {
 			panic(fmt.Errorf("failed to register validation: %w", err))
 		}
 	}
+	validate.RegisterStructValidationCtx(reconcileReceipt, models.Receipt{})
 }
 
-func ValidateReceipt(r interface{}) error {
-	if err := validate.Struct(r); err != nil {
+// ValidateReceipt checks field formats and that the item prices reconcile with
+// the total within tolerance. Failures are ierrors.ValidationErrors, in which
+// a reconciliation failure is reported on the total with its own message.
+func ValidateReceipt(r *models.Receipt, tolerance TotalTolerance) error {
+	err := validate.StructCtx(context.WithValue(context.Background(), toleranceKey{}, tolerance), r)
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
 		return err
 	}
 
-	return nil
+	fieldErrs := make(ierrors.ValidationErrors, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		message := fieldErr.Error()
+		if fieldErr.Tag() == reconciledTag {
+			message = fieldErr.Param()
+		}
+		fieldErrs = append(fieldErrs, ierrors.FieldError{Field: fieldErr.Field(), Message: message})
+	}
+	return fieldErrs
+}
+
+// ValidateRequest checks the validate tags of a request body other than a receipt.
+func ValidateRequest(r interface{}) error {
+	return validate.Struct(r)
 }
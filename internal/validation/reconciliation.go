@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"math"
+	"ticket-processor/internal/models"
+)
+
+// TotalTolerance is how far the sum of item prices may be from the receipt
+// total, to allow for tax and rounding: Amount plus Percent of the item sum. The
+// zero value requires item prices to sum to the total exactly.
+type TotalTolerance struct {
+	Amount  models.Money
+	Percent float64
+}
+
+// allowed returns the largest accepted difference for the given item sum,
+// rounded up to a cent and capped at the largest amount.
+func (t TotalTolerance) allowed(itemSum models.Money) models.Money {
+	if t.Percent <= 0 || itemSum <= 0 {
+		return t.Amount
+	}
+	extra := math.Ceil(float64(itemSum.Cents()) * t.Percent / 100)
+	if extra >= float64(math.MaxInt64-t.Amount.Cents()) {
+		return models.Money(math.MaxInt64)
+	}
+	return t.Amount + models.Money(extra)
+}
+
+// reconciledTag is the tag a receipt whose items do not sum to its total fails on.
+const reconciledTag = "reconciled"
+
+type toleranceKey struct{}
+
+// reconcileReceipt is the struct-level validation of models.Receipt, so every
+// validation of a receipt reconciles its total. It takes the tolerance from the
+// context ValidateReceipt passes; without one, item prices must sum to the
+// total exactly. The reported error carries the message as its param.
+func reconcileReceipt(ctx context.Context, sl validator.StructLevel) {
+	r := sl.Current().Interface().(models.Receipt)
+	tolerance, _ := ctx.Value(toleranceKey{}).(TotalTolerance)
+	if err := reconcileTotal(&r, tolerance); err != nil {
+		sl.ReportError(r.Total, "total", "Total", reconciledTag, err.Error())
+	}
+}
+
+func reconcileTotal(r *models.Receipt, tolerance TotalTolerance) error {
+	var sum models.Money
+	for _, item := range r.Items {
+		// Prices are never negative, so only the upper bound can be exceeded.
+		if item.Price > math.MaxInt64-sum {
+			return errors.New("item prices sum to more than the largest supported amount")
+		}
+		sum += item.Price
+	}
+
+	diff := r.Total - sum
+	if diff < 0 {
+		diff = -diff
+	}
+
+	allowed := tolerance.allowed(sum)
+	if diff <= allowed {
+		return nil
+	}
+
+	return fmt.Errorf("item prices sum to %s but total is %s; allowed difference is %s", sum, r.Total, allowed)
+}
@@ -0,0 +1,121 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+	"ticket-processor/internal/ierrors"
+	"ticket-processor/internal/models"
+)
+
+func TestValidateReceipt_Reconciliation(t *testing.T) {
+	receipt := func(total models.Money, prices ...models.Money) *models.Receipt {
+		r := &models.Receipt{
+			Retailer:     "Target",
+			PurchaseDate: "2022-01-01",
+			PurchaseTime: "13:01",
+			Total:        total,
+		}
+		for _, price := range prices {
+			r.Items = append(r.Items, models.Item{ShortDescription: "Item", Price: price})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name      string
+		tolerance TotalTolerance
+		receipt   *models.Receipt
+		wantErr   string
+	}{
+		{
+			name:    "exact match",
+			receipt: receipt(350, 100, 250),
+		},
+		{
+			name:    "total far above items",
+			receipt: receipt(100000, 100, 200),
+			wantErr: "item prices sum to 3.00 but total is 1000.00; allowed difference is 0.00",
+		},
+		{
+			name:    "total below items",
+			receipt: receipt(299, 100, 200),
+			wantErr: "item prices sum to 3.00 but total is 2.99; allowed difference is 0.00",
+		},
+		{
+			name:      "within absolute tolerance",
+			tolerance: TotalTolerance{Amount: 50},
+			receipt:   receipt(350, 300),
+		},
+		{
+			name:      "outside absolute tolerance",
+			tolerance: TotalTolerance{Amount: 50},
+			receipt:   receipt(351, 300),
+			wantErr:   "item prices sum to 3.00 but total is 3.51; allowed difference is 0.50",
+		},
+		{
+			name:      "within percent tolerance rounded up",
+			tolerance: TotalTolerance{Percent: 8.875},
+			receipt:   receipt(1089, 1000),
+		},
+		{
+			name:      "outside percent tolerance",
+			tolerance: TotalTolerance{Percent: 8.875},
+			receipt:   receipt(1090, 1000),
+			wantErr:   "item prices sum to 10.00 but total is 10.90; allowed difference is 0.89",
+		},
+		{
+			name:      "amount and percent combine",
+			tolerance: TotalTolerance{Amount: 1, Percent: 8.875},
+			receipt:   receipt(1090, 1000),
+		},
+		{
+			name:    "item prices overflow",
+			receipt: receipt(100, math.MaxInt64, 1),
+			wantErr: "item prices sum to more than the largest supported amount",
+		},
+		{
+			name:      "tolerance capped at the largest amount",
+			tolerance: TotalTolerance{Amount: math.MaxInt64 - 1, Percent: 100},
+			receipt:   receipt(1, 100),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateReceipt(tt.receipt, tt.tolerance)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, ierrors.ValidationErrors{{Field: "total", Message: tt.wantErr}}, err)
+		})
+	}
+}
+
+func TestReceiptStructValidation(t *testing.T) {
+	r := &models.Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "1:01pm",
+		Items:        []models.Item{{ShortDescription: "Item", Price: 300}},
+		Total:        350,
+	}
+
+	// Any validation of a receipt reconciles its total, exactly without a tolerance.
+	err := validate.Struct(r)
+	var validationErrs validator.ValidationErrors
+	require.ErrorAs(t, err, &validationErrs)
+	require.Len(t, validationErrs, 2)
+	assert.Equal(t, "time", validationErrs[0].Tag())
+	assert.Equal(t, "total", validationErrs[1].Field())
+	assert.Equal(t, "item prices sum to 3.00 but total is 3.50; allowed difference is 0.00", validationErrs[1].Param())
+
+	// ValidateReceipt reports it alongside the field errors with its own message.
+	assert.Equal(t, ierrors.ValidationErrors{
+		{Field: "PurchaseTime", Message: "Key: 'Receipt.PurchaseTime' Error:Field validation for 'PurchaseTime' failed on the 'time' tag"},
+		{Field: "total", Message: "item prices sum to 3.00 but total is 3.50; allowed difference is 0.10"},
+	}, ValidateReceipt(r, TotalTolerance{Amount: 10}))
+}
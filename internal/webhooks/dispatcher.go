@@ -0,0 +1,284 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sync"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "sha256=" followed by the hex HMAC-SHA256 of the
+	// request body keyed with the subscription secret.
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Event-Id"
+	EventTypeHeader = "X-Webhook-Event-Type"
+)
+
+var (
+	errDispatcherClosed = errors.New("webhook dispatcher is shut down")
+	errQueueFull        = errors.New("webhook queue is full")
+)
+
+// Options tune delivery. A delivery is attempted up to MaxAttempts times,
+// waiting InitialBackoff after the first failure and doubling up to MaxBackoff.
+type Options struct {
+	Workers        int
+	QueueSize      int
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type Dispatcher interface {
+	// PublishReceiptProcessed queues a receipt.processed event for every subscription.
+	PublishReceiptProcessed(ctx context.Context, record models.ReceiptRecord)
+	// Close stops accepting events and waits for queued deliveries. Once ctx is
+	// done, deliveries still pending are dead-lettered.
+	Close(ctx context.Context) error
+}
+
+type delivery struct {
+	subscription models.WebhookSubscription
+	event        models.WebhookEvent
+	body         []byte
+}
+
+type dispatcher struct {
+	store  storage.WebhookStore
+	client *http.Client
+	opts   Options
+	log    *zap.Logger
+
+	// ctx is cancelled when Close gives up waiting, abandoning retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan delivery
+	// letters holds the deliveries refused because the queue is full until
+	// they are dead-lettered, so publishing never waits on the store.
+	letters chan delivery
+	wg      sync.WaitGroup
+}
+
+// NewDispatcher lists the subscriptions of every event from store, which should
+// therefore answer from memory, as a NewSubscriptionCache does.
+func NewDispatcher(log *zap.Logger, store storage.WebhookStore, client *http.Client, opts Options) Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &dispatcher{
+		store:   store,
+		client:  client,
+		opts:    opts,
+		log:     log,
+		ctx:     ctx,
+		cancel:  cancel,
+		queue:   make(chan delivery, opts.QueueSize),
+		letters: make(chan delivery, opts.QueueSize),
+	}
+
+	d.wg.Add(opts.Workers + 1)
+	for range opts.Workers {
+		go d.work()
+	}
+	go d.writeDeadLetters()
+	return d
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *dispatcher) PublishReceiptProcessed(ctx context.Context, record models.ReceiptRecord) {
+	subscriptions, err := d.store.ListSubscriptions(ctx)
+	if err != nil {
+		d.log.Error("Error listing webhook subscriptions", zap.Error(err))
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	data, _ := json.Marshal(models.ReceiptProcessedData{
+		ReceiptID:    record.ID,
+		Points:       record.Points.Total,
+		ProcessedAt:  record.ProcessedAt,
+		RulesVersion: record.RulesVersion,
+	})
+	event := models.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      models.EventReceiptProcessed,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	body, _ := json.Marshal(event)
+
+	d.mu.RLock()
+	closed := d.closed
+	if !closed {
+		for _, sub := range subscriptions {
+			del := delivery{subscription: sub, event: event, body: body}
+			select {
+			case d.queue <- del:
+			default:
+				select {
+				case d.letters <- del:
+				default:
+					d.log.Error("Webhook delivery dropped",
+						zap.String("subscriptionId", del.subscription.ID),
+						zap.String("eventId", del.event.ID),
+						zap.Error(errQueueFull),
+					)
+				}
+			}
+		}
+	}
+	d.mu.RUnlock()
+
+	// Once shut down there is no worker left to hand the deliveries to.
+	if closed {
+		for _, sub := range subscriptions {
+			d.deadLetter(delivery{subscription: sub, event: event, body: body}, 0, errDispatcherClosed)
+		}
+	}
+}
+
+func (d *dispatcher) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+		close(d.letters)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		d.cancel()
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-done
+		return fmt.Errorf("webhook deliveries abandoned: %w", ctx.Err())
+	}
+}
+
+func (d *dispatcher) work() {
+	defer d.wg.Done()
+	for del := range d.queue {
+		d.deliver(del)
+	}
+}
+
+// writeDeadLetters dead-letters the deliveries refused because the queue was full.
+func (d *dispatcher) writeDeadLetters() {
+	defer d.wg.Done()
+	for del := range d.letters {
+		d.deadLetter(del, 0, errQueueFull)
+	}
+}
+
+// deliver attempts del until it succeeds, runs out of attempts or the dispatcher
+// gives up at shutdown, and dead-letters it in the latter two cases.
+func (d *dispatcher) deliver(del delivery) {
+	var err error
+	attempt := 0
+	for attempt < d.opts.MaxAttempts {
+		if attempt > 0 {
+			select {
+			case <-time.After(d.backoff(attempt)):
+			case <-d.ctx.Done():
+				d.deadLetter(del, attempt, fmt.Errorf("%w; last error: %w", errDispatcherClosed, err))
+				return
+			}
+		}
+
+		attempt++
+		if err = d.send(del); err == nil {
+			return
+		}
+		d.log.Warn("Webhook delivery failed",
+			zap.String("subscriptionId", del.subscription.ID),
+			zap.String("eventId", del.event.ID),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+	}
+
+	d.deadLetter(del, attempt, err)
+}
+
+// backoff returns the wait before the attempt following the given number of failures.
+func (d *dispatcher) backoff(failures int) time.Duration {
+	wait := d.opts.InitialBackoff
+	for i := 1; i < failures && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, d.opts.MaxBackoff)
+}
+
+func (d *dispatcher) send(del delivery) error {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, del.subscription.URL, bytes.NewReader(del.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(del.subscription.Secret, del.body))
+	req.Header.Set(EventIDHeader, del.event.ID)
+	req.Header.Set(EventTypeHeader, del.event.Type)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("subscriber responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (d *dispatcher) deadLetter(del delivery, attempts int, err error) {
+	d.log.Error("Webhook delivery dead-lettered",
+		zap.String("subscriptionId", del.subscription.ID),
+		zap.String("eventId", del.event.ID),
+		zap.Int("attempts", attempts),
+		zap.Error(err),
+	)
+
+	letter := models.WebhookDeadLetter{
+		SubscriptionID: del.subscription.ID,
+		URL:            del.subscription.URL,
+		Event:          del.event,
+		Attempts:       attempts,
+		LastError:      err.Error(),
+		FailedAt:       time.Now().UTC(),
+	}
+	if err := d.store.AddDeadLetter(context.Background(), letter); err != nil {
+		d.log.Error("Error storing webhook dead letter", zap.Error(err))
+	}
+}
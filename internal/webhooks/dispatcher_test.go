@@ -0,0 +1,259 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+	"time"
+)
+
+var testOptions = Options{
+	Workers:        2,
+	QueueSize:      10,
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     4 * time.Millisecond,
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is an httptest server that records the requests it gets and answers
+// them with the next status from statuses, then 200.
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []receivedRequest
+	statuses []int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+func processedRecord() models.ReceiptRecord {
+	return models.ReceiptRecord{
+		ID:           "receipt-1",
+		Points:       models.PointsBreakdown{Total: 42},
+		ProcessedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		RulesVersion: "v1",
+	}
+}
+
+func subscribe(t *testing.T, store storage.WebhookStore, url, secret string) models.WebhookSubscription {
+	t.Helper()
+	sub, err := store.CreateSubscription(context.Background(), models.WebhookSubscription{URL: url, Secret: secret})
+	require.NoError(t, err)
+	return sub
+}
+
+func TestDispatcher_DeliversSignedEvents(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	first := newReceiver(t)
+	second := newReceiver(t)
+	subscribe(t, store, first.URL, "first-secret-0123")
+	subscribe(t, store, second.URL, "second-secret-012")
+
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, testOptions)
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+	require.NoError(t, d.Close(context.Background()))
+
+	for secret, r := range map[string]*receiver{"first-secret-0123": first, "second-secret-012": second} {
+		requests := r.received()
+		require.Len(t, requests, 1)
+		req := requests[0]
+
+		assert.Equal(t, Sign(secret, req.body), req.header.Get(SignatureHeader))
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		assert.Equal(t, models.EventReceiptProcessed, req.header.Get(EventTypeHeader))
+
+		var event models.WebhookEvent
+		require.NoError(t, json.Unmarshal(req.body, &event))
+		assert.Equal(t, event.ID, req.header.Get(EventIDHeader))
+		assert.Equal(t, models.EventReceiptProcessed, event.Type)
+		assert.JSONEq(t, `{"receiptId":"receipt-1","points":42,"processedAt":"2024-01-02T03:04:05Z","rulesVersion":"v1"}`, string(event.Data))
+	}
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	subscribe(t, store, r.URL, "secret-0123456789")
+
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, testOptions)
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+	require.NoError(t, d.Close(context.Background()))
+
+	requests := r.received()
+	require.Len(t, requests, 3)
+	assert.Equal(t, requests[0].body, requests[2].body, "retries resend the same event")
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDispatcher_DeadLettersAfterMaxAttempts(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError, http.StatusBadGateway)
+	sub := subscribe(t, store, r.URL, "secret-0123456789")
+
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, testOptions)
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+	require.NoError(t, d.Close(context.Background()))
+
+	assert.Len(t, r.received(), 3)
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, sub.ID, letters[0].SubscriptionID)
+	assert.Equal(t, r.URL, letters[0].URL)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "subscriber responded with status 502", letters[0].LastError)
+	assert.Equal(t, models.EventReceiptProcessed, letters[0].Event.Type)
+}
+
+func TestDispatcher_CloseAbandonsRetries(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	subscribe(t, store, server.URL, "secret-0123456789")
+
+	opts := testOptions
+	opts.InitialBackoff = time.Hour
+	opts.MaxBackoff = time.Hour
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, opts)
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Close(ctx), context.DeadlineExceeded)
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, 1, letters[0].Attempts)
+	assert.Contains(t, letters[0].LastError, "subscriber responded with status 500")
+
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+	letters, err = store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, letters, 2, "events published after Close are dead-lettered")
+}
+
+func TestDispatcher_NoSubscriptions(t *testing.T) {
+	store := storage.NewInMemoryWebhookStore(10)
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, testOptions)
+
+	d.PublishReceiptProcessed(context.Background(), processedRecord())
+	require.NoError(t, d.Close(context.Background()))
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=aa9e2e3575f5d7098b6caccd790888c36d5fdb63342a73bada2d6a51747a8494", Sign("secret", []byte(`{"a":1}`)))
+	assert.NotEqual(t, Sign("secret", []byte(`{"a":1}`)), Sign("other", []byte(`{"a":1}`)))
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := &dispatcher{opts: Options{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}}
+
+	for failures, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		assert.Equal(t, want, d.backoff(failures), "after %d failures", failures)
+	}
+}
+
+// blockingStore holds AddDeadLetter until release is closed.
+type blockingStore struct {
+	storage.WebhookStore
+	release chan struct{}
+}
+
+func (s *blockingStore) AddDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error {
+	<-s.release
+	return s.WebhookStore.AddDeadLetter(ctx, letter)
+}
+
+func TestDispatcher_FullQueueDeadLettersInBackground(t *testing.T) {
+	store := &blockingStore{WebhookStore: storage.NewInMemoryWebhookStore(10), release: make(chan struct{})}
+	subscribe(t, store, "http://127.0.0.1:1/queued", "secret-0123456789")
+	refused := subscribe(t, store, "http://127.0.0.1:1/refused", "secret-0123456789")
+
+	// Without workers the first delivery fills the queue for good.
+	opts := testOptions
+	opts.Workers = 0
+	opts.QueueSize = 1
+	d := NewDispatcher(zap.NewNop(), store, http.DefaultClient, opts)
+
+	published := make(chan struct{})
+	go func() {
+		d.PublishReceiptProcessed(context.Background(), processedRecord())
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publishing waited for the dead letter to be stored")
+	}
+
+	close(store.release)
+	require.NoError(t, d.Close(context.Background()))
+
+	letters, err := store.ListDeadLetters(context.Background())
+	require.NoError(t, err)
+	require.Len(t, letters, 1)
+	assert.Equal(t, refused.ID, letters[0].SubscriptionID)
+	assert.Equal(t, "webhook queue is full", letters[0].LastError)
+}
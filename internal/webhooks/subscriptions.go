@@ -0,0 +1,65 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+// subscriptionCache keeps the subscriptions of a WebhookStore in memory so
+// events are published without querying the store. They are loaded on first
+// use and again after a subscription is created or deleted through the cache,
+// so every change has to go through it.
+type subscriptionCache struct {
+	storage.WebhookStore
+
+	mu            sync.RWMutex
+	loaded        bool
+	subscriptions []models.WebhookSubscription
+}
+
+// NewSubscriptionCache wraps store so ListSubscriptions is answered from memory.
+// The dispatcher and the webhook handler should share it.
+func NewSubscriptionCache(store storage.WebhookStore) storage.WebhookStore {
+	return &subscriptionCache{WebhookStore: store}
+}
+
+func (c *subscriptionCache) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	c.mu.RLock()
+	if c.loaded {
+		defer c.mu.RUnlock()
+		return slices.Clone(c.subscriptions), nil
+	}
+	c.mu.RUnlock()
+
+	// The write lock is held across the query so a change made meanwhile
+	// invalidates what it returns rather than being overwritten by it.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded {
+		subscriptions, err := c.WebhookStore.ListSubscriptions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.subscriptions, c.loaded = subscriptions, true
+	}
+	return slices.Clone(c.subscriptions), nil
+}
+
+func (c *subscriptionCache) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	defer c.invalidate()
+	return c.WebhookStore.CreateSubscription(ctx, sub)
+}
+
+func (c *subscriptionCache) DeleteSubscription(ctx context.Context, id string) error {
+	defer c.invalidate()
+	return c.WebhookStore.DeleteSubscription(ctx, id)
+}
+
+func (c *subscriptionCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loaded, c.subscriptions = false, nil
+}
@@ -0,0 +1,71 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"ticket-processor/internal/models"
+	"ticket-processor/internal/storage"
+)
+
+// countingStore counts the subscription listings that reach the store and
+// fails them while err is set.
+type countingStore struct {
+	storage.WebhookStore
+	lists int
+	err   error
+}
+
+func (s *countingStore) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	s.lists++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.WebhookStore.ListSubscriptions(ctx)
+}
+
+func subscriptionIDs(t *testing.T, store storage.WebhookStore) []string {
+	t.Helper()
+	subs, err := store.ListSubscriptions(context.Background())
+	require.NoError(t, err)
+	ids := []string{}
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	return ids
+}
+
+func TestSubscriptionCache(t *testing.T) {
+	backing := &countingStore{WebhookStore: storage.NewInMemoryWebhookStore(10)}
+	cache := NewSubscriptionCache(backing)
+
+	first := subscribe(t, cache, "https://a.example/hooks", "secret-0123456789")
+	assert.Equal(t, []string{first.ID}, subscriptionIDs(t, cache))
+	assert.Equal(t, []string{first.ID}, subscriptionIDs(t, cache))
+	assert.Equal(t, 1, backing.lists, "listings are answered from memory")
+
+	second := subscribe(t, cache, "https://b.example/hooks", "secret-0123456789")
+	assert.Equal(t, []string{first.ID, second.ID}, subscriptionIDs(t, cache), "a new subscription is listed")
+	assert.Equal(t, 2, backing.lists)
+
+	require.NoError(t, cache.DeleteSubscription(context.Background(), first.ID))
+	assert.Equal(t, []string{second.ID}, subscriptionIDs(t, cache), "a deleted subscription is not listed")
+	assert.Equal(t, 3, backing.lists)
+
+	assert.ErrorIs(t, cache.DeleteSubscription(context.Background(), first.ID), storage.ErrNotFound)
+}
+
+func TestSubscriptionCache_ListingErrorsAreNotCached(t *testing.T) {
+	backing := &countingStore{WebhookStore: storage.NewInMemoryWebhookStore(10), err: errors.New("database is locked")}
+	cache := NewSubscriptionCache(backing)
+	sub := subscribe(t, cache, "https://a.example/hooks", "secret-0123456789")
+
+	_, err := cache.ListSubscriptions(context.Background())
+	assert.EqualError(t, err, "database is locked")
+
+	backing.err = nil
+	assert.Equal(t, []string{sub.ID}, subscriptionIDs(t, cache))
+	assert.Equal(t, 2, backing.lists)
+}